@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"gorm.io/gorm"
+)
+
+// CommitHook is work that has to wait for the transaction it was registered in to commit
+type CommitHook interface {
+	AfterCommit()
+}
+
+// hookedPool begins transactions that run their hooks once they commit, gorm has no callback for it
+type hookedPool struct {
+	*sql.DB
+}
+
+func (p *hookedPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &hookedTx{Tx: tx, hooks: map[string]CommitHook{}}, nil
+}
+
+func (p *hookedPool) GetDBConn() (*sql.DB, error) {
+	return p.DB, nil
+}
+
+type hookedTx struct {
+	*sql.Tx
+	hooks map[string]CommitHook
+	order []string
+}
+
+func (t *hookedTx) Commit() error {
+	if err := t.Tx.Commit(); err != nil {
+		return err
+	}
+	for _, key := range t.order {
+		t.hooks[key].AfterCommit()
+	}
+	return nil
+}
+
+// useCommitHooks lets the transactions of db register CommitHook
+func useCommitHooks(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	pool := &hookedPool{DB: sqlDB}
+	db.ConnPool = pool
+	db.Statement.ConnPool = pool
+	return nil
+}
+
+// OnCommit returns the hook registered under key in the transaction of db, newHook creates and
+// registers it the first time so the statements of a transaction share one hook. registered is
+// false when db isn't in a transaction, the caller runs the hook itself then.
+func OnCommit(db *gorm.DB, key string, newHook func() CommitHook) (hook CommitHook, registered bool) {
+	tx, ok := db.Statement.ConnPool.(*hookedTx)
+	if !ok {
+		return newHook(), false
+	}
+	if hook, ok := tx.hooks[key]; ok {
+		return hook, true
+	}
+	hook = newHook()
+	tx.hooks[key] = hook
+	tx.order = append(tx.order, key)
+	return hook, true
+}
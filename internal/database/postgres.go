@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/driver/postgres"
@@ -33,6 +34,10 @@ func NewDatabaseHandler(cfg *env.Config) *DatabaseHandler {
 		panic(fmt.Sprintf("failed to connect to PostgreSQL: %s", err))
 	}
 
+	if err := useCommitHooks(db); err != nil {
+		panic(fmt.Sprintf("failed to retrieve database connection: %s", err))
+	}
+
 	// Set up the handler
 	dbHandler = DatabaseHandler{
 		DB:  db,
@@ -60,6 +65,10 @@ func NewDatabaseHandler(cfg *env.Config) *DatabaseHandler {
 
 // Migrate runs database migrations for all models.
 func (d *DatabaseHandler) Migrate() error {
+	if err := d.DB.SetupJoinTable(&models.Project{}, "ProjectMembers", &models.ProjectMember{}); err != nil {
+		return fmt.Errorf("failed to setup project members join table: %w", err)
+	}
+
 	if err := d.DB.AutoMigrate(
 		&models.Team{},
 		&models.Project{},
@@ -69,8 +78,67 @@ func (d *DatabaseHandler) Migrate() error {
 		&models.TeamMember{},
 		&models.Message{},
 		&models.Report{},
+		&models.SecurityEvent{},
+		&models.TeamInvitation{},
+		&models.ProjectMember{},
+		&models.CustomRole{},
+		&models.RolePermission{},
+		&models.WorkflowStatus{},
+		&models.WorkflowTransition{},
+		&models.TaskMove{},
+		&models.TaskDependency{},
+		&models.ProjectTemplate{},
+		&models.TemplateTask{},
+		&models.TemplateSubtask{},
+		&models.TimeEntry{},
+		&models.TaskComment{},
+		&models.CommentRevision{},
+		&models.CommentMention{},
+		&models.CommentAttachment{},
+		&models.Blob{},
+		&models.Attachment{},
+		&models.Label{},
+		&models.CustomField{},
+		&models.TaskFieldValue{},
+		&models.SavedView{},
+		&models.TaskEvent{},
+		&models.Activity{},
 	); err != nil {
 		return fmt.Errorf("migration failed: %w", err)
 	}
+
+	// projects created before their workflow was created with them get the default columns
+	var defaults []string
+	var args []interface{}
+	for _, status := range models.DefaultWorkflow {
+		defaults = append(defaults, "(?::text, ?::integer, ?::boolean)")
+		args = append(args, status.Name, status.Position, status.IsDone)
+	}
+	if err := d.DB.Exec(`
+		INSERT INTO workflow_statuses (project_id, name, position, is_done, created_at, updated_at)
+		SELECT projects.id, defaults.name, defaults.position, defaults.is_done, NOW(), NOW()
+		FROM projects CROSS JOIN (VALUES `+strings.Join(defaults, ", ")+`) AS defaults(name, position, is_done)
+		WHERE NOT EXISTS (SELECT 1 FROM workflow_statuses WHERE workflow_statuses.project_id = projects.id)`, args...).Error; err != nil {
+		return fmt.Errorf("failed to backfill workflows: %w", err)
+	}
+
+	// tasks created before the history existed start it now, with their current state
+	if err := d.DB.Exec(`
+		INSERT INTO task_events (task_id, project_id, type, status_id, done, actor_id, occurred_at)
+		SELECT tasks.id, tasks.project_id, 'created', tasks.status_id, COALESCE(workflow_statuses.is_done, false), 0, NOW()
+		FROM tasks LEFT JOIN workflow_statuses ON workflow_statuses.id = tasks.status_id
+		WHERE NOT EXISTS (SELECT 1 FROM task_events WHERE task_events.task_id = tasks.id)`).Error; err != nil {
+		return fmt.Errorf("failed to backfill task history: %w", err)
+	}
+	if err := d.DB.Exec(`
+		INSERT INTO task_events (task_id, project_id, type, user_id, actor_id, occurred_at)
+		SELECT task_members.task_id, tasks.project_id, 'assigned', task_members.user_id, 0, NOW()
+		FROM task_members JOIN tasks ON tasks.id = task_members.task_id
+		WHERE NOT EXISTS (
+			SELECT 1 FROM task_events
+			WHERE task_events.task_id = task_members.task_id AND task_events.type = 'assigned' AND task_events.user_id = task_members.user_id
+		)`).Error; err != nil {
+		return fmt.Errorf("failed to backfill task history: %w", err)
+	}
 	return nil
 }
@@ -2,7 +2,10 @@ package database
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -47,6 +50,15 @@ func (rm *RedisHandler) Publish(event []byte, channel string) error {
 	return nil
 }
 
+// PublishEvent publishes a server side event to the sockets of its recipients.
+func (rm *RedisHandler) PublishEvent(event dtos.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event, err : %s", err.Error())
+	}
+	return rm.Publish(payload, "messages")
+}
+
 // SetBlacklistedToken adds a token to the blacklist with a TTL.
 func (rm *RedisHandler) SetBlacklistedToken(token string, ttl time.Duration) error {
 	return rm.Client.Set(context.Background(), "blacklist:"+token, "true", ttl).Err()
@@ -62,3 +74,80 @@ func (rm *RedisHandler) IsTokenBlacklisted(token string) (bool, error) {
 	}
 	return val == "true", nil
 }
+
+func sessionVersionKey(userID uint) string {
+	return "session:version:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// GetSessionVersion returns the current session version of a user, tokens carrying an older version are revoked.
+func (rm *RedisHandler) GetSessionVersion(userID uint) (int64, error) {
+	version, err := rm.Client.Get(context.Background(), sessionVersionKey(userID)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return version, err
+}
+
+// RevokeSessions bumps the session version of a user, invalidating every token issued before.
+func (rm *RedisHandler) RevokeSessions(userID uint) error {
+	return rm.Client.Incr(context.Background(), sessionVersionKey(userID)).Err()
+}
+
+const dashboardEpochKey = "dashboard:epoch"
+
+func userDashboardEpochKey(userID uint) string {
+	return dashboardEpochKey + ":" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func dashboardKey(userID uint, epoch string) string {
+	return "dashboard:" + strconv.FormatUint(uint64(userID), 10) + ":" + epoch
+}
+
+// GetDashboardEpoch returns the dashboard epoch of a user, it changes when every dashboard or the
+// user's is invalidated. cached dashboards of older epochs are stale.
+func (rm *RedisHandler) GetDashboardEpoch(userID uint) (string, error) {
+	values, err := rm.Client.MGet(context.Background(), dashboardEpochKey, userDashboardEpochKey(userID)).Result()
+	if err != nil {
+		return "", err
+	}
+	epochs := make([]string, len(values))
+	for i, value := range values {
+		epochs[i] = "0"
+		if epoch, ok := value.(string); ok {
+			epochs[i] = epoch
+		}
+	}
+	return strings.Join(epochs, "."), nil
+}
+
+// InvalidateDashboards bumps the dashboard epoch so every cached dashboard is rebuilt on its next read.
+func (rm *RedisHandler) InvalidateDashboards() error {
+	return rm.Client.Incr(context.Background(), dashboardEpochKey).Err()
+}
+
+// InvalidateUserDashboards bumps the dashboard epochs of users so their cached dashboards are rebuilt on their next read.
+func (rm *RedisHandler) InvalidateUserDashboards(userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	pipe := rm.Client.Pipeline()
+	for _, userID := range userIDs {
+		pipe.Incr(context.Background(), userDashboardEpochKey(userID))
+	}
+	_, err := pipe.Exec(context.Background())
+	return err
+}
+
+// GetCachedDashboard returns the dashboard of a user cached in an epoch, nil when there is none.
+func (rm *RedisHandler) GetCachedDashboard(userID uint, epoch string) ([]byte, error) {
+	data, err := rm.Client.Get(context.Background(), dashboardKey(userID, epoch)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+// SetCachedDashboard caches the dashboard of a user for an epoch.
+func (rm *RedisHandler) SetCachedDashboard(userID uint, epoch string, data []byte, ttl time.Duration) error {
+	return rm.Client.Set(context.Background(), dashboardKey(userID, epoch), data, ttl).Err()
+}
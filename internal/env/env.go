@@ -1,19 +1,50 @@
 package env
 
+import "time"
+
 type Config struct {
-	MongoCollection     string `envDefault:"messages"`
-	MongoDatabase       string `envDefault:"mizito"`
-	AppPort             string `envDefault:":8000"`
-	AuthorizationSecret string `evnDefault:"testing12345678910"`
-	RedisHost           string `envDefault:"localhost"`
-	RedisPort           string `envDefault:"6379"`
-	RedisUsername       string
-	RedisPassword       string
-	RedisProjectsDB     string
-	PostgresHost        string `envDefault:"localhost"`
-	PostgresPort        string `envDefault:"5432"`
-	PostgresUser        string `envDefault:"postgres"`
-	PostgresPass        string `envDefault:"postgres"`
-	PostgresDatabase    string `envDefault:"mizito"`
-	MongoDBHost         string `envDefault:"mongodb://localhost:27017"`
+	MongoCollection       string `envDefault:"messages"`
+	MongoDatabase         string `envDefault:"mizito"`
+	AppPort               string `envDefault:":8000"`
+	AppBaseURL            string `envDefault:"http://localhost:8000"`
+	AuthorizationSecret   string `evnDefault:"testing12345678910"`
+	RedisHost             string `envDefault:"localhost"`
+	RedisPort             string `envDefault:"6379"`
+	RedisUsername         string
+	RedisPassword         string
+	RedisProjectsDB       string
+	PostgresHost          string `envDefault:"localhost"`
+	PostgresPort          string `envDefault:"5432"`
+	PostgresUser          string `envDefault:"postgres"`
+	PostgresPass          string `envDefault:"postgres"`
+	PostgresDatabase      string `envDefault:"mizito"`
+	MongoDBHost           string `envDefault:"mongodb://localhost:27017"`
+	SMTPHost              string
+	SMTPPort              string `envDefault:"587"`
+	SMTPUsername          string
+	SMTPPassword          string
+	MailFrom              string        `envDefault:"no-reply@mizito.local"`
+	LoginMaxAttempts      int64         `envDefault:"5"`
+	LoginMaxAttemptsPerIP int64         `envDefault:"20"`
+	LoginAttemptWindow    time.Duration `envDefault:"15m"`
+	LoginLockoutBase      time.Duration `envDefault:"1m"`
+	LoginLockoutMax       time.Duration `envDefault:"24h"`
+	UnlockTokenTTL        time.Duration `envDefault:"1h"`
+	PasswordResetTTL      time.Duration `envDefault:"30m"`
+	EmailVerificationTTL  time.Duration `envDefault:"48h"`
+	TeamInvitationTTL     time.Duration `envDefault:"168h"`
+	RecurrenceInterval    time.Duration `envDefault:"1m"`
+	DashboardCacheTTL     time.Duration `envDefault:"2m"`
+	StorageBackend        string        `envDefault:"local"`
+	StorageLocalPath      string        `envDefault:"./data/attachments"`
+	S3Endpoint            string        `envDefault:"http://localhost:9000"`
+	S3Region              string        `envDefault:"us-east-1"`
+	S3Bucket              string        `envDefault:"mizito"`
+	S3AccessKey           string
+	S3SecretKey           string
+	AttachmentMaxSize     int64         `envDefault:"26214400"`
+	AttachmentTypes       []string      `envDefault:"image/png,image/jpeg,image/gif,image/webp,application/pdf,text/plain,text/csv,application/zip,application/json"`
+	AttachmentURLTTL      time.Duration `envDefault:"15m"`
+	TrashRetention        time.Duration `envDefault:"720h"`
+	TrashPurgeInterval    time.Duration `envDefault:"1h"`
 }
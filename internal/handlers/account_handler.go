@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"mizito/internal/env"
+	"mizito/internal/mailer"
+	"mizito/internal/repositories"
+	bearerrepo "mizito/internal/repositories/auth/bearer"
+	verificationrepo "mizito/internal/repositories/auth/verification"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AccountHandler interface {
+	ForgotPassword(ctx *fiber.Ctx) error
+	ResetPassword(ctx *fiber.Ctx) error
+	ResetPasswordForm(ctx *fiber.Ctx) error
+	RequestEmailVerification(ctx *fiber.Ctx) error
+	VerifyEmail(ctx *fiber.Ctx) error
+}
+
+type accountHandler struct {
+	userRepo         repositories.UserRepository
+	verificationRepo verificationrepo.VerificationRepository
+	jwtRepo          bearerrepo.BearerRepository
+	mailer           mailer.Mailer
+	cfg              *env.Config
+}
+
+func NewAccountHandler(userRepo repositories.UserRepository, verificationRepo verificationrepo.VerificationRepository, jwtRepo bearerrepo.BearerRepository, mailer mailer.Mailer, cfg *env.Config) AccountHandler {
+	return &accountHandler{
+		userRepo:         userRepo,
+		verificationRepo: verificationRepo,
+		jwtRepo:          jwtRepo,
+		mailer:           mailer,
+		cfg:              cfg,
+	}
+}
+
+// ForgotPassword emails a password reset link, the response doesn't reveal whether the email is registered
+func (ah *accountHandler) ForgotPassword(ctx *fiber.Ctx) error {
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil || payload.Email == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Email is required",
+		})
+	}
+
+	user, err := ah.userRepo.GetUserByEmail(payload.Email)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to request password reset",
+		})
+	}
+
+	if user != nil {
+		token, err := ah.verificationRepo.IssueToken(user.ID, user.Email, verificationrepo.PasswordReset, ah.cfg.PasswordResetTTL)
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to request password reset",
+			})
+		}
+
+		body := fmt.Sprintf(
+			"Use the link below to choose a new password, it expires in %s:\n%s/api/auth/password/reset?token=%s\n",
+			ah.cfg.PasswordResetTTL, ah.cfg.AppBaseURL, token,
+		)
+		if err := ah.mailer.Send(user.Email, "Reset your mizito password", body); err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to send password reset email",
+			})
+		}
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "If the email is registered, a password reset link has been sent",
+	})
+}
+
+// resetPasswordPage is served for the emailed reset link, the form posts to ResetPassword
+const resetPasswordPage = `<!DOCTYPE html>
+<html>
+<head><title>Reset your mizito password</title></head>
+<body>
+<form method="post" action="/api/auth/password/reset">
+<input type="hidden" name="token" value="%s">
+<label>New password <input type="password" name="password" required></label>
+<button type="submit">Reset password</button>
+</form>
+</body>
+</html>
+`
+
+// ResetPasswordForm serves the page the reset email links to, the token is only used once the form is sent
+func (ah *accountHandler) ResetPasswordForm(ctx *fiber.Ctx) error {
+	token := ctx.Query("token")
+	if token == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Token is required",
+		})
+	}
+
+	ctx.Type("html")
+	return ctx.Status(fiber.StatusOK).SendString(fmt.Sprintf(resetPasswordPage, html.EscapeString(token)))
+}
+
+// ResetPassword sets a new password using a reset token and revokes every existing session of the user
+func (ah *accountHandler) ResetPassword(ctx *fiber.Ctx) error {
+	var payload struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil || payload.Token == "" || payload.Password == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Token and password are required",
+		})
+	}
+
+	// checked before consuming the token so a weak password doesn't burn it
+	if err := repositories.ValidatePassword(payload.Password); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	userID, _, err := ah.verificationRepo.ConsumeToken(payload.Token, verificationrepo.PasswordReset)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := ah.userRepo.WithRequest(requestInfo(ctx)).UpdatePassword(userID, payload.Password); err != nil {
+		if errors.Is(err, repositories.ErrWeakPassword) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset password",
+		})
+	}
+
+	if err := ah.jwtRepo.RevokeSessions(userID); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Password changed but failed to revoke existing sessions",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Password reset successfully",
+	})
+}
+
+// RequestEmailVerification emails a verification link to an unverified address
+func (ah *accountHandler) RequestEmailVerification(ctx *fiber.Ctx) error {
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil || payload.Email == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Email is required",
+		})
+	}
+
+	user, err := ah.userRepo.GetUserByEmail(payload.Email)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to request email verification",
+		})
+	}
+
+	if user != nil && !user.EmailVerified {
+		token, err := ah.verificationRepo.IssueToken(user.ID, user.Email, verificationrepo.EmailVerification, ah.cfg.EmailVerificationTTL)
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to request email verification",
+			})
+		}
+
+		body := fmt.Sprintf(
+			"Confirm your email address using the link below:\n%s/api/auth/verify-email?token=%s\n",
+			ah.cfg.AppBaseURL, token,
+		)
+		if err := ah.mailer.Send(user.Email, "Verify your mizito email address", body); err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to send verification email",
+			})
+		}
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "If the email is registered and unverified, a verification link has been sent",
+	})
+}
+
+// VerifyEmail confirms the email address the verification token was sent to
+func (ah *accountHandler) VerifyEmail(ctx *fiber.Ctx) error {
+	token := ctx.Query("token")
+	if token == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Verification token is required",
+		})
+	}
+
+	userID, email, err := ah.verificationRepo.ConsumeToken(token, verificationrepo.EmailVerification)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// the token only proves the address it was sent to, not one the user changed to afterwards
+	user, err := ah.userRepo.GetUserByID(userID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify email",
+		})
+	}
+	if user == nil || user.Email != email {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": repositories.ErrEmailChanged.Error(),
+		})
+	}
+
+	if err := ah.userRepo.WithRequest(requestInfo(ctx)).MarkEmailVerified(userID, email); err != nil {
+		if errors.Is(err, repositories.ErrEmailChanged) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify email",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Email verified successfully",
+	})
+}
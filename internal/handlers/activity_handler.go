@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"mizito/pkg/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+type ActivityHandler interface {
+	GetProjectActivity(ctx *fiber.Ctx) error
+	GetTeamActivity(ctx *fiber.Ctx) error
+	GetAuditLog(ctx *fiber.Ctx) error
+}
+
+type activityHandler struct {
+	repo repositories.ActivityRepository
+}
+
+func NewActivityHandler(postgreSql *database.DatabaseHandler) ActivityHandler {
+	repo := repositories.NewActivityRepository(postgreSql)
+	return &activityHandler{
+		repo: repo,
+	}
+}
+
+// requestInfo identifies the request for the activities its mutations record
+func requestInfo(ctx *fiber.Ctx) repositories.RequestInfo {
+	request := repositories.RequestInfo{}
+	request.RequestID, _ = ctx.Locals(requestid.ConfigDefault.ContextKey).(string)
+	request.ActorID, _ = ctx.Locals("userID").(uint)
+	return request
+}
+
+func parseOptionalID(value string, name string) (*uint, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s", name)
+	}
+	id := uint(parsed)
+	return &id, nil
+}
+
+// parseActivityQuery reads the filters and the page of an activity listing
+func parseActivityQuery(ctx *fiber.Ctx) (repositories.ActivityQuery, error) {
+	query := repositories.ActivityQuery{
+		Action:     models.ActivityAction(ctx.Query("action")),
+		TargetType: models.ActivityTarget(ctx.Query("target_type")),
+		RequestID:  ctx.Query("request_id"),
+	}
+
+	var err error
+	if query.ActorID, err = parseOptionalID(ctx.Query("actor_id"), "actor ID"); err != nil {
+		return query, err
+	}
+	if query.TargetID, err = parseOptionalID(ctx.Query("target_id"), "target ID"); err != nil {
+		return query, err
+	}
+	if query.TeamID, err = parseOptionalID(ctx.Query("team_id"), "team ID"); err != nil {
+		return query, err
+	}
+	if query.ProjectID, err = parseOptionalID(ctx.Query("project_id"), "project ID"); err != nil {
+		return query, err
+	}
+
+	if from := ctx.Query("from"); from != "" {
+		parsed, err := parseDateParam(from, false)
+		if err != nil {
+			return query, errors.New("Invalid from date")
+		}
+		query.From = &parsed
+	}
+	if to := ctx.Query("to"); to != "" {
+		parsed, err := parseDateParam(to, true)
+		if err != nil {
+			return query, errors.New("Invalid to date")
+		}
+		query.To = &parsed
+	}
+
+	if cursor := ctx.Query("cursor"); cursor != "" {
+		parsed, err := strconv.ParseUint(cursor, 10, 32)
+		if err != nil {
+			return query, errors.New("Invalid cursor")
+		}
+		query.Cursor = uint(parsed)
+	}
+	if limit := ctx.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 {
+			return query, errors.New("Invalid limit")
+		}
+		query.Limit = parsed
+	}
+	return query, nil
+}
+
+func sendActivityPage(ctx *fiber.Ctx, page *repositories.ActivityPage) error {
+	if page.NextCursor != 0 {
+		ctx.Set("X-Next-Cursor", strconv.FormatUint(uint64(page.NextCursor), 10))
+	}
+	return ctx.Status(fiber.StatusOK).JSON(page.Activities)
+}
+
+// GetProjectActivity returns the activity feed of a project
+func (h *activityHandler) GetProjectActivity(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+	query, err := parseActivityQuery(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	page, err := h.repo.GetProjectActivity(uint(projectID), query, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return sendActivityPage(ctx, page)
+}
+
+// GetTeamActivity returns the activity feed of a team and its projects
+func (h *activityHandler) GetTeamActivity(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+	query, err := parseActivityQuery(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	page, err := h.repo.GetTeamActivity(uint(teamID), query, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return sendActivityPage(ctx, page)
+}
+
+// GetAuditLog queries the whole activity log for system admins
+func (h *activityHandler) GetAuditLog(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	query, err := parseActivityQuery(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	page, err := h.repo.GetAuditLog(query, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return sendActivityPage(ctx, page)
+}
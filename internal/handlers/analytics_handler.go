@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AnalyticsHandler interface {
+	GetBurndown(ctx *fiber.Ctx) error
+	GetThroughput(ctx *fiber.Ctx) error
+	GetCycleTimes(ctx *fiber.Ctx) error
+	GetWorkload(ctx *fiber.Ctx) error
+}
+
+type analyticsHandler struct {
+	repo repositories.AnalyticsRepository
+}
+
+func NewAnalyticsHandler(postgreSql *database.DatabaseHandler) AnalyticsHandler {
+	repo := repositories.NewAnalyticsRepository(postgreSql)
+	return &analyticsHandler{
+		repo: repo,
+	}
+}
+
+// analyticsParams reads the project and the from/to range of an analytics request
+func analyticsParams(ctx *fiber.Ctx) (uint, time.Time, time.Time, error) {
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return 0, time.Time{}, time.Time{}, errors.New("Invalid project ID")
+	}
+	from, to, err := parseDateRange(ctx)
+	if err != nil {
+		return 0, from, to, err
+	}
+	return uint(projectID), from, to, nil
+}
+
+// GetBurndown returns the total, done and remaining tasks of a project at the end of every day
+func (h *analyticsHandler) GetBurndown(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, from, to, err := analyticsParams(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	burndown, err := h.repo.GetBurndown(projectID, from, to, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(burndown)
+}
+
+// GetThroughput returns the tasks completed every week and the resulting velocity
+func (h *analyticsHandler) GetThroughput(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, from, to, err := analyticsParams(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	throughput, err := h.repo.GetThroughput(projectID, from, to, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(throughput)
+}
+
+// GetCycleTimes returns the cycle time distribution of the tasks completed in the range
+func (h *analyticsHandler) GetCycleTimes(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, from, to, err := analyticsParams(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	cycleTimes, err := h.repo.GetCycleTimes(projectID, from, to, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(cycleTimes)
+}
+
+// GetWorkload returns the assigned, completed and open tasks of every member
+func (h *analyticsHandler) GetWorkload(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, from, to, err := analyticsParams(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	workload, err := h.repo.GetWorkload(projectID, from, to, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(workload)
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mizito/internal/database"
+	"mizito/internal/env"
+	"mizito/internal/repositories"
+	"mizito/internal/storage"
+	"mizito/pkg/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AttachmentHandler interface {
+	UploadAttachment(ctx *fiber.Ctx) error
+	GetAttachment(ctx *fiber.Ctx) error
+	GetOwnerAttachments(ctx *fiber.Ctx) error
+	GetTaskAttachments(ctx *fiber.Ctx) error
+	GetAttachmentContent(ctx *fiber.Ctx) error
+	GetSignedURL(ctx *fiber.Ctx) error
+	GetSignedContent(ctx *fiber.Ctx) error
+	DeleteAttachment(ctx *fiber.Ctx) error
+}
+
+type attachmentHandler struct {
+	repo repositories.AttachmentRepository
+}
+
+func NewAttachmentHandler(postgreSql *database.DatabaseHandler, store storage.BlobStore, cfg *env.Config) AttachmentHandler {
+	repo := repositories.NewAttachmentRepository(postgreSql, store, cfg)
+	return &attachmentHandler{
+		repo: repo,
+	}
+}
+
+// sendContent streams an attachment, the reader is closed once the response is written
+func sendContent(ctx *fiber.Ctx, attachment *models.Attachment, content io.ReadCloser) error {
+	disposition := "attachment"
+	if ctx.QueryBool("inline") {
+		disposition = "inline"
+	}
+	ctx.Set(fiber.HeaderContentType, attachment.ContentType)
+	ctx.Set(fiber.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}))
+	ctx.Set("X-Content-Type-Options", "nosniff")
+	return ctx.Status(fiber.StatusOK).SendStream(content, int(attachment.Size))
+}
+
+// UploadAttachment stores a multipart file and attaches it to a task, comment, project chat or avatar
+func (h *attachmentHandler) UploadAttachment(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	ownerID, err := strconv.ParseUint(ctx.FormValue("owner_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid owner ID",
+		})
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "A file is required",
+		})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read the file",
+		})
+	}
+	defer file.Close()
+
+	attachment, err := h.repo.UploadAttachment(&repositories.AttachmentUpload{
+		Name:         fileHeader.Filename,
+		DeclaredType: fileHeader.Header.Get(fiber.HeaderContentType),
+		Body:         file,
+		OwnerType:    models.AttachmentOwner(ctx.FormValue("owner_type")),
+		OwnerID:      uint(ownerID),
+	}, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to upload attachment: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(attachment)
+}
+
+// GetAttachment returns the metadata of an attachment
+func (h *attachmentHandler) GetAttachment(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	attachmentID, err := strconv.ParseUint(ctx.Params("attachment_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid attachment ID",
+		})
+	}
+
+	attachment, err := h.repo.GetAttachment(uint(attachmentID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(attachment)
+}
+
+// GetOwnerAttachments lists the attachments of ?owner_type=&owner_id=
+func (h *attachmentHandler) GetOwnerAttachments(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	ownerID, err := strconv.ParseUint(ctx.Query("owner_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid owner ID",
+		})
+	}
+
+	attachments, err := h.repo.GetOwnerAttachments(models.AttachmentOwner(ctx.Query("owner_type")), uint(ownerID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(attachments)
+}
+
+// GetTaskAttachments lists the files attached to a task
+func (h *attachmentHandler) GetTaskAttachments(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	attachments, err := h.repo.GetOwnerAttachments(models.AttachmentTask, uint(taskID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(attachments)
+}
+
+// GetAttachmentContent streams an attachment to an authenticated user
+func (h *attachmentHandler) GetAttachmentContent(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	attachmentID, err := strconv.ParseUint(ctx.Params("attachment_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid attachment ID",
+		})
+	}
+
+	attachment, content, err := h.repo.OpenAttachment(uint(attachmentID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return sendContent(ctx, attachment, content)
+}
+
+// GetSignedURL returns a short lived download link that needs no token
+func (h *attachmentHandler) GetSignedURL(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	attachmentID, err := strconv.ParseUint(ctx.Params("attachment_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid attachment ID",
+		})
+	}
+
+	url, expiresAt, err := h.repo.SignedURL(uint(attachmentID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"url":        url,
+		"expires_at": expiresAt,
+	})
+}
+
+// GetSignedContent streams an attachment through a signed link
+func (h *attachmentHandler) GetSignedContent(ctx *fiber.Ctx) error {
+	attachmentID, err := strconv.ParseUint(ctx.Params("attachment_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid attachment ID",
+		})
+	}
+	expires, err := strconv.ParseInt(ctx.Query("expires"), 10, 64)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid expiry",
+		})
+	}
+
+	attachment, content, err := h.repo.OpenSigned(uint(attachmentID), expires, ctx.Query("signature"))
+	if errors.Is(err, repositories.ErrInvalidSignature) {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return sendContent(ctx, attachment, content)
+}
+
+// DeleteAttachment removes an attachment, the stored file goes when nothing else uses it
+func (h *attachmentHandler) DeleteAttachment(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	attachmentID, err := strconv.ParseUint(ctx.Params("attachment_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid attachment ID",
+		})
+	}
+
+	deletedAttachmentID, err := h.repo.DeleteAttachment(uint(attachmentID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to delete attachment: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deleted_attachment_id": deletedAttachmentID,
+	})
+}
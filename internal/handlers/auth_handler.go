@@ -4,6 +4,9 @@ import (
 	"fmt"
 	basicrepo "mizito/internal/repositories/auth/basic"
 	bearerrepo "mizito/internal/repositories/auth/bearer"
+	lockoutrepo "mizito/internal/repositories/auth/lockout"
+	"mizito/internal/repositories/utils"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,20 +17,33 @@ type AuthHandler interface {
 	Refresh(ctx *fiber.Ctx) error
 	Authorize(ctx *fiber.Ctx) error
 	Logout(ctx *fiber.Ctx) error
+	Unlock(ctx *fiber.Ctx) error
+	AdminUnlock(ctx *fiber.Ctx) error
 }
 
 type authHandler struct {
-	jwtRepo   bearerrepo.BearerRepository
-	basicRepo basicrepo.BasicRepository
+	jwtRepo        bearerrepo.BearerRepository
+	basicRepo      basicrepo.BasicRepository
+	lockoutRepo    lockoutrepo.LockoutRepository
+	permissionRepo utils.PermissionRepository
 }
 
-func NewAuthHandler(jwtRepo bearerrepo.BearerRepository, basicRepo basicrepo.BasicRepository) AuthHandler {
+func NewAuthHandler(jwtRepo bearerrepo.BearerRepository, basicRepo basicrepo.BasicRepository, lockoutRepo lockoutrepo.LockoutRepository, permissionRepo utils.PermissionRepository) AuthHandler {
 	return &authHandler{
-		jwtRepo:   jwtRepo,
-		basicRepo: basicRepo,
+		jwtRepo:        jwtRepo,
+		basicRepo:      basicRepo,
+		lockoutRepo:    lockoutRepo,
+		permissionRepo: permissionRepo,
 	}
 }
 
+func tooManyAttempts(ctx *fiber.Ctx, retryAfter time.Duration) error {
+	ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(retryAfter.Seconds())+1))
+	return ctx.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error": fmt.Sprintf("Too many failed login attempts, try again in %s", retryAfter.Round(time.Second)),
+	})
+}
+
 func (ah *authHandler) Login(ctx *fiber.Ctx) error {
 	var credentials struct {
 		Username string `json:"username"`
@@ -38,6 +54,17 @@ func (ah *authHandler) Login(ctx *fiber.Ctx) error {
 			"error": "Invalid request body",
 		})
 	}
+
+	remaining, err := ah.lockoutRepo.CheckLocked(credentials.Username, ctx.IP())
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Authentication failed",
+		})
+	}
+	if remaining > 0 {
+		return tooManyAttempts(ctx, remaining)
+	}
+
 	authenticated, userID, err := ah.basicRepo.AuthenticateUser(credentials.Username, credentials.Password)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -45,10 +72,24 @@ func (ah *authHandler) Login(ctx *fiber.Ctx) error {
 		})
 	}
 	if !authenticated {
+		lockedFor, err := ah.lockoutRepo.RegisterFailure(credentials.Username, ctx.IP())
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Authentication failed",
+			})
+		}
+		if lockedFor > 0 {
+			return tooManyAttempts(ctx, lockedFor)
+		}
 		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Invalid username or password",
 		})
 	}
+	if err := ah.lockoutRepo.ResetFailures(credentials.Username); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Authentication failed",
+		})
+	}
 	token, refreshToken, err := ah.jwtRepo.GenerateTokens(userID, credentials.Username)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -160,3 +201,52 @@ func (ah *authHandler) Logout(ctx *fiber.Ctx) error {
 		"message": "Successfully logged out",
 	})
 }
+
+// Unlock lifts a lockout using the token from the email sent when the account got locked
+func (ah *authHandler) Unlock(ctx *fiber.Ctx) error {
+	token := ctx.Query("token")
+	if token == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unlock token is required",
+		})
+	}
+
+	username, err := ah.lockoutRepo.UnlockWithToken(token)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Account unlocked successfully",
+		"username": username,
+	})
+}
+
+// AdminUnlock lets a system admin lift the lockout of any username
+func (ah *authHandler) AdminUnlock(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	if !ah.permissionRepo.CheckUserIsSystemAdmin(requestUserID) {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "only admins can unlock accounts",
+		})
+	}
+
+	username := ctx.Params("username")
+	if username == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "username is required",
+		})
+	}
+
+	if err := ah.lockoutRepo.Unlock(username, requestUserID); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Account unlocked successfully",
+	})
+}
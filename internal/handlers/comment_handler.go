@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"mizito/pkg/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CommentHandler interface {
+	GetTaskComments(ctx *fiber.Ctx) error
+	CreateComment(ctx *fiber.Ctx) error
+	UpdateComment(ctx *fiber.Ctx) error
+	DeleteComment(ctx *fiber.Ctx) error
+	GetCommentHistory(ctx *fiber.Ctx) error
+}
+
+type commentHandler struct {
+	repo repositories.CommentRepository
+}
+
+func NewCommentHandler(postgreSql *database.DatabaseHandler, redis *database.RedisHandler) CommentHandler {
+	repo := repositories.NewCommentRepository(postgreSql, redis)
+	return &commentHandler{
+		repo: repo,
+	}
+}
+
+// GetTaskComments returns the comment thread of a task, oldest first
+func (h *commentHandler) GetTaskComments(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	comments, err := h.repo.GetTaskComments(uint(taskID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(comments)
+}
+
+// CreateComment posts a markdown comment on a task
+func (h *commentHandler) CreateComment(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	var comment models.TaskComment
+	if err := ctx.BodyParser(&comment); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	comment.TaskID = uint(taskID)
+
+	createdComment, err := h.repo.CreateComment(&comment, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to create comment: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(createdComment)
+}
+
+// UpdateComment edits a comment, the previous body is kept in its history
+func (h *commentHandler) UpdateComment(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	commentID, err := strconv.ParseUint(ctx.Params("comment_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid comment ID",
+		})
+	}
+
+	var payload struct {
+		Body        string                     `json:"body"`
+		Attachments []models.CommentAttachment `json:"attachments"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	comment, err := h.repo.UpdateComment(uint(commentID), payload.Body, payload.Attachments, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to update comment: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(comment)
+}
+
+// DeleteComment deletes a comment, its history stays available
+func (h *commentHandler) DeleteComment(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	commentID, err := strconv.ParseUint(ctx.Params("comment_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid comment ID",
+		})
+	}
+
+	deletedCommentID, err := h.repo.DeleteComment(uint(commentID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to delete comment: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deleted_comment_id": deletedCommentID,
+	})
+}
+
+// GetCommentHistory returns the previous bodies of a comment
+func (h *commentHandler) GetCommentHistory(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	commentID, err := strconv.ParseUint(ctx.Params("comment_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid comment ID",
+		})
+	}
+
+	history, err := h.repo.GetCommentHistory(uint(commentID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(history)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"mizito/pkg/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CustomFieldHandler interface {
+	GetProjectFields(ctx *fiber.Ctx) error
+	CreateField(ctx *fiber.Ctx) error
+	UpdateField(ctx *fiber.Ctx) error
+	DeleteField(ctx *fiber.Ctx) error
+	GetTaskFieldValues(ctx *fiber.Ctx) error
+	SetTaskFieldValues(ctx *fiber.Ctx) error
+}
+
+type customFieldHandler struct {
+	repo repositories.CustomFieldRepository
+}
+
+func NewCustomFieldHandler(postgreSql *database.DatabaseHandler) CustomFieldHandler {
+	repo := repositories.NewCustomFieldRepository(postgreSql)
+	return &customFieldHandler{
+		repo: repo,
+	}
+}
+
+// GetProjectFields returns the custom fields of a project in display order
+func (h *customFieldHandler) GetProjectFields(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	fields, err := h.repo.GetProjectFields(uint(projectID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fields)
+}
+
+// CreateField adds a text, number, date, select or user field to the tasks of a project
+func (h *customFieldHandler) CreateField(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	var field models.CustomField
+	if err := ctx.BodyParser(&field); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	field.ProjectID = uint(projectID)
+
+	fieldID, err := h.repo.CreateField(&field, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to create field: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"field_id": fieldID,
+	})
+}
+
+// UpdateField renames, reorders or changes the options of a custom field
+func (h *customFieldHandler) UpdateField(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+	fieldID, err := strconv.ParseUint(ctx.Params("field_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid field ID",
+		})
+	}
+
+	var field models.CustomField
+	if err := ctx.BodyParser(&field); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	field.ID = uint(fieldID)
+	field.ProjectID = uint(projectID)
+
+	updatedFieldID, err := h.repo.UpdateField(&field, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to update field: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"field_id": updatedFieldID,
+	})
+}
+
+// DeleteField removes a custom field and its values from every task
+func (h *customFieldHandler) DeleteField(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+	fieldID, err := strconv.ParseUint(ctx.Params("field_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid field ID",
+		})
+	}
+
+	deletedFieldID, err := h.repo.DeleteField(uint(projectID), uint(fieldID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to delete field: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deleted_field_id": deletedFieldID,
+	})
+}
+
+// GetTaskFieldValues returns the custom field values of a task
+func (h *customFieldHandler) GetTaskFieldValues(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	values, err := h.repo.GetTaskFieldValues(uint(taskID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(values)
+}
+
+// SetTaskFieldValues sets the values given as {"values": {"<field_id>": value}}, null clears a value
+func (h *customFieldHandler) SetTaskFieldValues(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	var payload struct {
+		Values map[string]json.RawMessage `json:"values"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	values := make(map[uint]json.RawMessage)
+	for key, value := range payload.Values {
+		fieldID, err := strconv.ParseUint(key, 10, 32)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID " + key,
+			})
+		}
+		values[uint(fieldID)] = value
+	}
+
+	fieldValues, err := h.repo.SetTaskFieldValues(uint(taskID), values, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to set field values: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fieldValues)
+}
@@ -4,14 +4,15 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"mizito/internal/database"
 	"mizito/internal/repositories"
+	"time"
 )
 
 type DashboardHandler struct {
 	Repo repositories.DashboardRepository
 }
 
-func NewDashboardHandler(postgreSql *database.DatabaseHandler) *DashboardHandler {
-	repo := repositories.NewDashboardRepository(postgreSql)
+func NewDashboardHandler(postgreSql *database.DatabaseHandler, redis *database.RedisHandler, cacheTTL time.Duration) *DashboardHandler {
+	repo := repositories.NewDashboardRepository(postgreSql, redis, cacheTTL)
 	return &DashboardHandler{Repo: repo}
 }
 
@@ -22,22 +23,10 @@ func (h *DashboardHandler) GetDashboardDetails(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
 	}
 
-	// Call the repository method
-	username, profilePicture, todoCount, coworkers, todoList, projectList, err := h.Repo.GetDashboardDetails(requestUserID)
+	dashboard, err := h.Repo.GetDashboard(requestUserID)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	// Construct the response
-	response := fiber.Map{
-		"username":        username,
-		"profile_picture": profilePicture,
-		"todo_count":      todoCount,
-		"coworkers":       coworkers,
-		"todo_list":       todoList,
-		"project_list":    projectList,
-	}
-
-	// Return the response
-	return ctx.Status(fiber.StatusOK).JSON(response)
+	return ctx.Status(fiber.StatusOK).JSON(dashboard)
 }
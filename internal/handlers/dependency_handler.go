@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DependencyHandler interface {
+	AddDependency(ctx *fiber.Ctx) error
+	RemoveDependency(ctx *fiber.Ctx) error
+	GetTaskDependencies(ctx *fiber.Ctx) error
+	GetDependencyGraph(ctx *fiber.Ctx) error
+}
+
+type dependencyHandler struct {
+	repo repositories.DependencyRepository
+}
+
+func NewDependencyHandler(postgreSql *database.DatabaseHandler) DependencyHandler {
+	repo := repositories.NewDependencyRepository(postgreSql)
+	return &dependencyHandler{
+		repo: repo,
+	}
+}
+
+// AddDependency marks the task as blocked by another task of the project
+func (h *dependencyHandler) AddDependency(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	var payload struct {
+		BlockerID uint `json:"blocker_id"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil || payload.BlockerID == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Blocker ID is required",
+		})
+	}
+
+	dependency, err := h.repo.WithRequest(requestInfo(ctx)).AddDependency(payload.BlockerID, uint(taskID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to add dependency: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(dependency)
+}
+
+// RemoveDependency unblocks the task from one of its blockers
+func (h *dependencyHandler) RemoveDependency(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+	blockerID, err := strconv.ParseUint(ctx.Params("blocker_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid blocker ID",
+		})
+	}
+
+	if err := h.repo.WithRequest(requestInfo(ctx)).RemoveDependency(uint(blockerID), uint(taskID), requestUserID); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to remove dependency: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Dependency removed successfully",
+	})
+}
+
+// GetTaskDependencies returns the tasks blocking the task and the tasks it blocks
+func (h *dependencyHandler) GetTaskDependencies(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	dependencies, err := h.repo.GetTaskDependencies(uint(taskID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(dependencies)
+}
+
+// GetDependencyGraph returns the dependency graph of a project with its critical path
+func (h *dependencyHandler) GetDependencyGraph(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	graph, err := h.repo.GetDependencyGraph(uint(projectID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(graph)
+}
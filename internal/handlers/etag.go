@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"mizito/internal/repositories"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errInvalidIfMatch = errors.New("If-Match doesn't match a version of the record")
+
+// setETag sends the version of a record as its ETag, clients send it back in If-Match to update it
+func setETag(ctx *fiber.Ctx, version uint) {
+	ctx.Set(fiber.HeaderETag, strconv.Quote(strconv.FormatUint(uint64(version), 10)))
+}
+
+// ifMatchVersion returns the version the client read from the If-Match header, 0 when it sent
+// none or "*" so the update isn't checked
+func ifMatchVersion(ctx *fiber.Ctx) (uint, error) {
+	value := strings.TrimSpace(ctx.Get(fiber.HeaderIfMatch))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+	value = strings.TrimPrefix(value, "W/")
+	unquoted, err := strconv.Unquote(value)
+	if err != nil {
+		return 0, errInvalidIfMatch
+	}
+	version, err := strconv.ParseUint(unquoted, 10, 32)
+	if err != nil || version == 0 {
+		return 0, errInvalidIfMatch
+	}
+	return uint(version), nil
+}
+
+// isVersionConflict reports whether an update failed because the client's version is stale
+func isVersionConflict(err error) bool {
+	return errors.Is(err, errInvalidIfMatch) || errors.Is(err, repositories.ErrVersionConflict)
+}
+
+// preconditionFailed answers an update whose If-Match doesn't match the stored version
+func preconditionFailed(ctx *fiber.Ctx, err error) error {
+	return ctx.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": err.Error()})
+}
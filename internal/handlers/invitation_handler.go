@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/env"
+	"mizito/internal/mailer"
+	"mizito/internal/repositories"
+	"mizito/pkg/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type InvitationHandler interface {
+	InviteToTeam(ctx *fiber.Ctx) error
+	GetTeamInvitations(ctx *fiber.Ctx) error
+	RevokeInvitation(ctx *fiber.Ctx) error
+	GetMyInvitations(ctx *fiber.Ctx) error
+	AcceptInvitation(ctx *fiber.Ctx) error
+	DeclineInvitation(ctx *fiber.Ctx) error
+}
+
+type invitationHandler struct {
+	repo   repositories.InvitationRepository
+	mailer mailer.Mailer
+	cfg    *env.Config
+}
+
+func NewInvitationHandler(postgreSql *database.DatabaseHandler, mailer mailer.Mailer, cfg *env.Config) InvitationHandler {
+	repo := repositories.NewInvitationRepository(postgreSql)
+	return &invitationHandler{
+		repo:   repo,
+		mailer: mailer,
+		cfg:    cfg,
+	}
+}
+
+// InviteToTeam invites users by username or email to join a team with a specified role
+func (h *invitationHandler) InviteToTeam(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	var payload struct {
+		Usernames []string    `json:"usernames"`
+		Emails    []string    `json:"emails"`
+		Role      models.Role `json:"role" validate:"required"`
+	}
+
+	// Parse request body
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validate payload
+	if (len(payload.Usernames) == 0 && len(payload.Emails) == 0) || payload.Role == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Usernames or emails and role are required",
+		})
+	}
+
+	invitations, err := h.repo.InviteToTeam(uint(teamID), payload.Usernames, payload.Emails, payload.Role, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to invite users to team: %v", err),
+		})
+	}
+
+	for _, invitation := range invitations {
+		h.sendInvitationEmail(&invitation)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(invitations)
+}
+
+func (h *invitationHandler) sendInvitationEmail(invitation *models.TeamInvitation) {
+	var body string
+	if invitation.InviteeID != nil {
+		body = fmt.Sprintf(
+			"You have been invited to join a team on mizito as %s.\nReview your invitations at %s/invitations\n",
+			invitation.Role, h.cfg.AppBaseURL,
+		)
+	} else {
+		body = fmt.Sprintf(
+			"You have been invited to join a team on mizito as %s.\nSign up with this email address at %s/user to accept it.\n",
+			invitation.Role, h.cfg.AppBaseURL,
+		)
+	}
+
+	// the invitation is stored already, a failed email shouldn't fail the request
+	if err := h.mailer.Send(invitation.Email, "You have been invited to a mizito team", body); err != nil {
+		fmt.Printf("failed to send invitation email, err: %s\n", err.Error())
+	}
+}
+
+// GetTeamInvitations lists the pending invitations of a team
+func (h *invitationHandler) GetTeamInvitations(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	invitations, err := h.repo.GetPendingInvitations(uint(teamID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(invitations)
+}
+
+// RevokeInvitation revokes a pending invitation of a team
+func (h *invitationHandler) RevokeInvitation(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+	invitationID, err := strconv.ParseUint(ctx.Params("invitation_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid invitation ID",
+		})
+	}
+
+	if err := h.repo.RevokeInvitation(uint(teamID), uint(invitationID), requestUserID); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to revoke invitation: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Invitation revoked successfully",
+	})
+}
+
+// GetMyInvitations lists the pending invitations addressed to the authenticated user
+func (h *invitationHandler) GetMyInvitations(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+
+	invitations, err := h.repo.GetUserInvitations(requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve invitations",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(invitations)
+}
+
+// AcceptInvitation adds the authenticated user to the team of the invitation
+func (h *invitationHandler) AcceptInvitation(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	invitationID, err := strconv.ParseUint(ctx.Params("invitation_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid invitation ID",
+		})
+	}
+
+	teamID, err := h.repo.WithRequest(requestInfo(ctx)).AcceptInvitation(uint(invitationID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to accept invitation: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"team_id": teamID,
+	})
+}
+
+// DeclineInvitation declines an invitation addressed to the authenticated user
+func (h *invitationHandler) DeclineInvitation(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	invitationID, err := strconv.ParseUint(ctx.Params("invitation_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid invitation ID",
+		})
+	}
+
+	if err := h.repo.DeclineInvitation(uint(invitationID), requestUserID); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to decline invitation: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Invitation declined",
+	})
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"mizito/pkg/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LabelHandler interface {
+	GetProjectLabels(ctx *fiber.Ctx) error
+	CreateLabel(ctx *fiber.Ctx) error
+	UpdateLabel(ctx *fiber.Ctx) error
+	DeleteLabel(ctx *fiber.Ctx) error
+	SetTaskLabels(ctx *fiber.Ctx) error
+}
+
+type labelHandler struct {
+	repo repositories.LabelRepository
+}
+
+func NewLabelHandler(postgreSql *database.DatabaseHandler) LabelHandler {
+	repo := repositories.NewLabelRepository(postgreSql)
+	return &labelHandler{
+		repo: repo,
+	}
+}
+
+// GetProjectLabels returns the labels defined by a project
+func (h *labelHandler) GetProjectLabels(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	labels, err := h.repo.GetProjectLabels(uint(projectID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(labels)
+}
+
+// CreateLabel adds a label with a name and a #rrggbb color to a project
+func (h *labelHandler) CreateLabel(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	var label models.Label
+	if err := ctx.BodyParser(&label); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	label.ProjectID = uint(projectID)
+
+	labelID, err := h.repo.CreateLabel(&label, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to create label: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"label_id": labelID,
+	})
+}
+
+// UpdateLabel renames or recolors a label
+func (h *labelHandler) UpdateLabel(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+	labelID, err := strconv.ParseUint(ctx.Params("label_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid label ID",
+		})
+	}
+
+	var label models.Label
+	if err := ctx.BodyParser(&label); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	label.ID = uint(labelID)
+	label.ProjectID = uint(projectID)
+
+	updatedLabelID, err := h.repo.UpdateLabel(&label, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to update label: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"label_id": updatedLabelID,
+	})
+}
+
+// DeleteLabel removes a label from the project and from all of its tasks
+func (h *labelHandler) DeleteLabel(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+	labelID, err := strconv.ParseUint(ctx.Params("label_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid label ID",
+		})
+	}
+
+	deletedLabelID, err := h.repo.DeleteLabel(uint(projectID), uint(labelID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to delete label: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deleted_label_id": deletedLabelID,
+	})
+}
+
+// SetTaskLabels replaces the labels of a task with the given label ids
+func (h *labelHandler) SetTaskLabels(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	var payload struct {
+		LabelIDs []uint `json:"label_ids"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	labels, err := h.repo.SetTaskLabels(uint(taskID), payload.LabelIDs, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to set task labels: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(labels)
+}
@@ -12,6 +12,9 @@ type ProjectCrudHandler interface {
 	GetProjectByID(ctx *fiber.Ctx) error
 	UpdateProject(ctx *fiber.Ctx) error
 	DeleteProject(ctx *fiber.Ctx) error
+	RestoreProject(ctx *fiber.Ctx) error
+	ArchiveProject(ctx *fiber.Ctx) error
+	UnarchiveProject(ctx *fiber.Ctx) error
 	CreateProject(ctx *fiber.Ctx) error
 }
 
@@ -31,10 +34,11 @@ type projectHandler struct {
 	repository repositories.ProjectRepository
 }
 
+// GetProjectsByUser lists the projects of the user's teams, archived ones only with include_archived=true
 func (pr *projectHandler) GetProjectsByUser(ctx *fiber.Ctx) error {
 	userID := ctx.Locals("userID").(uint)
 
-	projects, repoErr := pr.repository.GetProjectsByUser(userID)
+	projects, repoErr := pr.repository.GetProjectsByUser(userID, ctx.QueryBool("include_archived"))
 	if repoErr != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": repoErr.Error(),
@@ -79,7 +83,7 @@ func (pr *projectHandler) CreateProject(ctx *fiber.Ctx) error {
 		})
 	}
 
-	projectID, repoErr := pr.repository.CreateProject(project, userID)
+	projectID, repoErr := pr.repository.WithRequest(requestInfo(ctx)).CreateProject(project, userID)
 	if repoErr != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": repoErr.Error(),
@@ -114,11 +118,14 @@ func (pr *projectHandler) GetProjectByID(ctx *fiber.Ctx) error {
 		})
 	}
 
+	setETag(ctx, project.Version)
 	return ctx.Status(fiber.StatusOK).JSON(project)
 }
 
+// UpdateProject sets the fields present in the body on a project, with If-Match the update
+// fails with 412 when the project changed since that version
 func (pr *projectHandler) UpdateProject(ctx *fiber.Ctx) error {
-	projectID := ctx.Params("projectID")
+	projectID := ctx.Params("project_id")
 	requestUserID := ctx.Locals("userID").(uint)
 	if projectID == "" {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -133,27 +140,36 @@ func (pr *projectHandler) UpdateProject(ctx *fiber.Ctx) error {
 		})
 	}
 
-	updatedProject := new(models.Project)
-	if err := ctx.BodyParser(updatedProject); err != nil {
+	patch := new(repositories.ProjectPatch)
+	if err := ctx.BodyParser(patch); err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid request body",
 		})
 	}
 
-	_, repoErr := pr.repository.UpdateProject(uint(parsedProjectID), updatedProject, requestUserID)
+	expectedVersion, err := ifMatchVersion(ctx)
+	if err != nil {
+		return preconditionFailed(ctx, err)
+	}
+
+	version, repoErr := pr.repository.WithRequest(requestInfo(ctx)).UpdateProject(uint(parsedProjectID), patch, expectedVersion, requestUserID)
 	if repoErr != nil {
+		if isVersionConflict(repoErr) {
+			return preconditionFailed(ctx, repoErr)
+		}
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": repoErr.Error(),
 		})
 	}
 
+	setETag(ctx, version)
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Project updated successfully",
 	})
 }
 
 func (pr *projectHandler) DeleteProject(ctx *fiber.Ctx) error {
-	projectID := ctx.Params("projectID")
+	projectID := ctx.Params("project_id")
 	requestUserID := ctx.Locals("userID").(uint)
 
 	if projectID == "" {
@@ -169,7 +185,7 @@ func (pr *projectHandler) DeleteProject(ctx *fiber.Ctx) error {
 		})
 	}
 
-	_, repoErr := pr.repository.DeleteProject(uint(parsedProjectID), requestUserID)
+	_, repoErr := pr.repository.WithRequest(requestInfo(ctx)).DeleteProject(uint(parsedProjectID), requestUserID)
 	if repoErr != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": repoErr.Error(),
@@ -181,9 +197,79 @@ func (pr *projectHandler) DeleteProject(ctx *fiber.Ctx) error {
 	})
 }
 
+// RestoreProject brings a project back from the trash with its tasks
+func (pr *projectHandler) RestoreProject(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid projectID format",
+		})
+	}
+
+	restoredProjectID, err := pr.repository.WithRequest(requestInfo(ctx)).RestoreProject(uint(projectID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":    "Project restored successfully",
+		"project_id": restoredProjectID,
+	})
+}
+
+// ArchiveProject makes a project read-only and hides it from the listings
+func (pr *projectHandler) ArchiveProject(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid projectID format",
+		})
+	}
+
+	archivedProjectID, err := pr.repository.WithRequest(requestInfo(ctx)).ArchiveProject(uint(projectID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":    "Project archived successfully",
+		"project_id": archivedProjectID,
+	})
+}
+
+// UnarchiveProject makes an archived project writable again
+func (pr *projectHandler) UnarchiveProject(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid projectID format",
+		})
+	}
+
+	unarchivedProjectID, err := pr.repository.WithRequest(requestInfo(ctx)).UnarchiveProject(uint(projectID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":    "Project unarchived successfully",
+		"project_id": unarchivedProjectID,
+	})
+}
+
 func (pr *projectHandler) AddUserToProject(ctx *fiber.Ctx) error {
 	type RequestBody struct {
-		UserID uint `json:"userID"`
+		UserID uint        `json:"userID"`
+		Role   models.Role `json:"role"`
 	}
 	requestUserID := ctx.Locals("userID").(uint)
 
@@ -208,7 +294,7 @@ func (pr *projectHandler) AddUserToProject(ctx *fiber.Ctx) error {
 		})
 	}
 
-	repoErr := pr.repository.AddUserToProject(uint(projectID), userID, requestUserID)
+	repoErr := pr.repository.WithRequest(requestInfo(ctx)).AddUserToProject(uint(projectID), userID, requestBody.Role, requestUserID)
 	if repoErr != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": repoErr.Error(),
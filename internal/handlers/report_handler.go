@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"mizito/pkg/models"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReportHandler interface {
+	CreateReport(ctx *fiber.Ctx) error
+	GetTaskReports(ctx *fiber.Ctx) error
+	UpdateReport(ctx *fiber.Ctx) error
+	DeleteReport(ctx *fiber.Ctx) error
+	GetProjectStandup(ctx *fiber.Ctx) error
+	GetProjectReportSummary(ctx *fiber.Ctx) error
+}
+
+type reportHandler struct {
+	repo repositories.ReportRepository
+}
+
+func NewReportHandler(postgreSql *database.DatabaseHandler) ReportHandler {
+	repo := repositories.NewReportRepository(postgreSql)
+	return &reportHandler{
+		repo: repo,
+	}
+}
+
+// CreateReport files the request user's report on a task
+func (h *reportHandler) CreateReport(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	var report models.Report
+	if err := ctx.BodyParser(&report); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	report.TaskID = uint(taskID)
+
+	reportID, err := h.repo.CreateReport(&report, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to create report: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"report_id": reportID,
+	})
+}
+
+// GetTaskReports lists the reports of a task, newest day first
+func (h *reportHandler) GetTaskReports(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	reports, err := h.repo.GetTaskReports(uint(taskID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(reports)
+}
+
+// UpdateReport edits a report of the request user
+func (h *reportHandler) UpdateReport(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	reportID, err := strconv.ParseUint(ctx.Params("report_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid report ID",
+		})
+	}
+
+	var report models.Report
+	if err := ctx.BodyParser(&report); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	report.ID = uint(reportID)
+
+	updatedReportID, err := h.repo.UpdateReport(&report, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to update report: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"report_id": updatedReportID,
+	})
+}
+
+// DeleteReport deletes a report of the request user
+func (h *reportHandler) DeleteReport(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	reportID, err := strconv.ParseUint(ctx.Params("report_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid report ID",
+		})
+	}
+
+	deletedReportID, err := h.repo.DeleteReport(uint(reportID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to delete report: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deleted_report_id": deletedReportID,
+	})
+}
+
+// GetProjectStandup returns the reports of a project for one day grouped by member, today by default
+func (h *reportHandler) GetProjectStandup(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	date := time.Now()
+	if value := ctx.Query("date"); value != "" {
+		date, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid date, expected YYYY-MM-DD",
+			})
+		}
+	}
+
+	standup, err := h.repo.GetProjectStandup(uint(projectID), date, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(standup)
+}
+
+// GetProjectReportSummary collects each member's reports in a project for a date range
+func (h *reportHandler) GetProjectReportSummary(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+	from, to, err := parseDateRange(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	summary, err := h.repo.GetProjectReportSummary(uint(projectID), from, to, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"from":    from,
+		"to":      to,
+		"members": summary,
+	})
+}
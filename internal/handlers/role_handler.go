@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"mizito/pkg/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RoleHandler interface {
+	GetPermissions(ctx *fiber.Ctx) error
+	GetTeamRoles(ctx *fiber.Ctx) error
+	CreateRole(ctx *fiber.Ctx) error
+	UpdateRole(ctx *fiber.Ctx) error
+	DeleteRole(ctx *fiber.Ctx) error
+	AssignTeamRole(ctx *fiber.Ctx) error
+	AssignProjectRole(ctx *fiber.Ctx) error
+}
+
+type roleHandler struct {
+	repo repositories.RoleRepository
+}
+
+func NewRoleHandler(postgreSql *database.DatabaseHandler) RoleHandler {
+	repo := repositories.NewRoleRepository(postgreSql)
+	return &roleHandler{
+		repo: repo,
+	}
+}
+
+// GetPermissions lists every permission that can be granted to a custom role
+func (h *roleHandler) GetPermissions(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusOK).JSON(models.AllPermissions)
+}
+
+// GetTeamRoles lists the built-in and custom roles of a team
+func (h *roleHandler) GetTeamRoles(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	roles, err := h.repo.GetTeamRoles(uint(teamID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(roles)
+}
+
+// CreateRole creates a custom role in a team
+func (h *roleHandler) CreateRole(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	var payload struct {
+		Name        models.Role         `json:"name"`
+		Permissions []models.Permission `json:"permissions"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	roleID, err := h.repo.CreateRole(uint(teamID), payload.Name, payload.Permissions, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to create role: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"role_id": roleID,
+	})
+}
+
+// UpdateRole replaces the permissions of a custom role
+func (h *roleHandler) UpdateRole(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+	roleID, err := strconv.ParseUint(ctx.Params("role_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid role ID",
+		})
+	}
+
+	var payload struct {
+		Permissions []models.Permission `json:"permissions"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	updatedRoleID, err := h.repo.UpdateRole(uint(teamID), uint(roleID), payload.Permissions, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to update role: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"role_id": updatedRoleID,
+	})
+}
+
+// DeleteRole deletes a custom role that isn't assigned to anyone
+func (h *roleHandler) DeleteRole(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+	roleID, err := strconv.ParseUint(ctx.Params("role_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid role ID",
+		})
+	}
+
+	deletedRoleID, err := h.repo.DeleteRole(uint(teamID), uint(roleID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to delete role: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deleted_role_id": deletedRoleID,
+	})
+}
+
+// AssignTeamRole changes the role of a team member
+func (h *roleHandler) AssignTeamRole(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+	userID, err := strconv.ParseUint(ctx.Params("user_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var payload struct {
+		Role models.Role `json:"role"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil || payload.Role == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Role is required",
+		})
+	}
+
+	if err := h.repo.WithRequest(requestInfo(ctx)).AssignTeamRole(uint(teamID), uint(userID), payload.Role, requestUserID); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to assign role: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Role assigned successfully",
+	})
+}
+
+// AssignProjectRole changes the role of a project member, an empty role falls back to the team role
+func (h *roleHandler) AssignProjectRole(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+	userID, err := strconv.ParseUint(ctx.Params("user_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var payload struct {
+		Role models.Role `json:"role"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.repo.WithRequest(requestInfo(ctx)).AssignProjectRole(uint(projectID), uint(userID), payload.Role, requestUserID); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to assign role: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Role assigned successfully",
+	})
+}
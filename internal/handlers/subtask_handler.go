@@ -61,7 +61,7 @@ func (sh *subtaskHandler) CreateSubtask(ctx *fiber.Ctx) error {
 
 	requestUserID := ctx.Locals("userID").(uint)
 
-	subtaskID, err := sh.repository.CreateSubtask(&subtask, requestUserID)
+	subtaskID, err := sh.repository.WithRequest(requestInfo(ctx)).CreateSubtask(&subtask, requestUserID)
 	if err != nil {
 		if err.Error() == "you don't have access to the project" {
 			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
@@ -105,21 +105,44 @@ func (sh *subtaskHandler) GetSubtaskByID(ctx *fiber.Ctx) error {
 		})
 	}
 
+	setETag(ctx, subtask.Version)
 	return ctx.Status(fiber.StatusOK).JSON(subtask)
 }
 
+// UpdateSubtask sets the fields present in the body on a subtask, with If-Match the update
+// fails with 412 when the subtask changed since that version
 func (sh *subtaskHandler) UpdateSubtask(ctx *fiber.Ctx) error {
-	var subtask models.Subtask
-	if err := ctx.BodyParser(&subtask); err != nil {
+	subtaskID, err := ctx.ParamsInt("subtask_id")
+	if err != nil || subtaskID <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid subtask ID",
+		})
+	}
+
+	var patch repositories.SubtaskPatch
+	if err := ctx.BodyParser(&patch); err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid request body",
 		})
 	}
 
+	expectedVersion, err := ifMatchVersion(ctx)
+	if err != nil {
+		return preconditionFailed(ctx, err)
+	}
+
 	requestUserID := ctx.Locals("userID").(uint)
 
-	subtaskID, err := sh.repository.UpdateSubtask(&subtask, requestUserID)
+	version, err := sh.repository.WithRequest(requestInfo(ctx)).UpdateSubtask(uint(subtaskID), &patch, expectedVersion, requestUserID)
 	if err != nil {
+		if isVersionConflict(err) {
+			return preconditionFailed(ctx, err)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Subtask not found",
+			})
+		}
 		if err.Error() == "you don't have access to the project" {
 			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error": err.Error(),
@@ -130,6 +153,7 @@ func (sh *subtaskHandler) UpdateSubtask(ctx *fiber.Ctx) error {
 		})
 	}
 
+	setETag(ctx, version)
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"subtask_id": subtaskID,
 	})
@@ -145,7 +169,7 @@ func (sh *subtaskHandler) DeleteSubtask(ctx *fiber.Ctx) error {
 
 	requestUserID := ctx.Locals("userID").(uint)
 
-	deletedID, err := sh.repository.DeleteSubtask(uint(subtaskID), requestUserID)
+	deletedID, err := sh.repository.WithRequest(requestInfo(ctx)).DeleteSubtask(uint(subtaskID), requestUserID)
 	if err != nil {
 		if err.Error() == "you don't have access to the project" {
 			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
@@ -17,18 +17,23 @@ type TaskHandler interface {
 	UpdateTask(ctx *fiber.Ctx) error
 	DeleteTask(ctx *fiber.Ctx) error
 	AssignTask(ctx *fiber.Ctx) error
+	RestoreTask(ctx *fiber.Ctx) error
+	BulkTasks(ctx *fiber.Ctx) error
 }
 
 type taskHandler struct {
 	repository repositories.TaskRepository
+	bulkRepo   repositories.BulkTaskRepository
 }
 
-func NewTaskHandler(db *database.DatabaseHandler) TaskHandler {
+func NewTaskHandler(db *database.DatabaseHandler, redis *database.RedisHandler) TaskHandler {
 	repo := repositories.NewTaskRepository(db)
-	return &taskHandler{repository: repo}
+	bulkRepo := repositories.NewBulkTaskRepository(db, redis)
+	return &taskHandler{repository: repo, bulkRepo: bulkRepo}
 }
 
-// GetTasksByProject fetches all tasks for a given project ID
+// GetTasksByProject returns a page of the tasks of a project, see parseTaskQuery for the query parameters.
+// the cursor of the next page is sent in the X-Next-Cursor header
 func (th *taskHandler) GetTasksByProject(ctx *fiber.Ctx) error {
 	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 64)
 	if err != nil {
@@ -37,12 +42,17 @@ func (th *taskHandler) GetTasksByProject(ctx *fiber.Ctx) error {
 
 	requestUserID := ctx.Locals("userID").(uint)
 
-	tasks, err := th.repository.GetTasksByProject(uint(projectID), requestUserID)
+	taskQuery, err := parseTaskQuery(ctx.Queries(), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	page, err := th.repository.GetTasksByProject(uint(projectID), taskQuery, requestUserID)
 	if err != nil {
 		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return ctx.JSON(tasks)
+	return sendTaskPage(ctx, page, taskQuery.SelectedFields())
 }
 
 // CreateTask creates a new task
@@ -54,7 +64,7 @@ func (th *taskHandler) CreateTask(ctx *fiber.Ctx) error {
 
 	requestUserID := ctx.Locals("userID").(uint)
 
-	taskID, err := th.repository.CreateTask(&task, requestUserID)
+	taskID, err := th.repository.WithRequest(requestInfo(ctx)).CreateTask(&task, requestUserID)
 	if err != nil {
 		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -79,24 +89,40 @@ func (th *taskHandler) GetTaskByID(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	setETag(ctx, task.Version)
 	return ctx.JSON(task)
 }
 
-// UpdateTask updates an existing task
+// UpdateTask sets the fields present in the body on a task, with If-Match the update fails with
+// 412 when the task changed since that version
 func (th *taskHandler) UpdateTask(ctx *fiber.Ctx) error {
-	var task models.Task
-	if err := ctx.BodyParser(&task); err != nil {
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 64)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid task ID"})
+	}
+
+	var patch repositories.TaskPatch
+	if err := ctx.BodyParser(&patch); err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
+	expectedVersion, err := ifMatchVersion(ctx)
+	if err != nil {
+		return preconditionFailed(ctx, err)
+	}
+
 	requestUserID := ctx.Locals("userID").(uint)
 
-	taskID, err := th.repository.UpdateTask(&task, requestUserID)
+	version, err := th.repository.WithRequest(requestInfo(ctx)).UpdateTask(uint(taskID), &patch, expectedVersion, requestUserID)
 	if err != nil {
+		if isVersionConflict(err) {
+			return preconditionFailed(ctx, err)
+		}
 		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return ctx.JSON(fiber.Map{"updated_task_id": taskID})
+	setETag(ctx, version)
+	return ctx.JSON(fiber.Map{"updated_task_id": uint(taskID)})
 }
 
 // DeleteTask deletes a task by its ID
@@ -108,7 +134,7 @@ func (th *taskHandler) DeleteTask(ctx *fiber.Ctx) error {
 
 	requestUserID := ctx.Locals("userID").(uint)
 
-	deletedTaskID, err := th.repository.DeleteTask(uint(taskID), requestUserID)
+	deletedTaskID, err := th.repository.WithRequest(requestInfo(ctx)).DeleteTask(uint(taskID), requestUserID)
 	if err != nil {
 		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -116,6 +142,51 @@ func (th *taskHandler) DeleteTask(ctx *fiber.Ctx) error {
 	return ctx.JSON(fiber.Map{"deleted_task_id": deletedTaskID})
 }
 
+// RestoreTask brings a task back from the trash
+func (th *taskHandler) RestoreTask(ctx *fiber.Ctx) error {
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 64)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid task ID"})
+	}
+
+	requestUserID := ctx.Locals("userID").(uint)
+
+	restoredTaskID, err := th.repository.WithRequest(requestInfo(ctx)).RestoreTask(uint(taskID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return ctx.JSON(fiber.Map{"restored_task_id": restoredTaskID})
+}
+
+// BulkTasks applies a batch of operations to many tasks and reports the result of every task,
+// 207 when some of them failed and 422 when nothing was applied
+func (th *taskHandler) BulkTasks(ctx *fiber.Ctx) error {
+	var request repositories.BulkTaskRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	requestUserID := ctx.Locals("userID").(uint)
+
+	results, err := th.bulkRepo.WithRequest(requestInfo(ctx)).ApplyBulk(&request, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	status := fiber.StatusOK
+	for _, result := range results.Results {
+		if result.Error != "" {
+			status = fiber.StatusMultiStatus
+			break
+		}
+	}
+	if !results.Applied && status != fiber.StatusOK {
+		status = fiber.StatusUnprocessableEntity
+	}
+	return ctx.Status(status).JSON(results)
+}
+
 func (th *taskHandler) AssignTask(ctx *fiber.Ctx) error {
 	type RequestBody struct {
 		UserID uint `json:"userID" validate:"required"`
@@ -136,7 +207,7 @@ func (th *taskHandler) AssignTask(ctx *fiber.Ctx) error {
 		})
 	}
 	var err error
-	err = th.repository.AssignTask(requestBody.UserID, requestBody.TaskID, requestUserID)
+	err = th.repository.WithRequest(requestInfo(ctx)).AssignTask(requestBody.UserID, requestBody.TaskID, requestUserID)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mizito/internal/repositories"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseIDList reads a comma separated list of ids, "me" stands for the requesting user
+func parseIDList(value string, requestUserID uint) ([]uint, error) {
+	var ids []uint
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "me" {
+			ids = append(ids, requestUserID)
+			continue
+		}
+		id, err := strconv.ParseUint(item, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %s", item)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+func parseIntParam(value string, name string) (*int, error) {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %s", name, value)
+	}
+	return &parsed, nil
+}
+
+func parseDueParam(value string, endOfDay bool, name string) (*time.Time, error) {
+	parsed, err := parseDateParam(value, endOfDay)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s date %s", name, value)
+	}
+	return &parsed, nil
+}
+
+// parseTaskFilter reads the filters of a task listing:
+// project=1,2 status=1,2 assignee=3,me priority_min=1 priority_max=5 due_before=2024-01-31 due_after=2024-01-01
+// q=text label=1,2 and custom field filters written as field.<id>=value, field.<id>.gte=value
+// and field.<id>.lte=value
+func parseTaskFilter(queries map[string]string, requestUserID uint) (repositories.TaskFilter, error) {
+	var filter repositories.TaskFilter
+	var err error
+	for key, value := range queries {
+		switch key {
+		case "project":
+			filter.ProjectIDs, err = parseIDList(value, requestUserID)
+		case "status":
+			filter.StatusIDs, err = parseIDList(value, requestUserID)
+		case "assignee":
+			filter.AssigneeIDs, err = parseIDList(value, requestUserID)
+		case "label":
+			filter.LabelIDs, err = parseIDList(value, requestUserID)
+		case "priority_min":
+			filter.PriorityMin, err = parseIntParam(value, key)
+		case "priority_max":
+			filter.PriorityMax, err = parseIntParam(value, key)
+		case "due_before":
+			filter.DueBefore, err = parseDueParam(value, false, key)
+		case "due_after":
+			// a plain date excludes the whole day
+			filter.DueAfter, err = parseDueParam(value, true, key)
+		case "q":
+			filter.Text = value
+		default:
+			if !strings.HasPrefix(key, "field.") {
+				continue
+			}
+			parts := strings.Split(strings.TrimPrefix(key, "field."), ".")
+			fieldID, parseErr := strconv.ParseUint(parts[0], 10, 32)
+			if parseErr != nil || len(parts) > 2 {
+				return filter, errors.New("invalid field filter " + key)
+			}
+			operator := repositories.FieldEquals
+			if len(parts) == 2 {
+				operator = repositories.FieldOperator(parts[1])
+			}
+			filter.Fields = append(filter.Fields, repositories.FieldFilter{FieldID: uint(fieldID), Operator: operator, Value: value})
+		}
+		if err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// parseTaskQuery reads the filters of parseTaskFilter with sort=-due_date, cursor=, limit=50,
+// fields=id,title,members and include=subtasks,labels. relations are only loaded when fields or
+// include names them
+func parseTaskQuery(queries map[string]string, requestUserID uint) (repositories.TaskQuery, error) {
+	filter, err := parseTaskFilter(queries, requestUserID)
+	if err != nil {
+		return repositories.TaskQuery{}, err
+	}
+
+	taskQuery := repositories.TaskQuery{
+		Filter: filter,
+		Sort:   queries["sort"],
+		Cursor: queries["cursor"],
+	}
+	if value := queries["limit"]; value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return taskQuery, fmt.Errorf("invalid limit %s", value)
+		}
+		taskQuery.Limit = limit
+	}
+	if value := queries["fields"]; value != "" {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if _, ok := repositories.TaskListFields[field]; !ok {
+				return taskQuery, fmt.Errorf("unknown task field %s", field)
+			}
+			taskQuery.Fields = append(taskQuery.Fields, field)
+		}
+	}
+	if value := queries["include"]; value != "" {
+		for _, relation := range strings.Split(value, ",") {
+			relation = strings.TrimSpace(relation)
+			if repositories.TaskListFields[relation].Relation == "" {
+				return taskQuery, fmt.Errorf("unknown task relation %s", relation)
+			}
+			taskQuery.Include = append(taskQuery.Include, relation)
+		}
+	}
+	return taskQuery, nil
+}
+
+// sendTaskPage writes a page of tasks with only the selected fields, the id is always included
+func sendTaskPage(ctx *fiber.Ctx, page *repositories.TaskPage, fields []string) error {
+	if page.NextCursor != "" {
+		ctx.Set("X-Next-Cursor", page.NextCursor)
+	}
+	if len(fields) == 0 {
+		return ctx.JSON(page.Tasks)
+	}
+
+	tasks := make([]map[string]json.RawMessage, 0, len(page.Tasks))
+	for _, task := range page.Tasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+		var full map[string]json.RawMessage
+		if err := json.Unmarshal(data, &full); err != nil {
+			return err
+		}
+
+		sparse := map[string]json.RawMessage{"ID": full["ID"]}
+		for _, field := range fields {
+			key := repositories.TaskListFields[field].Key
+			sparse[key] = full[key]
+		}
+		tasks = append(tasks, sparse)
+	}
+	return ctx.JSON(tasks)
+}
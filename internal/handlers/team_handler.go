@@ -14,30 +14,35 @@ type TeamHandler interface {
 	GetTeams(ctx *fiber.Ctx) error
 	GetTeamByID(ctx *fiber.Ctx) error
 	GetProjectsByTeam(ctx *fiber.Ctx) error
-	AddUsersToTeam(ctx *fiber.Ctx) error
 	DeleteUsersFromTeam(ctx *fiber.Ctx) error
 	CreateTeam(ctx *fiber.Ctx) error
 	UpdateTeam(ctx *fiber.Ctx) error
 	DeleteTeam(ctx *fiber.Ctx) error
+	RestoreTeam(ctx *fiber.Ctx) error
+	ArchiveTeam(ctx *fiber.Ctx) error
+	UnarchiveTeam(ctx *fiber.Ctx) error
 }
 
 type teamHandler struct {
-	repo repositories.TeamRepository
+	repo         repositories.TeamRepository
+	deletionRepo repositories.DeletionRepository
 }
 
-func NewTeamHandler(postgreSql *database.DatabaseHandler) TeamHandler {
+func NewTeamHandler(postgreSql *database.DatabaseHandler, deletionRepo repositories.DeletionRepository) TeamHandler {
 	repo := repositories.NewTeamRepository(postgreSql)
 	return &teamHandler{
-		repo: repo,
+		repo:         repo,
+		deletionRepo: deletionRepo,
 	}
 }
 
-// GetTeams retrieves all teams for the authenticated user
+// GetTeams retrieves all teams for the authenticated user, archived teams and projects
+// are only listed with include_archived=true
 func (h *teamHandler) GetTeams(ctx *fiber.Ctx) error {
 	// Assume userID is obtained from middleware (e.g., JWT)
 	userID := ctx.Locals("userID").(uint)
 
-	teams, err := h.repo.GetTeams(userID)
+	teams, err := h.repo.GetTeams(userID, ctx.QueryBool("include_archived"))
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to retrieve teams",
@@ -66,7 +71,7 @@ func (h *teamHandler) CreateTeam(ctx *fiber.Ctx) error {
 		})
 	}
 
-	teamID, err := h.repo.CreateTeam(&team, requestUserID)
+	teamID, err := h.repo.WithRequest(requestInfo(ctx)).CreateTeam(&team, requestUserID)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create team",
@@ -80,6 +85,7 @@ func (h *teamHandler) CreateTeam(ctx *fiber.Ctx) error {
 
 // GetTeamByID retrieves a team by its ID
 func (h *teamHandler) GetTeamByID(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
 	teamIDParam := ctx.Params("id")
 	teamID, err := strconv.ParseUint(teamIDParam, 10, 32)
 	if err != nil {
@@ -88,7 +94,7 @@ func (h *teamHandler) GetTeamByID(ctx *fiber.Ctx) error {
 		})
 	}
 
-	team, err := h.repo.GetTeamByID(uint(teamID))
+	team, err := h.repo.GetTeamByID(uint(teamID), requestUserID)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to retrieve team",
@@ -101,11 +107,14 @@ func (h *teamHandler) GetTeamByID(ctx *fiber.Ctx) error {
 		})
 	}
 
+	setETag(ctx, team.Version)
 	return ctx.Status(fiber.StatusOK).JSON(team)
 }
 
-// GetProjectsByTeam retrieves all projects for a specific team
+// GetProjectsByTeam retrieves all projects for a specific team, archived projects are only
+// listed with include_archived=true
 func (h *teamHandler) GetProjectsByTeam(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
 	teamIDParam := ctx.Params("id")
 	teamID, err := strconv.ParseUint(teamIDParam, 10, 32)
 	if err != nil {
@@ -114,7 +123,7 @@ func (h *teamHandler) GetProjectsByTeam(ctx *fiber.Ctx) error {
 		})
 	}
 
-	projects, err := h.repo.GetProjectsByTeam(uint(teamID))
+	projects, err := h.repo.GetProjectsByTeam(uint(teamID), ctx.QueryBool("include_archived"), requestUserID)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to retrieve projects",
@@ -124,42 +133,9 @@ func (h *teamHandler) GetProjectsByTeam(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusOK).JSON(projects)
 }
 
-// AddUsersToTeam adds users to a team with a specified role
-func (h *teamHandler) AddUsersToTeam(ctx *fiber.Ctx) error {
-	var payload struct {
-		ID        uint        `json:"id" validate:"required"`
-		Usernames []string    `json:"usernames" validate:"required,min=1"`
-		Role      models.Role `json:"role" validate:"required,oneof=admin member"`
-	}
-
-	// Parse request body
-	if err := ctx.BodyParser(&payload); err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
-	}
-
-	// Validate payload
-	if len(payload.Usernames) == 0 || payload.Role == "" {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "User IDs and role are required",
-		})
-	}
-
-	addedCount, err := h.repo.AddUsersToTeam(payload.Usernames, payload.ID, payload.Role)
-	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": fmt.Sprintf("Failed to add users to team: %v", err),
-		})
-	}
-
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
-		"added_users": addedCount,
-	})
-}
-
 // DeleteUsersFromTeam removes users from a team
 func (h *teamHandler) DeleteUsersFromTeam(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
 	teamIDParam := ctx.Params("id")
 	teamID, err := strconv.ParseUint(teamIDParam, 10, 32)
 	if err != nil {
@@ -186,7 +162,7 @@ func (h *teamHandler) DeleteUsersFromTeam(ctx *fiber.Ctx) error {
 		})
 	}
 
-	deletedCount, err := h.repo.DeleteUsersFromTeam(payload.UserIDs, uint(teamID))
+	deletedCount, err := h.repo.WithRequest(requestInfo(ctx)).DeleteUsersFromTeam(payload.UserIDs, uint(teamID), requestUserID)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to delete users from team: %v", err),
@@ -198,9 +174,23 @@ func (h *teamHandler) DeleteUsersFromTeam(ctx *fiber.Ctx) error {
 	})
 }
 
-// UpdateTeam updates a team's details
+// UpdateTeam sets the fields present in the body on a team, with If-Match the update fails with
+// 412 when the team changed since that version
 func (h *teamHandler) UpdateTeam(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
 	teamIDParam := ctx.Params("id")
+	if teamIDParam == "" {
+		// PUT /teams/update is kept for older clients, they send the team's id in the query or body
+		teamIDParam = ctx.Query("id")
+		if teamIDParam == "" {
+			var body struct {
+				ID uint `json:"id"`
+			}
+			if err := ctx.BodyParser(&body); err == nil && body.ID != 0 {
+				teamIDParam = strconv.FormatUint(uint64(body.ID), 10)
+			}
+		}
+	}
 	teamID, err := strconv.ParseUint(teamIDParam, 10, 32)
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -208,20 +198,26 @@ func (h *teamHandler) UpdateTeam(ctx *fiber.Ctx) error {
 		})
 	}
 
-	var updateData struct {
-		Name string `json:"name,omitempty"`
-		// Add other fields as necessary
-	}
-
 	// Parse request body
-	if err := ctx.BodyParser(&updateData); err != nil {
+	var patch repositories.TeamPatch
+	if err := ctx.BodyParser(&patch); err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid request body",
 		})
 	}
+	if patch.Name != nil && *patch.Name == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Team name is required",
+		})
+	}
+
+	expectedVersion, err := ifMatchVersion(ctx)
+	if err != nil {
+		return preconditionFailed(ctx, err)
+	}
 
 	// Fetch the existing team
-	team, err := h.repo.GetTeamByID(uint(teamID))
+	team, err := h.repo.GetTeamByID(uint(teamID), requestUserID)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to retrieve team",
@@ -234,26 +230,26 @@ func (h *teamHandler) UpdateTeam(ctx *fiber.Ctx) error {
 		})
 	}
 
-	// Update fields if provided
-	if updateData.Name != "" {
-		team.Name = updateData.Name
-	}
-	// Add other fields as necessary
-
-	updatedTeamID, err := h.repo.UpdateTeam(team)
+	version, err := h.repo.WithRequest(requestInfo(ctx)).UpdateTeam(team.ID, &patch, expectedVersion, requestUserID)
 	if err != nil {
+		if isVersionConflict(err) {
+			return preconditionFailed(ctx, err)
+		}
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update team",
 		})
 	}
 
+	setETag(ctx, version)
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
-		"team_id": updatedTeamID,
+		"team_id": team.ID,
 	})
 }
 
-// DeleteTeam deletes a team by its ID
+// DeleteTeam moves a team with its projects and tasks to the trash, with permanent=true
+// the team and everything in it is deleted right away
 func (h *teamHandler) DeleteTeam(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
 	teamIDParam := ctx.Params("id")
 	teamID, err := strconv.ParseUint(teamIDParam, 10, 32)
 	if err != nil {
@@ -262,7 +258,19 @@ func (h *teamHandler) DeleteTeam(ctx *fiber.Ctx) error {
 		})
 	}
 
-	deletedTeamID, err := h.repo.DeleteTeam(uint(teamID))
+	if ctx.QueryBool("permanent") {
+		if err := h.deletionRepo.WithRequest(requestInfo(ctx)).PurgeTeam(uint(teamID), requestUserID); err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to delete team: %v", err),
+			})
+		}
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Team deleted permanently",
+			"team_id": teamID,
+		})
+	}
+
+	deletedTeamID, err := h.repo.WithRequest(requestInfo(ctx)).DeleteTeam(uint(teamID), requestUserID)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to delete team: %v", err),
@@ -274,3 +282,72 @@ func (h *teamHandler) DeleteTeam(ctx *fiber.Ctx) error {
 		"team_id": deletedTeamID,
 	})
 }
+
+// RestoreTeam brings a team back from the trash with its projects and tasks
+func (h *teamHandler) RestoreTeam(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	restoredTeamID, err := h.repo.WithRequest(requestInfo(ctx)).RestoreTeam(uint(teamID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to restore team: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Team restored successfully",
+		"team_id": restoredTeamID,
+	})
+}
+
+// ArchiveTeam makes a team and its projects read-only
+func (h *teamHandler) ArchiveTeam(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	archivedTeamID, err := h.repo.WithRequest(requestInfo(ctx)).ArchiveTeam(uint(teamID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to archive team: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Team archived successfully",
+		"team_id": archivedTeamID,
+	})
+}
+
+// UnarchiveTeam makes an archived team writable again
+func (h *teamHandler) UnarchiveTeam(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	unarchivedTeamID, err := h.repo.WithRequest(requestInfo(ctx)).UnarchiveTeam(uint(teamID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to unarchive team: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Team unarchived successfully",
+		"team_id": unarchivedTeamID,
+	})
+}
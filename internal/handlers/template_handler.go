@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"mizito/pkg/models"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TemplateHandler interface {
+	GetTeamTemplates(ctx *fiber.Ctx) error
+	GetTemplateByID(ctx *fiber.Ctx) error
+	CreateTemplate(ctx *fiber.Ctx) error
+	UpdateTemplate(ctx *fiber.Ctx) error
+	DeleteTemplate(ctx *fiber.Ctx) error
+	CreateProjectFromTemplate(ctx *fiber.Ctx) error
+	ApplyTemplate(ctx *fiber.Ctx) error
+}
+
+type templateHandler struct {
+	repo repositories.TemplateRepository
+}
+
+func NewTemplateHandler(postgreSql *database.DatabaseHandler) TemplateHandler {
+	repo := repositories.NewTemplateRepository(postgreSql)
+	return &templateHandler{
+		repo: repo,
+	}
+}
+
+// startDateOrToday is the date relative due dates of a template are counted from
+func startDateOrToday(startDate *time.Time) time.Time {
+	if startDate != nil && !startDate.IsZero() {
+		return *startDate
+	}
+	return time.Now().Truncate(24 * time.Hour)
+}
+
+// GetTeamTemplates lists the templates of a team
+func (h *templateHandler) GetTeamTemplates(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	templates, err := h.repo.GetTeamTemplates(uint(teamID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(templates)
+}
+
+// GetTemplateByID returns a template with its tasks and subtasks
+func (h *templateHandler) GetTemplateByID(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	templateID, err := strconv.ParseUint(ctx.Params("template_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template ID",
+		})
+	}
+
+	template, err := h.repo.GetTemplateByID(uint(templateID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(template)
+}
+
+// CreateTemplate creates a template in a team
+func (h *templateHandler) CreateTemplate(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	var template models.ProjectTemplate
+	if err := ctx.BodyParser(&template); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	template.TeamID = uint(teamID)
+
+	templateID, err := h.repo.CreateTemplate(&template, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to create template: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"template_id": templateID,
+	})
+}
+
+// UpdateTemplate replaces a template's details and tasks
+func (h *templateHandler) UpdateTemplate(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	templateID, err := strconv.ParseUint(ctx.Params("template_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template ID",
+		})
+	}
+
+	var template models.ProjectTemplate
+	if err := ctx.BodyParser(&template); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	template.ID = uint(templateID)
+
+	updatedTemplateID, err := h.repo.UpdateTemplate(&template, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to update template: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"template_id": updatedTemplateID,
+	})
+}
+
+// DeleteTemplate deletes a template, projects created from it are not affected
+func (h *templateHandler) DeleteTemplate(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	templateID, err := strconv.ParseUint(ctx.Params("template_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template ID",
+		})
+	}
+
+	deletedTemplateID, err := h.repo.DeleteTemplate(uint(templateID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to delete template: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deleted_template_id": deletedTemplateID,
+	})
+}
+
+// CreateProjectFromTemplate creates a project with the members and tasks of a template
+func (h *templateHandler) CreateProjectFromTemplate(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	templateID, err := strconv.ParseUint(ctx.Params("template_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template ID",
+		})
+	}
+
+	var payload struct {
+		Name      string                 `json:"name"`
+		ImageUrl  string                 `json:"image_url"`
+		StartDate *time.Time             `json:"start_date"`
+		Members   []models.ProjectMember `json:"members"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	project := models.Project{Name: payload.Name, ImageUrl: payload.ImageUrl}
+	projectID, err := h.repo.WithRequest(requestInfo(ctx)).CreateProjectFromTemplate(uint(templateID), &project, payload.Members, startDateOrToday(payload.StartDate), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to create project from template: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"project_id": projectID,
+	})
+}
+
+// ApplyTemplate adds the tasks of a template to an existing project
+func (h *templateHandler) ApplyTemplate(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+	templateID, err := strconv.ParseUint(ctx.Params("template_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template ID",
+		})
+	}
+
+	var payload struct {
+		StartDate *time.Time `json:"start_date"`
+	}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&payload); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	taskIDs, err := h.repo.WithRequest(requestInfo(ctx)).ApplyTemplate(uint(templateID), uint(projectID), startDateOrToday(payload.StartDate), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to apply template: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"task_ids": taskIDs,
+	})
+}
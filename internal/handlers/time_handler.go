@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"mizito/pkg/models"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TimeHandler interface {
+	StartTimer(ctx *fiber.Ctx) error
+	StopTimer(ctx *fiber.Ctx) error
+	GetRunningTimer(ctx *fiber.Ctx) error
+	CreateEntry(ctx *fiber.Ctx) error
+	UpdateEntry(ctx *fiber.Ctx) error
+	DeleteEntry(ctx *fiber.Ctx) error
+	GetTaskTime(ctx *fiber.Ctx) error
+	GetProjectReport(ctx *fiber.Ctx) error
+	GetProjectTimesheet(ctx *fiber.Ctx) error
+	GetUserReport(ctx *fiber.Ctx) error
+	GetUserTimesheet(ctx *fiber.Ctx) error
+}
+
+type timeHandler struct {
+	repo repositories.TimeRepository
+}
+
+func NewTimeHandler(postgreSql *database.DatabaseHandler) TimeHandler {
+	repo := repositories.NewTimeRepository(postgreSql)
+	return &timeHandler{
+		repo: repo,
+	}
+}
+
+// parseDateRange reads the from and to query parameters as dates or RFC 3339 times, the last 30 days by default.
+// a plain to date includes the whole day.
+func parseDateRange(ctx *fiber.Ctx) (time.Time, time.Time, error) {
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+
+	if value := ctx.Query("from"); value != "" {
+		parsed, err := parseDateParam(value, false)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid from date %s", value)
+		}
+		from = parsed
+	}
+	if value := ctx.Query("to"); value != "" {
+		parsed, err := parseDateParam(value, true)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid to date %s", value)
+		}
+		to = parsed
+	}
+	if !to.After(from) {
+		return from, to, fmt.Errorf("to must be after from")
+	}
+	return from, to, nil
+}
+
+func parseDateParam(value string, endOfDay bool) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return parsed, err
+	}
+	if endOfDay {
+		parsed = parsed.AddDate(0, 0, 1)
+	}
+	return parsed, nil
+}
+
+// StartTimer starts tracking time on a task
+func (h *timeHandler) StartTimer(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	var payload struct {
+		Note string `json:"note"`
+	}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&payload); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	entry, err := h.repo.StartTimer(uint(taskID), payload.Note, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(entry)
+}
+
+// StopTimer stops the running timer of the user
+func (h *timeHandler) StopTimer(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+
+	entry, err := h.repo.StopTimer(requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(entry)
+}
+
+// GetRunningTimer returns the running timer of the user
+func (h *timeHandler) GetRunningTimer(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+
+	entry, err := h.repo.GetRunningTimer(requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(entry)
+}
+
+// CreateEntry adds a manual time entry to a task
+func (h *timeHandler) CreateEntry(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	var entry models.TimeEntry
+	if err := ctx.BodyParser(&entry); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	entry.TaskID = uint(taskID)
+
+	entryID, err := h.repo.CreateEntry(&entry, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to create time entry: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"entry_id": entryID,
+	})
+}
+
+// UpdateEntry changes the times or note of a time entry
+func (h *timeHandler) UpdateEntry(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	entryID, err := strconv.ParseUint(ctx.Params("entry_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid entry ID",
+		})
+	}
+
+	var entry models.TimeEntry
+	if err := ctx.BodyParser(&entry); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	entry.ID = uint(entryID)
+
+	updatedEntryID, err := h.repo.UpdateEntry(&entry, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to update time entry: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"entry_id": updatedEntryID,
+	})
+}
+
+// DeleteEntry deletes a time entry
+func (h *timeHandler) DeleteEntry(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	entryID, err := strconv.ParseUint(ctx.Params("entry_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid entry ID",
+		})
+	}
+
+	deletedEntryID, err := h.repo.DeleteEntry(uint(entryID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to delete time entry: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deleted_entry_id": deletedEntryID,
+	})
+}
+
+// GetTaskTime returns the time entries of a task next to its estimate
+func (h *timeHandler) GetTaskTime(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	taskTime, err := h.repo.GetTaskTime(uint(taskID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(taskTime)
+}
+
+func (h *timeHandler) projectTimesheet(ctx *fiber.Ctx) ([]repositories.TimesheetEntry, time.Time, time.Time, error) {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, fiber.NewError(fiber.StatusBadRequest, "Invalid project ID")
+	}
+	from, to, err := parseDateRange(ctx)
+	if err != nil {
+		return nil, from, to, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	entries, err := h.repo.GetProjectTimesheet(uint(projectID), from, to, requestUserID)
+	if err != nil {
+		return nil, from, to, fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	return entries, from, to, nil
+}
+
+func (h *timeHandler) userTimesheet(ctx *fiber.Ctx) ([]repositories.TimesheetEntry, time.Time, time.Time, error) {
+	requestUserID := ctx.Locals("userID").(uint)
+	userID, err := strconv.ParseUint(ctx.Params("user_id"), 10, 32)
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+	teamID, err := strconv.ParseUint(ctx.Query("team_id", "0"), 10, 32)
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, fiber.NewError(fiber.StatusBadRequest, "Invalid team ID")
+	}
+	from, to, err := parseDateRange(ctx)
+	if err != nil {
+		return nil, from, to, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	entries, err := h.repo.GetUserTimesheet(uint(userID), uint(teamID), from, to, requestUserID)
+	if err != nil {
+		return nil, from, to, fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	return entries, from, to, nil
+}
+
+func timesheetError(ctx *fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	if fiberErr, ok := err.(*fiber.Error); ok {
+		code = fiberErr.Code
+	}
+	return ctx.Status(code).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// writeTimesheetCSV sends the entries as a CSV attachment
+func writeTimesheetCSV(ctx *fiber.Ctx, filename string, entries []repositories.TimesheetEntry) error {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	_ = writer.Write([]string{"entry_id", "user", "project", "task_id", "task", "started_at", "ended_at", "hours", "manual", "note"})
+	for _, entry := range entries {
+		endedAt := ""
+		if entry.EndedAt != nil {
+			endedAt = entry.EndedAt.Format(time.RFC3339)
+		}
+		_ = writer.Write([]string{
+			strconv.FormatUint(uint64(entry.EntryID), 10),
+			entry.Username,
+			entry.ProjectName,
+			strconv.FormatUint(uint64(entry.TaskID), 10),
+			entry.TaskTitle,
+			entry.StartedAt.Format(time.RFC3339),
+			endedAt,
+			strconv.FormatFloat(float64(entry.Seconds)/3600, 'f', 2, 64),
+			strconv.FormatBool(entry.Manual),
+			entry.Note,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	ctx.Attachment(filename)
+	return ctx.Status(fiber.StatusOK).Send(buffer.Bytes())
+}
+
+// GetProjectReport totals the time spent in a project per user and task
+func (h *timeHandler) GetProjectReport(ctx *fiber.Ctx) error {
+	entries, from, to, err := h.projectTimesheet(ctx)
+	if err != nil {
+		return timesheetError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusOK).JSON(repositories.SummarizeTimesheet(entries, from, to))
+}
+
+// GetProjectTimesheet exports the time entries of a project as CSV
+func (h *timeHandler) GetProjectTimesheet(ctx *fiber.Ctx) error {
+	entries, _, _, err := h.projectTimesheet(ctx)
+	if err != nil {
+		return timesheetError(ctx, err)
+	}
+	return writeTimesheetCSV(ctx, fmt.Sprintf("project-%s-timesheet.csv", ctx.Params("project_id")), entries)
+}
+
+// GetUserReport totals the time a user spent per task
+func (h *timeHandler) GetUserReport(ctx *fiber.Ctx) error {
+	entries, from, to, err := h.userTimesheet(ctx)
+	if err != nil {
+		return timesheetError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusOK).JSON(repositories.SummarizeTimesheet(entries, from, to))
+}
+
+// GetUserTimesheet exports the time entries of a user as CSV
+func (h *timeHandler) GetUserTimesheet(ctx *fiber.Ctx) error {
+	entries, _, _, err := h.userTimesheet(ctx)
+	if err != nil {
+		return timesheetError(ctx, err)
+	}
+	return writeTimesheetCSV(ctx, fmt.Sprintf("user-%s-timesheet.csv", ctx.Params("user_id")), entries)
+}
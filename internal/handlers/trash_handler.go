@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TrashHandler interface {
+	GetTeamTrash(ctx *fiber.Ctx) error
+}
+
+type trashHandler struct {
+	repo repositories.TrashRepository
+}
+
+func NewTrashHandler(postgreSql *database.DatabaseHandler) TrashHandler {
+	repo := repositories.NewTrashRepository(postgreSql)
+	return &trashHandler{
+		repo: repo,
+	}
+}
+
+// GetTeamTrash lists the deleted projects and tasks of a team
+func (h *trashHandler) GetTeamTrash(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	teamID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	trash, err := h.repo.GetTeamTrash(uint(teamID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(trash)
+}
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"mizito/internal/database"
 	"strconv"
 
 	"mizito/internal/repositories"
+	bearerrepo "mizito/internal/repositories/auth/bearer"
 	"mizito/pkg/models"
 
 	"github.com/gofiber/fiber/v2"
@@ -19,13 +21,15 @@ type UserHandler interface {
 }
 
 type userHandler struct {
-	repo repositories.UserRepository
+	repo    repositories.UserRepository
+	jwtRepo bearerrepo.BearerRepository
 }
 
-func NewUserHandler(postgreSql *database.DatabaseHandler) UserHandler {
+func NewUserHandler(postgreSql *database.DatabaseHandler, jwtRepo bearerrepo.BearerRepository) UserHandler {
 	repo := repositories.NewUserRepository(postgreSql)
 	return &userHandler{
-		repo: repo,
+		repo:    repo,
+		jwtRepo: jwtRepo,
 	}
 }
 
@@ -54,7 +58,12 @@ func (h *userHandler) CreateUser(ctx *fiber.Ctx) error {
 		})
 	}
 
-	userID, err := h.repo.CreateUser(&user)
+	userID, err := h.repo.WithRequest(requestInfo(ctx)).CreateUser(&user)
+	if errors.Is(err, repositories.ErrWeakPassword) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create user",
@@ -93,8 +102,10 @@ func (h *userHandler) GetUserByID(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusOK).JSON(user)
 }
 
+// UpdateUser changes the profile of the signed in user, a new password needs the current one
+// and signs out every other session, the response carries new tokens for the requesting one
 func (h *userHandler) UpdateUser(ctx *fiber.Ctx) error {
-	userIDParam := ctx.Params("id")
+	userIDParam := ctx.Params("user_id")
 	userID, err := strconv.ParseUint(userIDParam, 10, 32)
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -102,9 +113,18 @@ func (h *userHandler) UpdateUser(ctx *fiber.Ctx) error {
 		})
 	}
 
+	requestUserID := ctx.Locals("userID").(uint)
+	if uint(userID) != requestUserID {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You can only update your own account",
+		})
+	}
+
 	var updateData struct {
-		Username string `json:"username,omitempty"`
-		Email    string `json:"email,omitempty"`
+		Username        string `json:"username,omitempty"`
+		Email           string `json:"email,omitempty"`
+		Password        string `json:"password,omitempty"`
+		CurrentPassword string `json:"current_password,omitempty"`
 	}
 
 	if err := ctx.BodyParser(&updateData); err != nil {
@@ -126,6 +146,26 @@ func (h *userHandler) UpdateUser(ctx *fiber.Ctx) error {
 		})
 	}
 
+	repo := h.repo.WithRequest(requestInfo(ctx))
+	if updateData.Password != "" {
+		err := repo.ChangePassword(user.ID, updateData.CurrentPassword, updateData.Password)
+		if errors.Is(err, repositories.ErrWrongPassword) || errors.Is(err, repositories.ErrWeakPassword) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to change password",
+			})
+		}
+		if err := h.jwtRepo.RevokeSessions(user.ID); err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Password changed but failed to revoke existing sessions",
+			})
+		}
+	}
+
 	if updateData.Username != "" {
 		user.Username = updateData.Username
 	}
@@ -133,15 +173,30 @@ func (h *userHandler) UpdateUser(ctx *fiber.Ctx) error {
 		user.Email = updateData.Email
 	}
 
-	updatedUserID, err := h.repo.UpdateUser(user)
+	updatedUserID, err := repo.UpdateUser(user)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update user",
 		})
 	}
 
+	if updateData.Password == "" {
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"user_id": updatedUserID,
+		})
+	}
+
+	// revoking the sessions invalidated the token of this request too, so it gets a new pair
+	token, refreshToken, err := h.jwtRepo.GenerateTokens(user.ID, user.Username)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Password changed but failed to generate new tokens",
+		})
+	}
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
-		"user_id": updatedUserID,
+		"user_id":       updatedUserID,
+		"access_token":  token,
+		"refresh_token": refreshToken,
 	})
 }
 
@@ -154,7 +209,7 @@ func (h *userHandler) DeleteUser(ctx *fiber.Ctx) error {
 		})
 	}
 
-	deletedUserID, err := h.repo.DeleteUser(uint(userID))
+	deletedUserID, err := h.repo.WithRequest(requestInfo(ctx)).DeleteUser(uint(userID))
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete user",
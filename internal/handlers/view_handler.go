@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"mizito/pkg/models"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ViewHandler interface {
+	GetMyTasks(ctx *fiber.Ctx) error
+	GetViews(ctx *fiber.Ctx) error
+	GetView(ctx *fiber.Ctx) error
+	CreateView(ctx *fiber.Ctx) error
+	UpdateView(ctx *fiber.Ctx) error
+	DeleteView(ctx *fiber.Ctx) error
+	GetViewTasks(ctx *fiber.Ctx) error
+}
+
+type viewHandler struct {
+	repo     repositories.ViewRepository
+	taskRepo repositories.TaskRepository
+}
+
+func NewViewHandler(postgreSql *database.DatabaseHandler) ViewHandler {
+	repo := repositories.NewViewRepository(postgreSql)
+	taskRepo := repositories.NewTaskRepository(postgreSql)
+	return &viewHandler{
+		repo:     repo,
+		taskRepo: taskRepo,
+	}
+}
+
+// viewQueries reads the saved query string of a view, the cursor and limit of a page come from the request
+func viewQueries(query string, page map[string]string) (map[string]string, error) {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid view query: %w", err)
+	}
+	queries := make(map[string]string)
+	for key := range values {
+		queries[key] = values.Get(key)
+	}
+	delete(queries, "cursor")
+	for _, key := range []string{"cursor", "limit"} {
+		if value, ok := page[key]; ok {
+			queries[key] = value
+		}
+	}
+	return queries, nil
+}
+
+// GetMyTasks returns the tasks assigned to the user across their projects, filtered like the project task list
+func (h *viewHandler) GetMyTasks(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+
+	taskQuery, err := parseTaskQuery(ctx.Queries(), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	page, err := h.taskRepo.GetMyTasks(taskQuery, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return sendTaskPage(ctx, page, taskQuery.SelectedFields())
+}
+
+// GetViews returns the user's saved views and the views shared with their teams
+func (h *viewHandler) GetViews(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+
+	views, err := h.repo.GetViews(requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(views)
+}
+
+// GetView returns a saved view
+func (h *viewHandler) GetView(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	viewID, err := strconv.ParseUint(ctx.Params("view_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid view ID",
+		})
+	}
+
+	view, err := h.repo.GetView(uint(viewID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(view)
+}
+
+// CreateView saves a task listing query under a name, optionally shared with a team
+func (h *viewHandler) CreateView(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+
+	var view models.SavedView
+	if err := ctx.BodyParser(&view); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := validateViewQuery(view.Query, requestUserID); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	viewID, err := h.repo.CreateView(&view, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to create view: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"view_id": viewID,
+	})
+}
+
+// UpdateView changes the name, query or sharing of a view
+func (h *viewHandler) UpdateView(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	viewID, err := strconv.ParseUint(ctx.Params("view_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid view ID",
+		})
+	}
+
+	var view models.SavedView
+	if err := ctx.BodyParser(&view); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := validateViewQuery(view.Query, requestUserID); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	view.ID = uint(viewID)
+
+	updatedViewID, err := h.repo.UpdateView(&view, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to update view: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"view_id": updatedViewID,
+	})
+}
+
+// DeleteView deletes a view of the user
+func (h *viewHandler) DeleteView(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	viewID, err := strconv.ParseUint(ctx.Params("view_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid view ID",
+		})
+	}
+
+	deletedViewID, err := h.repo.DeleteView(uint(viewID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to delete view: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deleted_view_id": deletedViewID,
+	})
+}
+
+// GetViewTasks runs a view for the requesting user, "me" in a shared view is whoever runs it
+func (h *viewHandler) GetViewTasks(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	viewID, err := strconv.ParseUint(ctx.Params("view_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid view ID",
+		})
+	}
+
+	view, err := h.repo.GetView(uint(viewID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	queries, err := viewQueries(view.Query, ctx.Queries())
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	taskQuery, err := parseTaskQuery(queries, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var page *repositories.TaskPage
+	if view.ProjectID != nil {
+		page, err = h.taskRepo.GetTasksByProject(*view.ProjectID, taskQuery, requestUserID)
+	} else {
+		page, err = h.taskRepo.GetMyTasks(taskQuery, requestUserID)
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return sendTaskPage(ctx, page, taskQuery.SelectedFields())
+}
+
+func validateViewQuery(query string, requestUserID uint) error {
+	queries, err := viewQueries(query, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := parseTaskQuery(queries, requestUserID); err != nil {
+		return fmt.Errorf("invalid view query: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"fmt"
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+	"mizito/pkg/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WorkflowHandler interface {
+	GetWorkflow(ctx *fiber.Ctx) error
+	CreateStatus(ctx *fiber.Ctx) error
+	UpdateStatus(ctx *fiber.Ctx) error
+	DeleteStatus(ctx *fiber.Ctx) error
+	SetTransitions(ctx *fiber.Ctx) error
+	GetBoard(ctx *fiber.Ctx) error
+	MoveTask(ctx *fiber.Ctx) error
+	GetTaskMoves(ctx *fiber.Ctx) error
+}
+
+type workflowHandler struct {
+	repo repositories.WorkflowRepository
+}
+
+func NewWorkflowHandler(postgreSql *database.DatabaseHandler) WorkflowHandler {
+	repo := repositories.NewWorkflowRepository(postgreSql)
+	return &workflowHandler{
+		repo: repo,
+	}
+}
+
+// GetWorkflow returns the statuses and allowed transitions of a project
+func (h *workflowHandler) GetWorkflow(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	workflow, err := h.repo.GetWorkflow(uint(projectID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(workflow)
+}
+
+// CreateStatus adds a status to the workflow of a project
+func (h *workflowHandler) CreateStatus(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	var status models.WorkflowStatus
+	if err := ctx.BodyParser(&status); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	statusID, err := h.repo.CreateStatus(uint(projectID), &status, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to create status: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status_id": statusID,
+	})
+}
+
+// UpdateStatus renames, reorders or marks a status as done
+func (h *workflowHandler) UpdateStatus(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+	statusID, err := strconv.ParseUint(ctx.Params("status_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid status ID",
+		})
+	}
+
+	// only the fields present in the body change
+	var patch repositories.StatusPatch
+	if err := ctx.BodyParser(&patch); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	updatedStatusID, err := h.repo.UpdateStatus(uint(projectID), uint(statusID), &patch, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to update status: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status_id": updatedStatusID,
+	})
+}
+
+// DeleteStatus removes a status that no task uses anymore
+func (h *workflowHandler) DeleteStatus(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+	statusID, err := strconv.ParseUint(ctx.Params("status_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid status ID",
+		})
+	}
+
+	deletedStatusID, err := h.repo.DeleteStatus(uint(projectID), uint(statusID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to delete status: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deleted_status_id": deletedStatusID,
+	})
+}
+
+// SetTransitions replaces the allowed transitions of a project
+func (h *workflowHandler) SetTransitions(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	var payload struct {
+		Transitions []models.WorkflowTransition `json:"transitions"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.repo.SetTransitions(uint(projectID), payload.Transitions, requestUserID); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to set transitions: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Transitions updated successfully",
+	})
+}
+
+// GetBoard returns the tasks of a project grouped by status in board order, filtered like the task list
+func (h *workflowHandler) GetBoard(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	projectID, err := strconv.ParseUint(ctx.Params("project_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	filter, err := parseTaskFilter(ctx.Queries(), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	board, err := h.repo.GetBoard(uint(projectID), filter, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(board)
+}
+
+// MoveTask moves a task to a status and a position inside its column
+func (h *workflowHandler) MoveTask(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	var payload struct {
+		StatusID     uint `json:"status_id"`
+		AfterTaskID  uint `json:"after_task_id"`
+		BeforeTaskID uint `json:"before_task_id"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil || payload.StatusID == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Status ID is required",
+		})
+	}
+
+	task, err := h.repo.WithRequest(requestInfo(ctx)).MoveTask(uint(taskID), payload.StatusID, payload.AfterTaskID, payload.BeforeTaskID, requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to move task: %v", err),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(task)
+}
+
+// GetTaskMoves returns the status history of a task
+func (h *workflowHandler) GetTaskMoves(ctx *fiber.Ctx) error {
+	requestUserID := ctx.Locals("userID").(uint)
+	taskID, err := strconv.ParseUint(ctx.Params("task_id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID",
+		})
+	}
+
+	moves, err := h.repo.GetTaskMoves(uint(taskID), requestUserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(moves)
+}
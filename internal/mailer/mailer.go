@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"mizito/internal/env"
+)
+
+// Mailer delivers plain text emails to users.
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// NewMailer returns an SMTP backed mailer when SMTPHost is configured,
+// otherwise a mailer that only prints the email to stdout (useful for development).
+func NewMailer(cfg *env.Config) Mailer {
+	if cfg.SMTPHost == "" {
+		return &logMailer{from: cfg.MailFrom}
+	}
+	return &smtpMailer{
+		addr: fmt.Sprintf("%s:%s", cfg.SMTPHost, cfg.SMTPPort),
+		auth: smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost),
+		from: cfg.MailFrom,
+	}
+}
+
+type smtpMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func (m *smtpMailer) Send(to string, subject string, body string) error {
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", to, err)
+	}
+	return nil
+}
+
+type logMailer struct {
+	from string
+}
+
+func (m *logMailer) Send(to string, subject string, body string) error {
+	fmt.Printf("email from %s to %s\nsubject: %s\n%s\n", m.from, to, subject, body)
+	return nil
+}
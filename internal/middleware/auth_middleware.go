@@ -4,55 +4,73 @@ import (
 	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
-	"mizito/internal/env"
+	"mizito/internal/database"
 	"strings"
 )
 
-func AuthMiddleware(c *fiber.Ctx) error {
-	if strings.HasPrefix(c.Path(), "/api/auth") || strings.HasPrefix(c.Path(), "/user") {
-		return c.Next()
-	}
+// NewAuthMiddleware validates bearer tokens and rejects the ones belonging to revoked sessions
+func NewAuthMiddleware(secret string, redis *database.RedisHandler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// "/user" is the sign up route, "/users/..." routes need a token like the rest
+		if strings.HasPrefix(c.Path(), "/api/auth") || c.Path() == "/user" || strings.HasPrefix(c.Path(), "/user/") ||
+			strings.HasPrefix(c.Path(), "/files/") {
+			return c.Next()
+		}
 
-	token := c.Get("Authorization")
-	if len(token) > 7 && token[:7] == "Bearer " {
-		token = token[7:]
-	}
+		token := c.Get("Authorization")
+		if len(token) > 7 && token[:7] == "Bearer " {
+			token = token[7:]
+		}
 
-	userID, err := ValidateTokenAndExtractUserID(token)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Unauthorized: " + err.Error(),
-		})
-	}
+		claims, err := ValidateTokenAndExtractClaims(token, secret)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Unauthorized: " + err.Error(),
+			})
+		}
+
+		version, err := redis.GetSessionVersion(claims.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to check session",
+			})
+		}
+		if claims.SessionVersion != version {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Unauthorized: session has been revoked",
+			})
+		}
 
-	c.Locals("userID", userID)
-	return c.Next()
+		c.Locals("userID", claims.UserID)
+		return c.Next()
+	}
 }
 
 // CustomClaims defines the claims stored in the JWT token
 type CustomClaims struct {
-	UserID uint `json:"user_id"`
+	UserID         uint  `json:"user_id"`
+	SessionVersion int64 `json:"session_version"`
 	jwt.RegisteredClaims
 }
 
-// ValidateTokenAndExtractUserID validates the token and extracts the user ID
-func ValidateTokenAndExtractUserID(tokenString string) (uint, error) {
+// ValidateTokenAndExtractClaims validates the token and extracts its claims
+func ValidateTokenAndExtractClaims(tokenString string, secret string) (*CustomClaims, error) {
 	if tokenString == "" {
-		return 0, errors.New("missing token")
+		return nil, errors.New("missing token")
 	}
 
 	// Parse the token
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(env.Config{}.AuthorizationSecret), nil
+		return []byte(secret), nil
 	})
 	if err != nil {
-		return 0, errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	// Validate the token and extract claims
 	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		return claims.UserID, nil
+		return claims, nil
 	}
 
-	return 0, errors.New("unauthorized")
+	return nil, errors.New("unauthorized")
 }
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -14,3 +16,17 @@ func UpgradeMiddleware(c *fiber.Ctx) error {
 	}
 	return c.Next()
 }
+
+// SocketOwnerMiddleware runs after the auth middleware and rejects upgrades to a socket of another
+// user, the id in the path has to be the token's user
+func SocketOwnerMiddleware(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	userID, ok := c.Locals("userID").(uint)
+	if err != nil || !ok || uint(id) != userID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "failed",
+			"message": "the socket id doesn't match the signed in user",
+		})
+	}
+	return c.Next()
+}
@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// RequestInfo identifies the request a mutation is made for, it's stored with the activities
+// the mutation records. ActorID is only used by mutations that don't take the requesting user.
+type RequestInfo struct {
+	RequestID string
+	ActorID   uint
+}
+
+// activityIgnoredFields are bookkeeping fields left out of the recorded changes
+var activityIgnoredFields = map[string]bool{
+	"CreatedAt": true,
+	"UpdatedAt": true,
+	"Version":   true,
+}
+
+// activityMaskedFields are recorded as changed without their values
+var activityMaskedFields = map[string]bool{
+	"Password": true,
+}
+
+const maskedValue = "[redacted]"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// activityFields returns the scalar fields of a model, relations are left out
+func activityFields(record interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if record == nil {
+		return fields
+	}
+	value := reflect.Indirect(reflect.ValueOf(record))
+	if value.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() || activityIgnoredFields[field.Name] {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Map ||
+			(fieldType.Kind() == reflect.Struct && fieldType != timeType) {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Ptr {
+			if fieldValue.IsNil() {
+				fields[field.Name] = nil
+				continue
+			}
+			fieldValue = fieldValue.Elem()
+		}
+		fields[field.Name] = fieldValue.Interface()
+	}
+	return fields
+}
+
+func sameActivityValue(a interface{}, b interface{}) bool {
+	aTime, aIsTime := a.(time.Time)
+	bTime, bIsTime := b.(time.Time)
+	if aIsTime && bIsTime {
+		return aTime.Equal(bTime)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// activityChanges returns the fields that differ between two states of a record, before is nil
+// for created records and after is nil for deleted ones
+func activityChanges(before interface{}, after interface{}) models.ActivityChanges {
+	beforeFields := activityFields(before)
+	afterFields := activityFields(after)
+
+	changes := models.ActivityChanges{}
+	for name, value := range afterFields {
+		old, existed := beforeFields[name]
+		if existed && sameActivityValue(old, value) {
+			continue
+		}
+		changes[name] = models.FieldChange{Before: old, After: value}
+	}
+	for name, old := range beforeFields {
+		if _, exists := afterFields[name]; !exists {
+			changes[name] = models.FieldChange{Before: old}
+		}
+	}
+
+	for name, change := range changes {
+		if !activityMaskedFields[name] {
+			continue
+		}
+		if change.Before != nil {
+			change.Before = maskedValue
+		}
+		if change.After != nil {
+			change.After = maskedValue
+		}
+		changes[name] = change
+	}
+	return changes
+}
+
+// recordActivity appends an activity to the log, activities of a project are placed in its team's feed as well
+func recordActivity(tx *gorm.DB, request RequestInfo, activity models.Activity) error {
+	activity.RequestID = request.RequestID
+	if activity.TeamID == nil && activity.ProjectID != nil {
+		var project models.Project
+		if err := tx.Unscoped().Select("id", "team_id").First(&project, *activity.ProjectID).Error; err != nil {
+			return fmt.Errorf("failed to get project %d: %w", *activity.ProjectID, err)
+		}
+		activity.TeamID = &project.TeamID
+	}
+	if err := tx.Create(&activity).Error; err != nil {
+		return fmt.Errorf("failed to record activity: %w", err)
+	}
+	return nil
+}
+
+// taskProjectID returns the project of a task for placing its subtasks' activities
+func taskProjectID(tx *gorm.DB, taskID uint) (uint, error) {
+	var task models.Task
+	if err := tx.Select("id", "project_id").First(&task, taskID).Error; err != nil {
+		return 0, fmt.Errorf("failed to get task %d: %w", taskID, err)
+	}
+	return task.ProjectID, nil
+}
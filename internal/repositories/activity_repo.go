@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultActivityPageSize = 50
+	MaxActivityPageSize     = 200
+)
+
+// ActivityQuery filters a page of the activity log, newest first. Cursor is the NextCursor of
+// the previous page and From and To bound the time of the activities.
+type ActivityQuery struct {
+	ActorID    *uint
+	Action     models.ActivityAction
+	TargetType models.ActivityTarget
+	TargetID   *uint
+	TeamID     *uint
+	ProjectID  *uint
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+	Cursor     uint
+	Limit      int
+}
+
+type ActivityPage struct {
+	Activities []models.Activity
+	// NextCursor is 0 on the last page
+	NextCursor uint
+}
+
+type ActivityRepository interface {
+	// GetProjectActivity returns the feed of the project's, its tasks' and subtasks' activities
+	GetProjectActivity(projectID uint, query ActivityQuery, requestUserID uint) (*ActivityPage, error)
+	// GetTeamActivity returns the feed of the team's and its projects' activities
+	GetTeamActivity(teamID uint, query ActivityQuery, requestUserID uint) (*ActivityPage, error)
+	// GetAuditLog queries the whole activity log, only system admins may use it
+	GetAuditLog(query ActivityQuery, requestUserID uint) (*ActivityPage, error)
+}
+
+type activityRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+}
+
+func NewActivityRepository(postgreSql *database.DatabaseHandler) ActivityRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &activityRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+func (ar *activityRepository) GetProjectActivity(projectID uint, query ActivityQuery, requestUserID uint) (*ActivityPage, error) {
+	if !ar.permissionRepo.Authorize(requestUserID, models.ProjectView, utils.ProjectResource(projectID)) {
+		return nil, errors.New("you don't have access to the project")
+	}
+	query.ProjectID = &projectID
+	query.TeamID = nil
+	return ar.activityPage(query, 0)
+}
+
+func (ar *activityRepository) GetTeamActivity(teamID uint, query ActivityQuery, requestUserID uint) (*ActivityPage, error) {
+	if !ar.permissionRepo.Authorize(requestUserID, models.TeamView, utils.TeamResource(teamID)) {
+		return nil, errors.New("you don't have access to the team")
+	}
+	query.TeamID = &teamID
+
+	// members only see the activities of the projects they are in besides the team's own
+	var memberID uint
+	if !ar.permissionRepo.CheckUserIsSystemAdmin(requestUserID) && !ar.permissionRepo.CheckUserIsAdminOfTeam(requestUserID, teamID) {
+		memberID = requestUserID
+	}
+	return ar.activityPage(query, memberID)
+}
+
+func (ar *activityRepository) GetAuditLog(query ActivityQuery, requestUserID uint) (*ActivityPage, error) {
+	if !ar.permissionRepo.CheckUserIsSystemAdmin(requestUserID) {
+		return nil, errors.New("only admins may query the audit log")
+	}
+	return ar.activityPage(query, 0)
+}
+
+// activityPage returns a page of the activities matching query, the project activities are limited
+// to the projects of memberID unless it's 0
+func (ar *activityRepository) activityPage(query ActivityQuery, memberID uint) (*ActivityPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultActivityPageSize
+	} else if query.Limit > MaxActivityPageSize {
+		query.Limit = MaxActivityPageSize
+	}
+
+	db := ar.DB.Model(&models.Activity{})
+	if query.ActorID != nil {
+		db = db.Where("actor_id = ?", *query.ActorID)
+	}
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.TargetType != "" {
+		db = db.Where("target_type = ?", query.TargetType)
+	}
+	if query.TargetID != nil {
+		db = db.Where("target_id = ?", *query.TargetID)
+	}
+	if query.TeamID != nil {
+		db = db.Where("team_id = ?", *query.TeamID)
+	}
+	if query.ProjectID != nil {
+		db = db.Where("project_id = ?", *query.ProjectID)
+	}
+	if memberID != 0 {
+		memberProjects := ar.DB.Model(&models.ProjectMember{}).Select("project_id").Where("user_id = ?", memberID)
+		db = db.Where("(project_id IS NULL OR project_id IN (?))", memberProjects)
+	}
+	if query.RequestID != "" {
+		db = db.Where("request_id = ?", query.RequestID)
+	}
+	if query.From != nil {
+		db = db.Where("created_at >= ?", *query.From)
+	}
+	if query.To != nil {
+		db = db.Where("created_at < ?", *query.To)
+	}
+	if query.Cursor != 0 {
+		db = db.Where("id < ?", query.Cursor)
+	}
+
+	// one extra row tells whether there is a next page
+	activities := []models.Activity{}
+	if err := db.Order("id DESC").Limit(query.Limit + 1).Find(&activities).Error; err != nil {
+		return nil, fmt.Errorf("failed to get activities: %w", err)
+	}
+
+	page := &ActivityPage{Activities: activities}
+	if len(activities) > query.Limit {
+		page.Activities = activities[:query.Limit]
+		page.NextCursor = page.Activities[query.Limit-1].ID
+	}
+	return page, nil
+}
@@ -0,0 +1,363 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const maxAnalyticsDays = 366
+
+type BurndownPoint struct {
+	Date      time.Time `json:"date"`
+	Total     int       `json:"total"`
+	Done      int       `json:"done"`
+	Remaining int       `json:"remaining"`
+	// Ideal burns the remaining tasks of the first day down to zero on the last day
+	Ideal float64 `json:"ideal"`
+}
+
+type ThroughputWeek struct {
+	WeekStart time.Time `json:"week_start"`
+	Completed int       `json:"completed"`
+}
+
+type Throughput struct {
+	Weeks []ThroughputWeek `json:"weeks"`
+	// Velocity is the average number of tasks completed per week
+	Velocity float64 `json:"velocity"`
+}
+
+type CycleTimeBucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// CycleTimes describe the tasks completed in a range, cycle time runs from the first move out of
+// the task's initial status to its completion and lead time from its creation to its completion
+type CycleTimes struct {
+	Count           int               `json:"count"`
+	MedianHours     float64           `json:"median_hours"`
+	P85Hours        float64           `json:"p85_hours"`
+	MedianLeadHours float64           `json:"median_lead_hours"`
+	Buckets         []CycleTimeBucket `json:"buckets"`
+}
+
+type MemberWorkload struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	// Assigned counts the assignments made in the range
+	Assigned int `json:"assigned"`
+	// Completed counts the tasks completed in the range while assigned to the member
+	Completed int `json:"completed"`
+	// Open counts the unfinished tasks assigned to the member at the end of the range
+	Open int `json:"open"`
+}
+
+var cycleTimeBuckets = []struct {
+	label string
+	upTo  time.Duration
+}{
+	{"< 1 day", 24 * time.Hour},
+	{"1-2 days", 48 * time.Hour},
+	{"2-4 days", 96 * time.Hour},
+	{"4-7 days", 7 * 24 * time.Hour},
+	{"1-2 weeks", 14 * 24 * time.Hour},
+	{"2-4 weeks", 28 * 24 * time.Hour},
+	{"> 4 weeks", time.Duration(math.MaxInt64)},
+}
+
+type AnalyticsRepository interface {
+	GetBurndown(projectID uint, from time.Time, to time.Time, requestUserID uint) ([]BurndownPoint, error)
+	GetThroughput(projectID uint, from time.Time, to time.Time, requestUserID uint) (*Throughput, error)
+	GetCycleTimes(projectID uint, from time.Time, to time.Time, requestUserID uint) (*CycleTimes, error)
+	GetWorkload(projectID uint, from time.Time, to time.Time, requestUserID uint) ([]MemberWorkload, error)
+}
+
+type analyticsRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+}
+
+func NewAnalyticsRepository(postgreSql *database.DatabaseHandler) AnalyticsRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &analyticsRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+// taskHistory is the state of a task while its events are replayed
+type taskHistory struct {
+	exists    bool
+	done      bool
+	statusID  *uint
+	createdAt time.Time
+	startedAt time.Time
+	assignees map[uint]bool
+}
+
+// historyReplay applies the events of a project in order, apply reports the completions
+type historyReplay struct {
+	events []models.TaskEvent
+	next   int
+	tasks  map[uint]*taskHistory
+}
+
+func (h *historyReplay) task(taskID uint) *taskHistory {
+	task, ok := h.tasks[taskID]
+	if !ok {
+		task = &taskHistory{assignees: make(map[uint]bool)}
+		h.tasks[taskID] = task
+	}
+	return task
+}
+
+// applyUntil replays the events that happened before a time, onEvent sees every event with the
+// task state before it is applied
+func (h *historyReplay) applyUntil(until time.Time, onEvent func(event *models.TaskEvent, before taskHistory)) {
+	for ; h.next < len(h.events) && h.events[h.next].OccurredAt.Before(until); h.next++ {
+		event := &h.events[h.next]
+		task := h.task(event.TaskID)
+		if onEvent != nil {
+			onEvent(event, *task)
+		}
+
+		switch event.Type {
+		case models.TaskCreated:
+			task.exists = true
+			task.done = event.Done
+			task.statusID = event.StatusID
+			task.createdAt = event.OccurredAt
+		case models.TaskMoved:
+			if task.startedAt.IsZero() && !task.done && !sameStatus(task.statusID, event.StatusID) {
+				task.startedAt = event.OccurredAt
+			}
+			task.done = event.Done
+			task.statusID = event.StatusID
+		case models.TaskAssigned:
+			if event.UserID != nil {
+				task.assignees[*event.UserID] = true
+			}
+		case models.TaskDeleted:
+			task.exists = false
+		case models.TaskRestored:
+			task.exists = true
+			task.done = event.Done
+			task.statusID = event.StatusID
+		}
+	}
+}
+
+func sameStatus(a *uint, b *uint) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+// isCompletion tells whether an event finishes a task that wasn't done
+func isCompletion(event *models.TaskEvent, before taskHistory) bool {
+	return event.Type == models.TaskMoved && event.Done && !before.done && before.exists
+}
+
+// loadHistory checks access and loads the events of a project that happened before the end of a range
+func (ar *analyticsRepository) loadHistory(projectID uint, from time.Time, to time.Time, requestUserID uint) (*historyReplay, error) {
+	if !ar.permissionRepo.Authorize(requestUserID, models.TaskView, utils.ProjectResource(projectID)) {
+		return nil, errors.New("you don't have access to the project")
+	}
+	if to.Sub(from) > maxAnalyticsDays*24*time.Hour {
+		return nil, fmt.Errorf("ranges can't be longer than %d days", maxAnalyticsDays)
+	}
+
+	var events []models.TaskEvent
+	err := ar.DB.Where("project_id = ? AND occurred_at < ?", projectID, to).
+		Order("occurred_at, id").
+		Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history of project %d: %w", projectID, err)
+	}
+	return &historyReplay{events: events, tasks: make(map[uint]*taskHistory)}, nil
+}
+
+func (ar *analyticsRepository) GetBurndown(projectID uint, from time.Time, to time.Time, requestUserID uint) ([]BurndownPoint, error) {
+	history, err := ar.loadHistory(projectID, from, to, requestUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	points := []BurndownPoint{}
+	for day := truncateToDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		history.applyUntil(day.AddDate(0, 0, 1), nil)
+
+		point := BurndownPoint{Date: day}
+		for _, task := range history.tasks {
+			if !task.exists {
+				continue
+			}
+			point.Total++
+			if task.done {
+				point.Done++
+			}
+		}
+		point.Remaining = point.Total - point.Done
+		points = append(points, point)
+	}
+
+	if len(points) > 1 {
+		start := float64(points[0].Remaining)
+		for i := range points {
+			points[i].Ideal = start - start*float64(i)/float64(len(points)-1)
+		}
+	} else if len(points) == 1 {
+		points[0].Ideal = float64(points[0].Remaining)
+	}
+	return points, nil
+}
+
+// weekStart returns the monday starting the week of a time
+func weekStart(t time.Time) time.Time {
+	day := truncateToDay(t)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+func (ar *analyticsRepository) GetThroughput(projectID uint, from time.Time, to time.Time, requestUserID uint) (*Throughput, error) {
+	history, err := ar.loadHistory(projectID, from, to, requestUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	throughput := &Throughput{Weeks: []ThroughputWeek{}}
+	weeks := make(map[time.Time]int)
+	for week := weekStart(from); week.Before(to); week = week.AddDate(0, 0, 7) {
+		weeks[week] = len(throughput.Weeks)
+		throughput.Weeks = append(throughput.Weeks, ThroughputWeek{WeekStart: week})
+	}
+
+	history.applyUntil(from, nil)
+	total := 0
+	history.applyUntil(to, func(event *models.TaskEvent, before taskHistory) {
+		if isCompletion(event, before) {
+			throughput.Weeks[weeks[weekStart(event.OccurredAt)]].Completed++
+			total++
+		}
+	})
+
+	if len(throughput.Weeks) > 0 {
+		throughput.Velocity = float64(total) / float64(len(throughput.Weeks))
+	}
+	return throughput, nil
+}
+
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return sorted[index].Hours()
+}
+
+func (ar *analyticsRepository) GetCycleTimes(projectID uint, from time.Time, to time.Time, requestUserID uint) (*CycleTimes, error) {
+	history, err := ar.loadHistory(projectID, from, to, requestUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var cycleTimes, leadTimes []time.Duration
+	history.applyUntil(from, nil)
+	history.applyUntil(to, func(event *models.TaskEvent, before taskHistory) {
+		if !isCompletion(event, before) {
+			return
+		}
+		started := before.startedAt
+		if started.IsZero() {
+			started = before.createdAt
+		}
+		cycleTimes = append(cycleTimes, event.OccurredAt.Sub(started))
+		leadTimes = append(leadTimes, event.OccurredAt.Sub(before.createdAt))
+	})
+	sort.Slice(cycleTimes, func(i, j int) bool { return cycleTimes[i] < cycleTimes[j] })
+	sort.Slice(leadTimes, func(i, j int) bool { return leadTimes[i] < leadTimes[j] })
+
+	stats := &CycleTimes{
+		Count:           len(cycleTimes),
+		MedianHours:     percentile(cycleTimes, 0.5),
+		P85Hours:        percentile(cycleTimes, 0.85),
+		MedianLeadHours: percentile(leadTimes, 0.5),
+	}
+	for _, bucket := range cycleTimeBuckets {
+		stats.Buckets = append(stats.Buckets, CycleTimeBucket{Label: bucket.label})
+	}
+	for _, cycleTime := range cycleTimes {
+		for i, bucket := range cycleTimeBuckets {
+			if cycleTime < bucket.upTo {
+				stats.Buckets[i].Count++
+				break
+			}
+		}
+	}
+	return stats, nil
+}
+
+func (ar *analyticsRepository) GetWorkload(projectID uint, from time.Time, to time.Time, requestUserID uint) ([]MemberWorkload, error) {
+	history, err := ar.loadHistory(projectID, from, to, requestUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	workloads := make(map[uint]*MemberWorkload)
+	member := func(userID uint) *MemberWorkload {
+		workload, ok := workloads[userID]
+		if !ok {
+			workload = &MemberWorkload{UserID: userID}
+			workloads[userID] = workload
+		}
+		return workload
+	}
+
+	history.applyUntil(from, nil)
+	history.applyUntil(to, func(event *models.TaskEvent, before taskHistory) {
+		if event.Type == models.TaskAssigned && event.UserID != nil && !before.assignees[*event.UserID] {
+			member(*event.UserID).Assigned++
+		}
+		if isCompletion(event, before) {
+			for userID := range before.assignees {
+				member(userID).Completed++
+			}
+		}
+	})
+	for _, task := range history.tasks {
+		if !task.exists || task.done {
+			continue
+		}
+		for userID := range task.assignees {
+			member(userID).Open++
+		}
+	}
+
+	userIDs := make([]uint, 0, len(workloads))
+	for userID := range workloads {
+		userIDs = append(userIDs, userID)
+	}
+	if len(userIDs) > 0 {
+		var users []models.User
+		if err := ar.DB.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, fmt.Errorf("failed to get members: %w", err)
+		}
+		for _, user := range users {
+			workloads[user.ID].Username = user.Username
+		}
+	}
+
+	result := make([]MemberWorkload, 0, len(workloads))
+	for _, workload := range workloads {
+		result = append(result, *workload)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Username < result[j].Username })
+	return result, nil
+}
@@ -0,0 +1,475 @@
+package repositories
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/env"
+	"mizito/internal/repositories/utils"
+	"mizito/internal/storage"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidSignature = errors.New("invalid or expired download link")
+
+// AttachmentUpload is a file streamed from a multipart request
+type AttachmentUpload struct {
+	Name         string
+	DeclaredType string
+	Body         io.Reader
+	OwnerType    models.AttachmentOwner
+	OwnerID      uint
+}
+
+type AttachmentRepository interface {
+	// UploadAttachment stores the file once per content hash and attaches it to its owner
+	UploadAttachment(upload *AttachmentUpload, requestUserID uint) (*models.Attachment, error)
+	GetAttachment(attachmentID uint, requestUserID uint) (*models.Attachment, error)
+	GetOwnerAttachments(ownerType models.AttachmentOwner, ownerID uint, requestUserID uint) ([]models.Attachment, error)
+	OpenAttachment(attachmentID uint, requestUserID uint) (*models.Attachment, io.ReadCloser, error)
+	DeleteAttachment(attachmentID uint, requestUserID uint) (uint, error)
+	// SignedURL returns a download link that works without a token until it expires
+	SignedURL(attachmentID uint, requestUserID uint) (string, time.Time, error)
+	OpenSigned(attachmentID uint, expires int64, signature string) (*models.Attachment, io.ReadCloser, error)
+}
+
+type attachmentRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+	store          storage.BlobStore
+	cfg            *env.Config
+}
+
+func NewAttachmentRepository(postgreSql *database.DatabaseHandler, store storage.BlobStore, cfg *env.Config) AttachmentRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &attachmentRepository{DB: postgreSql.DB, permissionRepo: permissionRepo, store: store, cfg: cfg}
+}
+
+func attachmentContentURL(attachmentID uint) string {
+	return fmt.Sprintf("/attachments/%d/content", attachmentID)
+}
+
+func blobKey(hash string) string {
+	return "blobs/" + hash[:2] + "/" + hash[2:4] + "/" + hash
+}
+
+func isAvatar(ownerType models.AttachmentOwner) bool {
+	return ownerType == models.AttachmentProjectAvatar || ownerType == models.AttachmentUserAvatar
+}
+
+// commentTask returns the task of a comment and its author
+func (ar *attachmentRepository) commentTask(commentID uint) (uint, uint, error) {
+	var comment models.TaskComment
+	if err := ar.DB.Select("id", "task_id", "author_id").First(&comment, commentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, 0, fmt.Errorf("comment %d not found", commentID)
+		}
+		return 0, 0, err
+	}
+	return comment.TaskID, comment.AuthorID, nil
+}
+
+func (ar *attachmentRepository) canUpload(ownerType models.AttachmentOwner, ownerID uint, requestUserID uint) bool {
+	switch ownerType {
+	case models.AttachmentTask:
+		return ar.permissionRepo.Authorize(requestUserID, models.TaskUpdate, utils.TaskResource(ownerID))
+	case models.AttachmentComment:
+		_, authorID, err := ar.commentTask(ownerID)
+		return err == nil && authorID == requestUserID
+	case models.AttachmentMessage:
+		return ar.permissionRepo.Authorize(requestUserID, models.ChatSend, utils.ProjectResource(ownerID))
+	case models.AttachmentProjectAvatar:
+		return ar.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(ownerID))
+	case models.AttachmentUserAvatar:
+		return ownerID == requestUserID
+	}
+	return false
+}
+
+func (ar *attachmentRepository) canView(ownerType models.AttachmentOwner, ownerID uint, requestUserID uint) bool {
+	switch ownerType {
+	case models.AttachmentTask:
+		return ar.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(ownerID))
+	case models.AttachmentComment:
+		taskID, _, err := ar.commentTask(ownerID)
+		return err == nil && ar.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(taskID))
+	case models.AttachmentMessage, models.AttachmentProjectAvatar:
+		return ar.permissionRepo.Authorize(requestUserID, models.ProjectView, utils.ProjectResource(ownerID))
+	case models.AttachmentUserAvatar:
+		// user avatars are public to every signed in user
+		return true
+	}
+	return false
+}
+
+// canDelete allows the uploader and whoever manages the owner to remove an attachment
+func (ar *attachmentRepository) canDelete(attachment *models.Attachment, requestUserID uint) bool {
+	if attachment.UploadedBy == requestUserID {
+		return true
+	}
+	switch attachment.OwnerType {
+	case models.AttachmentTask:
+		return ar.permissionRepo.Authorize(requestUserID, models.TaskDelete, utils.TaskResource(attachment.OwnerID))
+	case models.AttachmentComment:
+		taskID, _, err := ar.commentTask(attachment.OwnerID)
+		return err == nil && ar.permissionRepo.Authorize(requestUserID, models.TaskDelete, utils.TaskResource(taskID))
+	case models.AttachmentMessage, models.AttachmentProjectAvatar:
+		return ar.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(attachment.OwnerID))
+	case models.AttachmentUserAvatar:
+		return attachment.OwnerID == requestUserID
+	}
+	return false
+}
+
+// ownerArchived reports whether the owner lies in an archived team or project, user avatars never do
+func (ar *attachmentRepository) ownerArchived(ownerType models.AttachmentOwner, ownerID uint) bool {
+	switch ownerType {
+	case models.AttachmentTask:
+		return ar.permissionRepo.CheckArchived(utils.TaskResource(ownerID))
+	case models.AttachmentComment:
+		taskID, _, err := ar.commentTask(ownerID)
+		return err == nil && ar.permissionRepo.CheckArchived(utils.TaskResource(taskID))
+	case models.AttachmentMessage, models.AttachmentProjectAvatar:
+		return ar.permissionRepo.CheckArchived(utils.ProjectResource(ownerID))
+	}
+	return false
+}
+
+// detectContentType trusts the sniffed type of the content, the declared type is only used to tell
+// plain text formats apart since they all sniff as text/plain
+func detectContentType(head []byte, declared string) string {
+	detected, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	declaredType, _, err := mime.ParseMediaType(declared)
+	if err == nil && detected == "text/plain" && (strings.HasPrefix(declaredType, "text/") || declaredType == "application/json") {
+		return declaredType
+	}
+	return detected
+}
+
+func (ar *attachmentRepository) isAllowedType(contentType string) bool {
+	for _, allowed := range ar.cfg.AttachmentTypes {
+		if strings.TrimSpace(allowed) == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// spool copies an upload to a temporary file while hashing it, uploads over the size limit are rejected
+func (ar *attachmentRepository) spool(body io.Reader) (*os.File, string, int64, error) {
+	file, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to buffer upload: %w", err)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(body, ar.cfg.AttachmentMaxSize+1))
+	if err == nil && size > ar.cfg.AttachmentMaxSize {
+		err = fmt.Errorf("file is larger than %d bytes", ar.cfg.AttachmentMaxSize)
+	}
+	if err == nil && size == 0 {
+		err = errors.New("file is empty")
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, "", 0, err
+	}
+	return file, hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+func (ar *attachmentRepository) UploadAttachment(upload *AttachmentUpload, requestUserID uint) (*models.Attachment, error) {
+	if !models.IsValidAttachmentOwner(upload.OwnerType) {
+		return nil, fmt.Errorf("invalid owner type %s", upload.OwnerType)
+	}
+	if !ar.canUpload(upload.OwnerType, upload.OwnerID, requestUserID) {
+		return nil, errors.New("you don't have permission to attach files here")
+	}
+	if ar.ownerArchived(upload.OwnerType, upload.OwnerID) {
+		return nil, ErrArchived
+	}
+
+	file, hash, size, err := ar.spool(upload.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	contentType := detectContentType(head[:n], upload.DeclaredType)
+	if !ar.isAllowedType(contentType) {
+		return nil, fmt.Errorf("files of type %s are not allowed", contentType)
+	}
+	if isAvatar(upload.OwnerType) && !strings.HasPrefix(contentType, "image/") {
+		return nil, errors.New("avatars must be images")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	name := filepath.Base(strings.ReplaceAll(upload.Name, "\\", "/"))
+	if name == "." || name == "/" {
+		name = hash
+	}
+
+	attachment := models.Attachment{
+		BlobHash:    hash,
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		UploadedBy:  requestUserID,
+		OwnerType:   upload.OwnerType,
+		OwnerID:     upload.OwnerID,
+	}
+
+	// the content is stored after the commit so a slow store doesn't hold the blob row locked
+	var newBlob *models.Blob
+	err = ar.DB.Transaction(func(tx *gorm.DB) error {
+		// the blob row is locked so a concurrent delete can't remove the content we reuse
+		var blob models.Blob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("hash = ?", hash).First(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			blob = models.Blob{Hash: hash, Size: size, ContentType: contentType, StorageKey: blobKey(hash)}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&blob)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				newBlob = &blob
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if err := tx.Create(&attachment).Error; err != nil {
+			return err
+		}
+
+		url := attachmentContentURL(attachment.ID)
+		switch upload.OwnerType {
+		case models.AttachmentComment:
+			commentAttachment := models.CommentAttachment{CommentID: upload.OwnerID, AttachmentID: &attachment.ID, Name: name, URL: url}
+			return tx.Create(&commentAttachment).Error
+		case models.AttachmentProjectAvatar:
+			return tx.Model(&models.Project{ID: upload.OwnerID}).
+				Updates(map[string]interface{}{"image_url": url, "version": gorm.Expr("version + 1")}).Error
+		case models.AttachmentUserAvatar:
+			return tx.Model(&models.User{}).Where("id = ?", upload.OwnerID).Update("avatar_url", url).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+
+	if newBlob != nil {
+		if err := ar.store.Put(context.Background(), newBlob.StorageKey, file, size, contentType); err != nil {
+			// the attachment is taken back, its blob never got content so nothing is left in the store
+			if cleanupErr := ar.DB.Transaction(func(tx *gorm.DB) error {
+				_, err := ar.removeAttachment(tx, &attachment)
+				return err
+			}); cleanupErr != nil {
+				err = errors.Join(err, cleanupErr)
+			}
+			return nil, fmt.Errorf("failed to store attachment: %w", err)
+		}
+	}
+	return &attachment, nil
+}
+
+func (ar *attachmentRepository) loadAttachment(attachmentID uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	if err := ar.DB.First(&attachment, attachmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("attachment %d not found", attachmentID)
+		}
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (ar *attachmentRepository) GetAttachment(attachmentID uint, requestUserID uint) (*models.Attachment, error) {
+	attachment, err := ar.loadAttachment(attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if !ar.canView(attachment.OwnerType, attachment.OwnerID, requestUserID) {
+		return nil, errors.New("you don't have access to this attachment")
+	}
+	return attachment, nil
+}
+
+func (ar *attachmentRepository) GetOwnerAttachments(ownerType models.AttachmentOwner, ownerID uint, requestUserID uint) ([]models.Attachment, error) {
+	if !models.IsValidAttachmentOwner(ownerType) {
+		return nil, fmt.Errorf("invalid owner type %s", ownerType)
+	}
+	if !ar.canView(ownerType, ownerID, requestUserID) {
+		return nil, errors.New("you don't have access to these attachments")
+	}
+
+	attachments := []models.Attachment{}
+	err := ar.DB.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).Order("created_at").Find(&attachments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+func (ar *attachmentRepository) openContent(attachment *models.Attachment) (io.ReadCloser, error) {
+	var blob models.Blob
+	if err := ar.DB.Where("hash = ?", attachment.BlobHash).First(&blob).Error; err != nil {
+		return nil, fmt.Errorf("failed to find content of attachment %d: %w", attachment.ID, err)
+	}
+	return ar.store.Get(context.Background(), blob.StorageKey)
+}
+
+func (ar *attachmentRepository) OpenAttachment(attachmentID uint, requestUserID uint) (*models.Attachment, io.ReadCloser, error) {
+	attachment, err := ar.GetAttachment(attachmentID, requestUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := ar.openContent(attachment)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
+
+// DeleteAttachment removes an attachment, its blob goes too once nothing references it
+func (ar *attachmentRepository) DeleteAttachment(attachmentID uint, requestUserID uint) (uint, error) {
+	attachment, err := ar.loadAttachment(attachmentID)
+	if err != nil {
+		return 0, err
+	}
+	if !ar.canDelete(attachment, requestUserID) {
+		return 0, errors.New("you don't have permission to delete this attachment")
+	}
+	if ar.ownerArchived(attachment.OwnerType, attachment.OwnerID) {
+		return 0, ErrArchived
+	}
+
+	var storageKey string
+	err = ar.DB.Transaction(func(tx *gorm.DB) error {
+		storageKey, err = ar.removeAttachment(tx, attachment)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete attachment %d: %w", attachmentID, err)
+	}
+
+	// the content goes once the rows are committed, a failed commit keeps it for the rows left
+	if storageKey != "" {
+		if err := ar.store.Delete(context.Background(), storageKey); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return 0, fmt.Errorf("failed to delete content of attachment %d: %w", attachmentID, err)
+		}
+	}
+	return attachment.ID, nil
+}
+
+// removeAttachment deletes an attachment with its references and its blob row once nothing else
+// references it, the storage key of a removed blob is returned for its content to be deleted
+func (ar *attachmentRepository) removeAttachment(tx *gorm.DB, attachment *models.Attachment) (string, error) {
+	if err := tx.Delete(attachment).Error; err != nil {
+		return "", err
+	}
+
+	url := attachmentContentURL(attachment.ID)
+	switch attachment.OwnerType {
+	case models.AttachmentComment:
+		if err := tx.Where("attachment_id = ?", attachment.ID).Delete(&models.CommentAttachment{}).Error; err != nil {
+			return "", err
+		}
+	case models.AttachmentProjectAvatar:
+		err := tx.Model(&models.Project{ID: attachment.OwnerID}).Where("image_url = ?", url).
+			Updates(map[string]interface{}{"image_url": "", "version": gorm.Expr("version + 1")}).Error
+		if err != nil {
+			return "", err
+		}
+	case models.AttachmentUserAvatar:
+		if err := tx.Model(&models.User{}).Where("id = ? AND avatar_url = ?", attachment.OwnerID, url).Update("avatar_url", "").Error; err != nil {
+			return "", err
+		}
+	}
+
+	var blob models.Blob
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("hash = ?", attachment.BlobHash).First(&blob).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	var references int64
+	if err := tx.Model(&models.Attachment{}).Where("blob_hash = ?", blob.Hash).Count(&references).Error; err != nil {
+		return "", err
+	}
+	if references > 0 {
+		return "", nil
+	}
+	if err := tx.Delete(&blob).Error; err != nil {
+		return "", err
+	}
+	return blob.StorageKey, nil
+}
+
+func (ar *attachmentRepository) signature(attachmentID uint, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(ar.cfg.AuthorizationSecret))
+	mac.Write([]byte(strconv.FormatUint(uint64(attachmentID), 10) + "|" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (ar *attachmentRepository) SignedURL(attachmentID uint, requestUserID uint) (string, time.Time, error) {
+	if _, err := ar.GetAttachment(attachmentID, requestUserID); err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(ar.cfg.AttachmentURLTTL)
+	expires := expiresAt.Unix()
+	url := fmt.Sprintf("/files/%d?expires=%d&signature=%s", attachmentID, expires, ar.signature(attachmentID, expires))
+	return url, expiresAt, nil
+}
+
+func (ar *attachmentRepository) OpenSigned(attachmentID uint, expires int64, signature string) (*models.Attachment, io.ReadCloser, error) {
+	if time.Now().Unix() > expires || !hmac.Equal([]byte(signature), []byte(ar.signature(attachmentID, expires))) {
+		return nil, nil, ErrInvalidSignature
+	}
+
+	attachment, err := ar.loadAttachment(attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := ar.openContent(attachment)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
@@ -17,6 +17,7 @@ type BearerRepository interface {
 	RefreshTokens(refreshToken string) (string, string, error)
 	BlacklistToken(tokenString string, ttl time.Duration) error
 	IsTokenBlacklisted(tokenString string) (bool, error)
+	RevokeSessions(userID uint) error
 }
 
 type jwtRepository struct {
@@ -48,16 +49,25 @@ func (jr *jwtRepository) AuthorizeBearerUser(tokenString string) (*userdto.UserC
 		return nil, err
 	}
 	claims, ok := token.Claims.(*userdto.UserClaims)
-	if ok && token.Valid {
-		return claims, nil
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if err := jr.checkSessionVersion(claims); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("invalid token")
+	return claims, nil
 }
 
 func (jr *jwtRepository) GenerateTokens(userID uint, username string) (string, string, error) {
+	sessionVersion, err := jr.redis.GetSessionVersion(userID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get session version: %w", err)
+	}
+
 	accessClaims := userdto.UserClaims{
-		UserID:   userID,
-		Username: username,
+		UserID:         userID,
+		Username:       username,
+		SessionVersion: sessionVersion,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(time.Minute * 15).Unix(),
 			IssuedAt:  time.Now().Unix(),
@@ -65,8 +75,9 @@ func (jr *jwtRepository) GenerateTokens(userID uint, username string) (string, s
 		},
 	}
 	refreshClaims := userdto.UserClaims{
-		UserID:   userID,
-		Username: username,
+		UserID:         userID,
+		Username:       username,
+		SessionVersion: sessionVersion,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(time.Hour * 24).Unix(),
 			IssuedAt:  time.Now().Unix(),
@@ -107,6 +118,9 @@ func (jr *jwtRepository) RefreshTokens(refreshToken string) (string, string, err
 	if !ok || !token.Valid {
 		return "", "", errors.New("invalid refresh token")
 	}
+	if err := jr.checkSessionVersion(claims); err != nil {
+		return "", "", err
+	}
 
 	return jr.GenerateTokens(claims.UserID, claims.Username)
 }
@@ -118,3 +132,18 @@ func (jr *jwtRepository) BlacklistToken(tokenString string, ttl time.Duration) e
 func (jr *jwtRepository) IsTokenBlacklisted(tokenString string) (bool, error) {
 	return jr.redis.IsTokenBlacklisted(tokenString)
 }
+
+func (jr *jwtRepository) RevokeSessions(userID uint) error {
+	return jr.redis.RevokeSessions(userID)
+}
+
+func (jr *jwtRepository) checkSessionVersion(claims *userdto.UserClaims) error {
+	version, err := jr.redis.GetSessionVersion(claims.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to check session version: %w", err)
+	}
+	if claims.SessionVersion != version {
+		return errors.New("session has been revoked")
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package lockout
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/env"
+	"mizito/internal/mailer"
+	"mizito/pkg/models"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	userScope = "user"
+	ipScope   = "ip"
+
+	// strikes are remembered for a day, so repeated lockouts keep growing exponentially
+	strikesTTL = 24 * time.Hour
+)
+
+// LockoutRepository tracks failed login attempts per username and per IP address
+// and locks them out temporarily with an exponentially growing duration.
+type LockoutRepository interface {
+	// CheckLocked returns the remaining lockout duration for the username or ip, zero when not locked.
+	CheckLocked(username string, ip string) (time.Duration, error)
+	// RegisterFailure records a failed attempt and returns the lockout duration if it caused a lockout.
+	RegisterFailure(username string, ip string) (time.Duration, error)
+	ResetFailures(username string) error
+	Unlock(username string, actorID uint) error
+	UnlockWithToken(token string) (string, error)
+}
+
+type lockoutRepository struct {
+	redis  *redis.Client
+	db     *gorm.DB
+	mailer mailer.Mailer
+	cfg    *env.Config
+}
+
+func NewLockoutRepository(redis *database.RedisHandler, db *database.DatabaseHandler, mailer mailer.Mailer, cfg *env.Config) LockoutRepository {
+	return &lockoutRepository{
+		redis:  redis.Client,
+		db:     db.DB,
+		mailer: mailer,
+		cfg:    cfg,
+	}
+}
+
+func failuresKey(scope string, id string) string {
+	return fmt.Sprintf("login:failures:%s:%s", scope, id)
+}
+
+func lockKey(scope string, id string) string {
+	return fmt.Sprintf("login:lock:%s:%s", scope, id)
+}
+
+func strikesKey(scope string, id string) string {
+	return fmt.Sprintf("login:strikes:%s:%s", scope, id)
+}
+
+func unlockTokenKey(token string) string {
+	return "login:unlock:" + token
+}
+
+func (lr *lockoutRepository) CheckLocked(username string, ip string) (time.Duration, error) {
+	ctx := context.Background()
+
+	var remaining time.Duration
+	for _, key := range []string{lockKey(userScope, username), lockKey(ipScope, ip)} {
+		ttl, err := lr.redis.PTTL(ctx, key).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to check lockout: %w", err)
+		}
+		// negative values mean the key doesn't exist or has no expiry
+		if ttl > remaining {
+			remaining = ttl
+		}
+	}
+	return remaining, nil
+}
+
+func (lr *lockoutRepository) RegisterFailure(username string, ip string) (time.Duration, error) {
+	userLock, err := lr.registerScopeFailure(userScope, username, lr.cfg.LoginMaxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	if userLock > 0 {
+		lr.recordEvent(models.LoginLockout, username, ip, 0, fmt.Sprintf("username locked for %s", userLock))
+		lr.sendUnlockEmail(username, userLock)
+	}
+
+	ipLock, err := lr.registerScopeFailure(ipScope, ip, lr.cfg.LoginMaxAttemptsPerIP)
+	if err != nil {
+		return 0, err
+	}
+	if ipLock > 0 {
+		lr.recordEvent(models.LoginLockout, username, ip, 0, fmt.Sprintf("ip locked for %s", ipLock))
+	}
+
+	return max(userLock, ipLock), nil
+}
+
+func (lr *lockoutRepository) registerScopeFailure(scope string, id string, maxAttempts int64) (time.Duration, error) {
+	ctx := context.Background()
+
+	attempts, err := lr.redis.Incr(ctx, failuresKey(scope, id)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record failed attempt: %w", err)
+	}
+	if attempts == 1 {
+		if err := lr.redis.Expire(ctx, failuresKey(scope, id), lr.cfg.LoginAttemptWindow).Err(); err != nil {
+			return 0, fmt.Errorf("failed to set attempt window: %w", err)
+		}
+	}
+	if attempts < maxAttempts {
+		return 0, nil
+	}
+
+	strikes, err := lr.redis.Incr(ctx, strikesKey(scope, id)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record lockout: %w", err)
+	}
+	if err := lr.redis.Expire(ctx, strikesKey(scope, id), strikesTTL).Err(); err != nil {
+		return 0, fmt.Errorf("failed to set lockout expiry: %w", err)
+	}
+
+	duration := lr.lockoutDuration(strikes)
+	pipe := lr.redis.TxPipeline()
+	pipe.Set(ctx, lockKey(scope, id), "true", duration)
+	pipe.Del(ctx, failuresKey(scope, id))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to lock %s %s: %w", scope, id, err)
+	}
+	return duration, nil
+}
+
+// lockoutDuration doubles the base duration for every lockout within the strikes window.
+func (lr *lockoutRepository) lockoutDuration(strikes int64) time.Duration {
+	duration := lr.cfg.LoginLockoutBase
+	for i := int64(1); i < strikes && duration < lr.cfg.LoginLockoutMax; i++ {
+		duration *= 2
+	}
+	return min(duration, lr.cfg.LoginLockoutMax)
+}
+
+func (lr *lockoutRepository) ResetFailures(username string) error {
+	if err := lr.redis.Del(context.Background(), failuresKey(userScope, username)).Err(); err != nil {
+		return fmt.Errorf("failed to reset failed attempts: %w", err)
+	}
+	return nil
+}
+
+func (lr *lockoutRepository) Unlock(username string, actorID uint) error {
+	ctx := context.Background()
+
+	err := lr.redis.Del(ctx,
+		lockKey(userScope, username),
+		failuresKey(userScope, username),
+		strikesKey(userScope, username),
+	).Err()
+	if err != nil {
+		return fmt.Errorf("failed to unlock %s: %w", username, err)
+	}
+
+	lr.recordEvent(models.AccountUnlocked, username, "", actorID, "")
+	return nil
+}
+
+func (lr *lockoutRepository) UnlockWithToken(token string) (string, error) {
+	username, err := lr.redis.GetDel(context.Background(), unlockTokenKey(token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", errors.New("invalid or expired unlock token")
+	} else if err != nil {
+		return "", fmt.Errorf("failed to redeem unlock token: %w", err)
+	}
+
+	if err := lr.Unlock(username, 0); err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+func (lr *lockoutRepository) sendUnlockEmail(username string, duration time.Duration) {
+	var user models.User
+	if err := lr.db.Where("username = ?", username).First(&user).Error; err != nil {
+		// unknown usernames get locked as well, but there is nobody to notify
+		return
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		fmt.Printf("failed to generate unlock token, err: %s\n", err.Error())
+		return
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	if err := lr.redis.Set(context.Background(), unlockTokenKey(token), username, lr.cfg.UnlockTokenTTL).Err(); err != nil {
+		fmt.Printf("failed to store unlock token, err: %s\n", err.Error())
+		return
+	}
+
+	body := fmt.Sprintf(
+		"Your account has been locked for %s after too many failed login attempts.\n"+
+			"If this was you, you can unlock it right away using the link below:\n%s/api/auth/unlock?token=%s\n",
+		duration, lr.cfg.AppBaseURL, token,
+	)
+	if err := lr.mailer.Send(user.Email, "Your mizito account has been locked", body); err != nil {
+		fmt.Printf("failed to send unlock email, err: %s\n", err.Error())
+	}
+}
+
+func (lr *lockoutRepository) recordEvent(eventType models.SecurityEventType, username string, ip string, actorID uint, detail string) {
+	event := models.SecurityEvent{
+		Type:     eventType,
+		Username: username,
+		IP:       ip,
+		ActorID:  actorID,
+		Detail:   detail,
+	}
+	if err := lr.db.Create(&event).Error; err != nil {
+		fmt.Printf("failed to store security event, err: %s\n", err.Error())
+	}
+}
@@ -0,0 +1,115 @@
+package verification
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"mizito/internal/database"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+type Purpose string
+
+const (
+	PasswordReset     Purpose = "password_reset"
+	EmailVerification Purpose = "email_verification"
+)
+
+// VerificationRepository issues signed, expiring tokens that can only be consumed once.
+type VerificationRepository interface {
+	// IssueToken signs a token for the user and the email address it is sent to.
+	IssueToken(userID uint, email string, purpose Purpose, ttl time.Duration) (string, error)
+	// ConsumeToken validates the token for the given purpose and returns the user and the email
+	// address it was issued for.
+	ConsumeToken(token string, purpose Purpose) (uint, string, error)
+}
+
+// tokenClaims binds a token to the address it was sent to, so it can't confirm an address the
+// user switched to after requesting it
+type tokenClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+type verificationRepository struct {
+	secret string
+	redis  *redis.Client
+}
+
+func NewVerificationRepository(secret string, redis *database.RedisHandler) VerificationRepository {
+	return &verificationRepository{
+		secret: secret,
+		redis:  redis.Client,
+	}
+}
+
+func tokenKey(purpose Purpose, tokenID string) string {
+	return fmt.Sprintf("verification:%s:%s", purpose, tokenID)
+}
+
+func (vr *verificationRepository) IssueToken(userID uint, email string, purpose Purpose, ttl time.Duration) (string, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+	tokenID := hex.EncodeToString(idBytes)
+
+	claims := tokenClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Audience:  jwt.ClaimStrings{string(purpose)},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "mizito",
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(vr.secret))
+	if err != nil {
+		return "", err
+	}
+
+	// the id is kept until the token expires, consuming it removes the id so the token can't be reused
+	if err := vr.redis.Set(context.Background(), tokenKey(purpose, tokenID), userID, ttl).Err(); err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return signed, nil
+}
+
+func (vr *verificationRepository) ConsumeToken(tokenString string, purpose Purpose) (uint, string, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &tokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(vr.secret), nil
+	})
+	if err != nil {
+		return 0, "", errors.New("invalid or expired token")
+	}
+	claims, ok := token.Claims.(*tokenClaims)
+	if !ok || !token.Valid || !claims.VerifyAudience(string(purpose), true) || claims.Email == "" {
+		return 0, "", errors.New("invalid or expired token")
+	}
+
+	err = vr.redis.GetDel(context.Background(), tokenKey(purpose, claims.ID)).Err()
+	if errors.Is(err, redis.Nil) {
+		return 0, "", errors.New("token has already been used")
+	} else if err != nil {
+		return 0, "", fmt.Errorf("failed to consume token: %w", err)
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil {
+		return 0, "", errors.New("invalid or expired token")
+	}
+	return uint(userID), claims.Email, nil
+}
@@ -0,0 +1,291 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+	"mizito/pkg/models/dtos"
+
+	"gorm.io/gorm"
+)
+
+const MaxBulkTasks = 500
+
+type BulkOperationType string
+
+const (
+	BulkUpdate BulkOperationType = "update"
+	BulkAssign BulkOperationType = "assign"
+	BulkMove   BulkOperationType = "move"
+	BulkDelete BulkOperationType = "delete"
+)
+
+// bulkPermissions are the permissions an operation needs on every task it's applied to
+var bulkPermissions = map[BulkOperationType]models.Permission{
+	BulkUpdate: models.TaskUpdate,
+	BulkAssign: models.TaskAssign,
+	BulkMove:   models.TaskUpdate,
+	BulkDelete: models.TaskDelete,
+}
+
+// BulkOperation is applied to every task of a bulk request. Fields holds the changes of an
+// update, UserID is the member an assign adds and StatusID the column a move puts the tasks
+// at the end of.
+type BulkOperation struct {
+	Type     BulkOperationType `json:"type"`
+	Fields   *TaskPatch        `json:"fields,omitempty"`
+	UserID   uint              `json:"user_id,omitempty"`
+	StatusID uint              `json:"status_id,omitempty"`
+}
+
+// BulkTaskRequest applies the operations in order to each of the tasks. an atomic request is
+// applied in one transaction that is rolled back when any task fails, otherwise every task is
+// applied on its own.
+type BulkTaskRequest struct {
+	TaskIDs    []uint          `json:"task_ids"`
+	Operations []BulkOperation `json:"operations"`
+	Atomic     bool            `json:"atomic"`
+}
+
+type BulkTaskResult struct {
+	TaskID uint   `json:"task_id"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BulkTaskResults struct {
+	// Applied is false when an atomic request was rolled back
+	Applied bool             `json:"applied"`
+	Results []BulkTaskResult `json:"results"`
+}
+
+// BulkTaskChange is the data of the event published once for a bulk request
+type BulkTaskChange struct {
+	Operations []BulkOperationType `json:"operations"`
+	TaskIDs    []uint              `json:"task_ids"`
+	ProjectIDs []uint              `json:"project_ids"`
+	ActorID    uint                `json:"actor_id"`
+	RequestID  string              `json:"request_id,omitempty"`
+}
+
+type BulkTaskRepository interface {
+	// ApplyBulk applies a bulk request with a result for every task, the changed tasks are
+	// announced to the members of their projects with one TasksChanged event
+	ApplyBulk(request *BulkTaskRequest, requestUserID uint) (*BulkTaskResults, error)
+	// WithRequest returns a copy of the repository that records its activities for the request
+	WithRequest(request RequestInfo) BulkTaskRepository
+}
+
+type bulkTaskRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+	redis          *database.RedisHandler
+	request        RequestInfo
+}
+
+func NewBulkTaskRepository(postgreSql *database.DatabaseHandler, redis *database.RedisHandler) BulkTaskRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &bulkTaskRepository{DB: postgreSql.DB, permissionRepo: permissionRepo, redis: redis}
+}
+
+func (br *bulkTaskRepository) WithRequest(request RequestInfo) BulkTaskRepository {
+	scoped := *br
+	scoped.request = request
+	return &scoped
+}
+
+// errBulkRolledBack rolls back an atomic request once one of its tasks failed
+var errBulkRolledBack = errors.New("bulk request rolled back")
+
+func validateBulkRequest(request *BulkTaskRequest) error {
+	if len(request.TaskIDs) == 0 {
+		return errors.New("no task IDs provided")
+	}
+	if len(request.TaskIDs) > MaxBulkTasks {
+		return fmt.Errorf("a bulk request can change at most %d tasks", MaxBulkTasks)
+	}
+	if len(request.Operations) == 0 {
+		return errors.New("no operations provided")
+	}
+	for i, operation := range request.Operations {
+		switch operation.Type {
+		case BulkUpdate:
+			if operation.Fields == nil {
+				return errors.New("update operations require fields")
+			}
+		case BulkAssign:
+			if operation.UserID == 0 {
+				return errors.New("assign operations require a user ID")
+			}
+		case BulkMove:
+			if operation.StatusID == 0 {
+				return errors.New("move operations require a status ID")
+			}
+		case BulkDelete:
+			if i != len(request.Operations)-1 {
+				return errors.New("delete must be the last operation")
+			}
+		default:
+			return fmt.Errorf("unknown operation %s", operation.Type)
+		}
+	}
+	return nil
+}
+
+func (br *bulkTaskRepository) ApplyBulk(request *BulkTaskRequest, requestUserID uint) (*BulkTaskResults, error) {
+	if err := validateBulkRequest(request); err != nil {
+		return nil, err
+	}
+
+	taskIDs := make([]uint, 0, len(request.TaskIDs))
+	seen := make(map[uint]bool)
+	for _, taskID := range request.TaskIDs {
+		if !seen[taskID] {
+			seen[taskID] = true
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+
+	// the projects are looked up front, deleted tasks can't be resolved afterwards
+	var tasks []models.Task
+	if err := br.DB.Select("id", "project_id").Where("id IN ?", taskIDs).Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	taskProjects := make(map[uint]uint, len(tasks))
+	for _, task := range tasks {
+		taskProjects[task.ID] = task.ProjectID
+	}
+
+	results := &BulkTaskResults{Results: make([]BulkTaskResult, len(taskIDs))}
+	apply := func(tx *gorm.DB, i int) bool {
+		taskID := taskIDs[i]
+		err := br.authorize(taskID, taskProjects, request.Operations, requestUserID)
+		if err == nil {
+			// a savepoint inside an atomic request, a transaction of its own otherwise
+			err = tx.Transaction(func(tx *gorm.DB) error {
+				return br.applyOperations(tx, taskID, request.Operations, requestUserID)
+			})
+		}
+		results.Results[i] = BulkTaskResult{TaskID: taskID, OK: err == nil}
+		if err != nil {
+			results.Results[i].Error = err.Error()
+		}
+		return err == nil
+	}
+
+	if request.Atomic {
+		err := br.DB.Transaction(func(tx *gorm.DB) error {
+			failed := false
+			for i := range taskIDs {
+				if !apply(tx, i) {
+					failed = true
+				}
+			}
+			if failed {
+				return errBulkRolledBack
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errBulkRolledBack) {
+			return nil, fmt.Errorf("failed to apply bulk request: %w", err)
+		}
+		results.Applied = err == nil
+		if !results.Applied {
+			// nothing was kept, tasks that went through are reported as not applied
+			for i := range results.Results {
+				results.Results[i].OK = false
+			}
+		}
+	} else {
+		for i := range taskIDs {
+			if apply(br.DB, i) {
+				results.Applied = true
+			}
+		}
+	}
+
+	br.publish(request.Operations, results, taskProjects, requestUserID)
+	return results, nil
+}
+
+// authorize checks every operation of the request against the task before any of them is applied
+func (br *bulkTaskRepository) authorize(taskID uint, taskProjects map[uint]uint, operations []BulkOperation, requestUserID uint) error {
+	if _, ok := taskProjects[taskID]; !ok {
+		return errors.New("task not found")
+	}
+	for _, operation := range operations {
+		if !br.permissionRepo.Authorize(requestUserID, bulkPermissions[operation.Type], utils.TaskResource(taskID)) {
+			return fmt.Errorf("you don't have permission to %s this task", operation.Type)
+		}
+	}
+	if br.permissionRepo.CheckArchived(utils.TaskResource(taskID)) {
+		return ErrArchived
+	}
+	return nil
+}
+
+func (br *bulkTaskRepository) applyOperations(tx *gorm.DB, taskID uint, operations []BulkOperation, requestUserID uint) error {
+	for _, operation := range operations {
+		var err error
+		switch operation.Type {
+		case BulkUpdate:
+			_, err = updateTask(tx, br.request, taskID, operation.Fields, 0, requestUserID)
+		case BulkAssign:
+			err = assignTask(tx, br.request, operation.UserID, taskID, requestUserID)
+		case BulkMove:
+			_, err = moveTask(tx, br.request, taskID, operation.StatusID, 0, 0, requestUserID)
+		case BulkDelete:
+			err = deleteTask(tx, br.request, taskID, requestUserID)
+		}
+		if err != nil {
+			return fmt.Errorf("%s failed: %w", operation.Type, err)
+		}
+	}
+	return nil
+}
+
+// publish announces the applied tasks to the members of their projects with a single event
+func (br *bulkTaskRepository) publish(operations []BulkOperation, results *BulkTaskResults, taskProjects map[uint]uint, requestUserID uint) {
+	if !results.Applied {
+		return
+	}
+
+	change := BulkTaskChange{ActorID: requestUserID, RequestID: br.request.RequestID}
+	for _, operation := range operations {
+		change.Operations = append(change.Operations, operation.Type)
+	}
+	seen := make(map[uint]bool)
+	for _, result := range results.Results {
+		if !result.OK {
+			continue
+		}
+		change.TaskIDs = append(change.TaskIDs, result.TaskID)
+		if projectID := taskProjects[result.TaskID]; !seen[projectID] {
+			seen[projectID] = true
+			change.ProjectIDs = append(change.ProjectIDs, projectID)
+		}
+	}
+
+	var recipients []uint
+	err := br.DB.Model(&models.ProjectMember{}).
+		Where("project_id IN ?", change.ProjectIDs).
+		Distinct().
+		Pluck("user_id", &recipients).Error
+	if err != nil {
+		fmt.Println("failed to get project members for bulk event:", err)
+		return
+	}
+
+	data, err := json.Marshal(change)
+	if err != nil {
+		fmt.Println("failed to marshal bulk event:", err)
+		return
+	}
+	if err := br.redis.PublishEvent(dtos.Event{EventType: dtos.TasksChanged, Data: data, Recipients: recipients}); err != nil {
+		fmt.Println(err.Error())
+	}
+}
@@ -0,0 +1,357 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+	"mizito/pkg/models/dtos"
+
+	"gorm.io/gorm"
+)
+
+const maxCommentLength = 10000
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.\-]+)`)
+
+type CommentRepository interface {
+	GetTaskComments(taskID uint, requestUserID uint) ([]models.TaskComment, error)
+	// CreateComment stores a comment, resolves its @mentions and notifies the task members
+	CreateComment(comment *models.TaskComment, requestUserID uint) (*models.TaskComment, error)
+	UpdateComment(commentID uint, body string, attachments []models.CommentAttachment, requestUserID uint) (*models.TaskComment, error)
+	DeleteComment(commentID uint, requestUserID uint) (uint, error)
+	GetCommentHistory(commentID uint, requestUserID uint) ([]models.CommentRevision, error)
+}
+
+type commentRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+	redis          *database.RedisHandler
+}
+
+func NewCommentRepository(postgreSql *database.DatabaseHandler, redis *database.RedisHandler) CommentRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &commentRepository{DB: postgreSql.DB, permissionRepo: permissionRepo, redis: redis}
+}
+
+func validateComment(body string) error {
+	if strings.TrimSpace(body) == "" {
+		return errors.New("comment body is required")
+	}
+	if len(body) > maxCommentLength {
+		return fmt.Errorf("comment body can't be longer than %d characters", maxCommentLength)
+	}
+	return nil
+}
+
+// resolveAttachments checks that the attachments given for a comment reference files uploaded to
+// the comment itself or to its task, their names and urls are taken from the uploaded files
+func (cr *commentRepository) resolveAttachments(tx *gorm.DB, taskID uint, commentID uint, attachments []models.CommentAttachment) ([]models.CommentAttachment, error) {
+	var ids []uint
+	seen := make(map[uint]bool)
+	for _, attachment := range attachments {
+		if attachment.AttachmentID == nil {
+			return nil, errors.New("attachments must reference an uploaded file")
+		}
+		if !seen[*attachment.AttachmentID] {
+			seen[*attachment.AttachmentID] = true
+			ids = append(ids, *attachment.AttachmentID)
+		}
+	}
+	if len(ids) == 0 {
+		return []models.CommentAttachment{}, nil
+	}
+
+	var files []models.Attachment
+	err := tx.Where("id IN ?", ids).
+		Where(cr.DB.Where("owner_type = ? AND owner_id = ?", models.AttachmentTask, taskID).
+			Or("owner_type = ? AND owner_id = ?", models.AttachmentComment, commentID)).
+		Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find attachments: %w", err)
+	}
+	if len(files) != len(ids) {
+		return nil, errors.New("attachments must belong to the comment or its task")
+	}
+
+	resolved := make([]models.CommentAttachment, 0, len(files))
+	for i := range files {
+		resolved = append(resolved, models.CommentAttachment{
+			CommentID:    commentID,
+			AttachmentID: &files[i].ID,
+			Name:         files[i].Name,
+			URL:          attachmentContentURL(files[i].ID),
+		})
+	}
+	return resolved, nil
+}
+
+// resolveMentions finds the users mentioned in a body who can see the task
+func (cr *commentRepository) resolveMentions(tx *gorm.DB, taskID uint, body string) ([]models.CommentMention, error) {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		if !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	var users []models.User
+	if err := tx.Select("id").Where("username IN ?", usernames).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to resolve mentions: %w", err)
+	}
+
+	var mentions []models.CommentMention
+	for _, user := range users {
+		if cr.permissionRepo.Authorize(user.ID, models.TaskView, utils.TaskResource(taskID)) {
+			mentions = append(mentions, models.CommentMention{UserID: user.ID})
+		}
+	}
+	return mentions, nil
+}
+
+// publish delivers a comment event to the task members, the mentioned users and the author
+func (cr *commentRepository) publish(eventType dtos.EventType, comment *models.TaskComment) {
+	var recipients []uint
+	if err := cr.DB.Table("task_members").Where("task_id = ?", comment.TaskID).Pluck("user_id", &recipients).Error; err != nil {
+		fmt.Println("failed to get task members for comment event:", err)
+		return
+	}
+	seen := make(map[uint]bool)
+	for _, id := range recipients {
+		seen[id] = true
+	}
+	for _, mention := range comment.Mentions {
+		if !seen[mention.UserID] {
+			seen[mention.UserID] = true
+			recipients = append(recipients, mention.UserID)
+		}
+	}
+	if !seen[comment.AuthorID] {
+		recipients = append(recipients, comment.AuthorID)
+	}
+
+	data, err := json.Marshal(comment)
+	if err != nil {
+		fmt.Println("failed to marshal comment event:", err)
+		return
+	}
+	if err := cr.redis.PublishEvent(dtos.Event{EventType: eventType, Data: data, Recipients: recipients}); err != nil {
+		fmt.Println(err.Error())
+	}
+}
+
+func (cr *commentRepository) GetTaskComments(taskID uint, requestUserID uint) ([]models.TaskComment, error) {
+	if !cr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(taskID)) {
+		return nil, errors.New("you don't have access to the task")
+	}
+
+	comments := []models.TaskComment{}
+	err := cr.DB.Preload("Mentions").Preload("Attachments").
+		Where("task_id = ?", taskID).
+		Order("created_at").
+		Find(&comments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments of task %d: %w", taskID, err)
+	}
+	return comments, nil
+}
+
+func (cr *commentRepository) CreateComment(comment *models.TaskComment, requestUserID uint) (*models.TaskComment, error) {
+	if !cr.permissionRepo.Authorize(requestUserID, models.ChatSend, utils.TaskResource(comment.TaskID)) {
+		return nil, errors.New("you don't have permission to comment on this task")
+	}
+	if cr.permissionRepo.CheckArchived(utils.TaskResource(comment.TaskID)) {
+		return nil, ErrArchived
+	}
+	if err := validateComment(comment.Body); err != nil {
+		return nil, err
+	}
+
+	comment.ID = 0
+	comment.AuthorID = requestUserID
+	comment.EditedAt = nil
+
+	// files uploaded to the comment need it to exist first, only task files can be referenced here
+	attachments, err := cr.resolveAttachments(cr.DB, comment.TaskID, 0, comment.Attachments)
+	if err != nil {
+		return nil, err
+	}
+	comment.Attachments = attachments
+
+	mentions, err := cr.resolveMentions(cr.DB, comment.TaskID, comment.Body)
+	if err != nil {
+		return nil, err
+	}
+	comment.Mentions = mentions
+
+	if err := cr.DB.Create(comment).Error; err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+
+	cr.publish(dtos.CommentCreated, comment)
+	return comment, nil
+}
+
+func (cr *commentRepository) loadComment(commentID uint) (*models.TaskComment, error) {
+	var comment models.TaskComment
+	if err := cr.DB.Preload("Mentions").Preload("Attachments").First(&comment, commentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("comment %d not found", commentID)
+		}
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (cr *commentRepository) UpdateComment(commentID uint, body string, attachments []models.CommentAttachment, requestUserID uint) (*models.TaskComment, error) {
+	comment, err := cr.loadComment(commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.AuthorID != requestUserID {
+		return nil, errors.New("you can only edit your own comments")
+	}
+	if cr.permissionRepo.CheckArchived(utils.TaskResource(comment.TaskID)) {
+		return nil, ErrArchived
+	}
+	if err := validateComment(body); err != nil {
+		return nil, err
+	}
+
+	mentions, err := cr.resolveMentions(cr.DB, comment.TaskID, body)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = cr.DB.Transaction(func(tx *gorm.DB) error {
+		revision := models.CommentRevision{CommentID: comment.ID, Body: comment.Body, EditedBy: requestUserID}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(comment).Updates(map[string]interface{}{"body": body, "edited_at": now}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(&models.CommentMention{}).Error; err != nil {
+			return err
+		}
+		for i := range mentions {
+			mentions[i].CommentID = comment.ID
+		}
+		if len(mentions) > 0 {
+			if err := tx.Create(&mentions).Error; err != nil {
+				return err
+			}
+		}
+
+		// attachments are only replaced when given. files uploaded to the comment stay attached
+		// until they are deleted, only the references to task files are replaced
+		if attachments != nil {
+			resolved, err := cr.resolveAttachments(tx, comment.TaskID, comment.ID, attachments)
+			if err != nil {
+				return err
+			}
+			uploaded := tx.Model(&models.Attachment{}).Select("id").
+				Where("owner_type = ? AND owner_id = ?", models.AttachmentComment, comment.ID)
+			if err := tx.Where("comment_id = ? AND (attachment_id IS NULL OR attachment_id NOT IN (?))", comment.ID, uploaded).
+				Delete(&models.CommentAttachment{}).Error; err != nil {
+				return err
+			}
+
+			var kept []uint
+			if err := tx.Model(&models.CommentAttachment{}).Where("comment_id = ?", comment.ID).Pluck("attachment_id", &kept).Error; err != nil {
+				return err
+			}
+			isKept := make(map[uint]bool)
+			for _, id := range kept {
+				isKept[id] = true
+			}
+			var added []models.CommentAttachment
+			for _, attachment := range resolved {
+				if !isKept[*attachment.AttachmentID] {
+					added = append(added, attachment)
+				}
+			}
+			if len(added) > 0 {
+				if err := tx.Create(&added).Error; err != nil {
+					return err
+				}
+			}
+
+			comment.Attachments = nil
+			if err := tx.Where("comment_id = ?", comment.ID).Order("id").Find(&comment.Attachments).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comment %d: %w", commentID, err)
+	}
+
+	comment.Body = body
+	comment.EditedAt = &now
+	comment.Mentions = mentions
+	cr.publish(dtos.CommentUpdated, comment)
+	return comment, nil
+}
+
+func (cr *commentRepository) DeleteComment(commentID uint, requestUserID uint) (uint, error) {
+	comment, err := cr.loadComment(commentID)
+	if err != nil {
+		return 0, err
+	}
+	if comment.AuthorID != requestUserID && !cr.permissionRepo.Authorize(requestUserID, models.TaskDelete, utils.TaskResource(comment.TaskID)) {
+		return 0, errors.New("you can only delete your own comments")
+	}
+	if cr.permissionRepo.CheckArchived(utils.TaskResource(comment.TaskID)) {
+		return 0, ErrArchived
+	}
+
+	// the last body goes to the history before the comment is soft deleted
+	err = cr.DB.Transaction(func(tx *gorm.DB) error {
+		revision := models.CommentRevision{CommentID: comment.ID, Body: comment.Body, EditedBy: requestUserID}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		return tx.Delete(comment).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete comment %d: %w", commentID, err)
+	}
+
+	cr.publish(dtos.CommentDeleted, &models.TaskComment{ID: comment.ID, TaskID: comment.TaskID, AuthorID: comment.AuthorID, Mentions: comment.Mentions})
+	return comment.ID, nil
+}
+
+func (cr *commentRepository) GetCommentHistory(commentID uint, requestUserID uint) ([]models.CommentRevision, error) {
+	var comment models.TaskComment
+	if err := cr.DB.Unscoped().First(&comment, commentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("comment %d not found", commentID)
+		}
+		return nil, err
+	}
+	if !cr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(comment.TaskID)) {
+		return nil, errors.New("you don't have access to the task")
+	}
+
+	revisions := []models.CommentRevision{}
+	if err := cr.DB.Where("comment_id = ?", commentID).Order("created_at").Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get history of comment %d: %w", commentID, err)
+	}
+	return revisions, nil
+}
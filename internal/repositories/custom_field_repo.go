@@ -0,0 +1,330 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxFieldTextLength = 1000
+
+type CustomFieldRepository interface {
+	GetProjectFields(projectID uint, requestUserID uint) ([]models.CustomField, error)
+	CreateField(field *models.CustomField, requestUserID uint) (uint, error)
+	// UpdateField renames, reorders or changes the options of a field, the type of a field never changes
+	UpdateField(field *models.CustomField, requestUserID uint) (uint, error)
+	DeleteField(projectID uint, fieldID uint, requestUserID uint) (uint, error)
+	GetTaskFieldValues(taskID uint, requestUserID uint) ([]models.TaskFieldValue, error)
+	// SetTaskFieldValues validates and stores the given values by field id, a null value clears the field
+	SetTaskFieldValues(taskID uint, values map[uint]json.RawMessage, requestUserID uint) ([]models.TaskFieldValue, error)
+}
+
+type customFieldRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+}
+
+func NewCustomFieldRepository(postgreSql *database.DatabaseHandler) CustomFieldRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &customFieldRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+func validateFieldOptions(field *models.CustomField) error {
+	if field.Type != models.FieldSelect {
+		field.Options = nil
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	options := []string{}
+	for _, option := range field.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || seen[option] {
+			continue
+		}
+		seen[option] = true
+		options = append(options, option)
+	}
+	if len(options) == 0 {
+		return errors.New("select fields need at least one option")
+	}
+	field.Options = options
+	return nil
+}
+
+// parseFieldValue turns a raw value into the stored form of the field's type, users are checked by the caller
+func parseFieldValue(field *models.CustomField, raw string) (models.TaskFieldValue, error) {
+	value := models.TaskFieldValue{FieldID: field.ID}
+	switch field.Type {
+	case models.FieldText:
+		if len(raw) > maxFieldTextLength {
+			return value, fmt.Errorf("%s can't be longer than %d characters", field.Name, maxFieldTextLength)
+		}
+		value.Value = raw
+	case models.FieldNumber:
+		number, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return value, fmt.Errorf("%s must be a number", field.Name)
+		}
+		value.Value = strconv.FormatFloat(number, 'f', -1, 64)
+		value.NumberValue = &number
+	case models.FieldDate:
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(raw))
+		if err != nil {
+			return value, fmt.Errorf("%s must be a YYYY-MM-DD date", field.Name)
+		}
+		value.Value = date.Format("2006-01-02")
+		value.DateValue = &date
+	case models.FieldSelect:
+		for _, option := range field.Options {
+			if option == raw {
+				value.Value = raw
+				return value, nil
+			}
+		}
+		return value, fmt.Errorf("%s must be one of %s", field.Name, strings.Join(field.Options, ", "))
+	case models.FieldUser:
+		userID, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 32)
+		if err != nil {
+			return value, fmt.Errorf("%s must be a user id", field.Name)
+		}
+		value.Value = strconv.FormatUint(userID, 10)
+	}
+	return value, nil
+}
+
+// rawFieldValue reads a JSON value as the text parseFieldValue expects, numbers are accepted unquoted
+func rawFieldValue(raw json.RawMessage) (string, bool, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return "", false, nil
+	}
+	if strings.HasPrefix(trimmed, `"`) {
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return "", false, err
+		}
+		return text, true, nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err != nil {
+		return "", false, errors.New("field values must be strings or numbers")
+	}
+	return number.String(), true, nil
+}
+
+func (fr *customFieldRepository) GetProjectFields(projectID uint, requestUserID uint) ([]models.CustomField, error) {
+	if !fr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.ProjectResource(projectID)) {
+		return nil, errors.New("you don't have access to the project")
+	}
+	return projectFields(fr.DB, projectID)
+}
+
+func projectFields(db *gorm.DB, projectID uint) ([]models.CustomField, error) {
+	fields := []models.CustomField{}
+	if err := db.Where("project_id = ?", projectID).Order("position, id").Find(&fields).Error; err != nil {
+		return nil, fmt.Errorf("failed to get custom fields of project %d: %w", projectID, err)
+	}
+	return fields, nil
+}
+
+func (fr *customFieldRepository) CreateField(field *models.CustomField, requestUserID uint) (uint, error) {
+	if !fr.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(field.ProjectID)) {
+		return 0, errors.New("you don't have permission to manage the project fields")
+	}
+	if fr.permissionRepo.CheckArchived(utils.ProjectResource(field.ProjectID)) {
+		return 0, ErrArchived
+	}
+	field.Name = strings.TrimSpace(field.Name)
+	if field.Name == "" {
+		return 0, errors.New("field name is required")
+	}
+	if !models.IsValidCustomFieldType(field.Type) {
+		return 0, fmt.Errorf("invalid field type %s", field.Type)
+	}
+	if err := validateFieldOptions(field); err != nil {
+		return 0, err
+	}
+
+	field.ID = 0
+	if err := fr.DB.Create(field).Error; err != nil {
+		return 0, fmt.Errorf("failed to create field, names must be unique in a project: %w", err)
+	}
+	return field.ID, nil
+}
+
+func (fr *customFieldRepository) projectField(projectID uint, fieldID uint) (*models.CustomField, error) {
+	var field models.CustomField
+	if err := fr.DB.Where("id = ? AND project_id = ?", fieldID, projectID).First(&field).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("field %d not found", fieldID)
+		}
+		return nil, err
+	}
+	return &field, nil
+}
+
+func (fr *customFieldRepository) UpdateField(field *models.CustomField, requestUserID uint) (uint, error) {
+	if !fr.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(field.ProjectID)) {
+		return 0, errors.New("you don't have permission to manage the project fields")
+	}
+	if fr.permissionRepo.CheckArchived(utils.ProjectResource(field.ProjectID)) {
+		return 0, ErrArchived
+	}
+	existingField, err := fr.projectField(field.ProjectID, field.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	field.Type = existingField.Type
+	if field.Options == nil {
+		field.Options = existingField.Options
+	}
+	if err := validateFieldOptions(field); err != nil {
+		return 0, err
+	}
+	if field.Name = strings.TrimSpace(field.Name); field.Name == "" {
+		field.Name = existingField.Name
+	}
+
+	err = fr.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(existingField).Select("name", "options", "position").Updates(&models.CustomField{
+			Name:     field.Name,
+			Options:  field.Options,
+			Position: field.Position,
+		}).Error
+		if err != nil {
+			return err
+		}
+		// values of removed options are cleared
+		if field.Type == models.FieldSelect {
+			return tx.Where("field_id = ? AND value NOT IN ?", field.ID, field.Options).Delete(&models.TaskFieldValue{}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to update field %d: %w", field.ID, err)
+	}
+	return existingField.ID, nil
+}
+
+func (fr *customFieldRepository) DeleteField(projectID uint, fieldID uint, requestUserID uint) (uint, error) {
+	if !fr.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(projectID)) {
+		return 0, errors.New("you don't have permission to manage the project fields")
+	}
+	if fr.permissionRepo.CheckArchived(utils.ProjectResource(projectID)) {
+		return 0, ErrArchived
+	}
+	field, err := fr.projectField(projectID, fieldID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = fr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("field_id = ?", field.ID).Delete(&models.TaskFieldValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(field).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete field %d: %w", fieldID, err)
+	}
+	return field.ID, nil
+}
+
+func (fr *customFieldRepository) GetTaskFieldValues(taskID uint, requestUserID uint) ([]models.TaskFieldValue, error) {
+	if !fr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(taskID)) {
+		return nil, errors.New("you don't have access to the task")
+	}
+
+	values := []models.TaskFieldValue{}
+	if err := fr.DB.Where("task_id = ?", taskID).Order("field_id").Find(&values).Error; err != nil {
+		return nil, fmt.Errorf("failed to get field values of task %d: %w", taskID, err)
+	}
+	return values, nil
+}
+
+func (fr *customFieldRepository) SetTaskFieldValues(taskID uint, values map[uint]json.RawMessage, requestUserID uint) ([]models.TaskFieldValue, error) {
+	if !fr.permissionRepo.Authorize(requestUserID, models.TaskUpdate, utils.TaskResource(taskID)) {
+		return nil, errors.New("user does not have permission to change this task")
+	}
+	if fr.permissionRepo.CheckArchived(utils.TaskResource(taskID)) {
+		return nil, ErrArchived
+	}
+
+	var task models.Task
+	if err := fr.DB.First(&task, taskID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("task not found")
+		}
+		return nil, err
+	}
+
+	fields, err := projectFields(fr.DB, task.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	fieldByID := make(map[uint]*models.CustomField)
+	for i := range fields {
+		fieldByID[fields[i].ID] = &fields[i]
+	}
+
+	var upserts []models.TaskFieldValue
+	var cleared []uint
+	for fieldID, raw := range values {
+		field, ok := fieldByID[fieldID]
+		if !ok {
+			return nil, fmt.Errorf("field %d doesn't belong to project %d", fieldID, task.ProjectID)
+		}
+
+		text, ok, err := rawFieldValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", field.Name, err)
+		}
+		if !ok {
+			cleared = append(cleared, fieldID)
+			continue
+		}
+
+		value, err := parseFieldValue(field, text)
+		if err != nil {
+			return nil, err
+		}
+		if field.Type == models.FieldUser {
+			userID, _ := strconv.ParseUint(value.Value, 10, 32)
+			if !fr.permissionRepo.Authorize(uint(userID), models.ProjectView, utils.ProjectResource(task.ProjectID)) {
+				return nil, fmt.Errorf("%s must be a member of the project", field.Name)
+			}
+		}
+		value.TaskID = taskID
+		upserts = append(upserts, value)
+	}
+
+	err = fr.DB.Transaction(func(tx *gorm.DB) error {
+		if len(cleared) > 0 {
+			if err := tx.Where("task_id = ? AND field_id IN ?", taskID, cleared).Delete(&models.TaskFieldValue{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(upserts) > 0 {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&upserts).Error; err != nil {
+				return err
+			}
+		}
+		return bumpVersion(tx, &models.Task{ID: task.ID, ProjectID: task.ProjectID})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set field values of task %d: %w", taskID, err)
+	}
+	return fr.GetTaskFieldValues(taskID, requestUserID)
+}
@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"mizito/internal/database"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type idSet map[uint]bool
+
+// add adds an id, it reports false for the zero id of a field that wasn't set
+func (s idSet) add(id uint) bool {
+	if id == 0 {
+		return false
+	}
+	s[id] = true
+	return true
+}
+
+func (s idSet) list() []uint {
+	ids := make([]uint, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+var errDashboardRowGone = errors.New("changed row is gone")
+
+// dashboardInvalidation collects the rows the statements of a transaction changed and invalidates
+// the dashboards of the members of their teams once it commits. rows written through a condition
+// alone can't be traced to a team, every dashboard is invalidated for them.
+type dashboardInvalidation struct {
+	DB    *gorm.DB
+	redis *database.RedisHandler
+	all   bool
+	// coworkers are changed users, they show on the dashboards of their teams
+	users, coworkers, teams, projects, tasks, subtasks idSet
+}
+
+func newDashboardInvalidation(db *gorm.DB, redis *database.RedisHandler) *dashboardInvalidation {
+	return &dashboardInvalidation{
+		DB: db, redis: redis,
+		users: idSet{}, coworkers: idSet{}, teams: idSet{}, projects: idSet{}, tasks: idSet{}, subtasks: idSet{},
+	}
+}
+
+// add collects the rows written by the statement of db
+func (di *dashboardInvalidation) add(db *gorm.DB) {
+	if di.all {
+		return
+	}
+	if db.Statement.Schema == nil || db.Statement.Model == nil {
+		di.all = true
+		return
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(db.Statement.Model))
+	switch value.Kind() {
+	case reflect.Struct:
+		di.all = !di.addRow(db, value)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len() && !di.all; i++ {
+			di.all = !di.addRow(db, reflect.Indirect(value.Index(i)))
+		}
+	default:
+		di.all = true
+	}
+}
+
+// addRow collects a written row, it reports false when the row doesn't tell whose dashboards show it
+func (di *dashboardInvalidation) addRow(db *gorm.DB, row reflect.Value) bool {
+	id := func(name string) uint {
+		field := db.Statement.Schema.LookUpField(name)
+		if field == nil {
+			return 0
+		}
+		value, zero := field.ValueOf(db.Statement.Context, row)
+		if zero {
+			return 0
+		}
+		id, _ := value.(uint)
+		return id
+	}
+
+	switch db.Statement.Table {
+	case "users":
+		return di.coworkers.add(id("ID"))
+	case "teams":
+		return di.teams.add(id("ID"))
+	case "team_members":
+		di.users.add(id("UserID"))
+		return di.teams.add(id("TeamID"))
+	case "projects":
+		return di.teams.add(id("TeamID")) || di.projects.add(id("ID"))
+	case "users_projects":
+		di.users.add(id("UserID"))
+		return di.projects.add(id("ProjectID"))
+	case "tasks":
+		return di.projects.add(id("ProjectID")) || di.tasks.add(id("ID"))
+	case "task_members":
+		di.users.add(id("UserID"))
+		return di.tasks.add(id("TaskID"))
+	case "subtasks":
+		return di.tasks.add(id("TaskID")) || di.subtasks.add(id("ID"))
+	case "workflow_statuses":
+		return di.projects.add(id("ProjectID"))
+	}
+	return false
+}
+
+func (di *dashboardInvalidation) AfterCommit() {
+	err := di.invalidate()
+	if err != nil {
+		// every dashboard is rebuilt rather than serving some stale ones
+		err = di.redis.InvalidateDashboards()
+	}
+	if err != nil {
+		fmt.Println("failed to invalidate dashboards:", err)
+	}
+}
+
+func (di *dashboardInvalidation) invalidate() error {
+	if di.all {
+		return di.redis.InvalidateDashboards()
+	}
+
+	if err := di.addParents(&models.Subtask{}, "task_id", di.subtasks, di.tasks); err != nil {
+		return err
+	}
+	if err := di.addParents(&models.Task{}, "project_id", di.tasks, di.projects); err != nil {
+		return err
+	}
+	if err := di.addParents(&models.Project{}, "team_id", di.projects, di.teams); err != nil {
+		return err
+	}
+	if len(di.coworkers) > 0 {
+		var teamIDs []uint
+		if err := di.DB.Model(&models.TeamMember{}).Where("user_id IN ?", di.coworkers.list()).Pluck("team_id", &teamIDs).Error; err != nil {
+			return err
+		}
+		for _, teamID := range teamIDs {
+			di.teams.add(teamID)
+		}
+		for userID := range di.coworkers {
+			di.users.add(userID)
+		}
+	}
+	if len(di.teams) > 0 {
+		var userIDs []uint
+		if err := di.DB.Model(&models.TeamMember{}).Where("team_id IN ?", di.teams.list()).Pluck("user_id", &userIDs).Error; err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			di.users.add(userID)
+		}
+	}
+	return di.redis.InvalidateUserDashboards(di.users.list())
+}
+
+// addParents adds the parents of changed rows, rows purged since can't be traced anymore
+func (di *dashboardInvalidation) addParents(model interface{}, column string, ids idSet, parents idSet) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var rows []struct {
+		ID     uint
+		Parent uint
+	}
+	err := di.DB.Unscoped().Model(model).Select("id, "+column+" AS parent").Where("id IN ?", ids.list()).Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	if len(rows) < len(ids) {
+		return errDashboardRowGone
+	}
+	for _, row := range rows {
+		parents.add(row.Parent)
+	}
+	return nil
+}
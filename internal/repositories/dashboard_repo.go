@@ -1,128 +1,221 @@
 package repositories
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
+
 	"gorm.io/gorm"
 	"mizito/internal/database"
 	"mizito/pkg/models"
+	dashboard_dto "mizito/pkg/models/dtos/dashboard"
+)
+
+const (
+	dashboardTodoLimit     = 50
+	dashboardDeadlineLimit = 20
+	dashboardUpcomingDays  = 7
 )
 
+// dashboardTables are the tables whose changes invalidate the cached dashboards
+var dashboardTables = map[string]bool{
+	"users":             true,
+	"teams":             true,
+	"team_members":      true,
+	"projects":          true,
+	"users_projects":    true,
+	"tasks":             true,
+	"task_members":      true,
+	"subtasks":          true,
+	"workflow_statuses": true,
+}
+
+var registerDashboardCallbacks sync.Once
+
 type DashboardRepository interface {
-	GetDashboardDetails(requestUserID uint) (string, string, int, []TeamMemberWithRole, []SubtaskDetail, []ProjectDetail, error)
+	// GetDashboard returns the dashboard of a user, cached until one of the tables it reads changes
+	GetDashboard(requestUserID uint) (*dashboard_dto.Dashboard, error)
 }
 
 type dashboardRepository struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	redis    *database.RedisHandler
+	cacheTTL time.Duration
 }
 
-func NewDashboardRepository(postgreSql *database.DatabaseHandler) DashboardRepository {
-	return &dashboardRepository{DB: postgreSql.DB}
+func NewDashboardRepository(postgreSql *database.DatabaseHandler, redis *database.RedisHandler, cacheTTL time.Duration) DashboardRepository {
+	// writes invalidate the dashboards of the teams they touch once their transaction commits, a
+	// dashboard rebuilt before the commit is cached in an epoch that's already stale then
+	registerDashboardCallbacks.Do(func() {
+		invalidate := func(db *gorm.DB) {
+			if db.Error != nil || db.RowsAffected == 0 || !dashboardTables[db.Statement.Table] {
+				return
+			}
+			hook, registered := database.OnCommit(db, "dashboard:invalidate", func() database.CommitHook {
+				return newDashboardInvalidation(postgreSql.DB, redis)
+			})
+			hook.(*dashboardInvalidation).add(db)
+			if !registered {
+				hook.AfterCommit()
+			}
+		}
+		callbacks := postgreSql.DB.Callback()
+		callbacks.Create().After("gorm:create").Register("dashboard:invalidate", invalidate)
+		callbacks.Update().After("gorm:update").Register("dashboard:invalidate", invalidate)
+		callbacks.Delete().After("gorm:delete").Register("dashboard:invalidate", invalidate)
+	})
+	return &dashboardRepository{DB: postgreSql.DB, redis: redis, cacheTTL: cacheTTL}
 }
 
-func (dr *dashboardRepository) GetDashboardDetails(requestUserID uint) (string, string, int, []TeamMemberWithRole, []SubtaskDetail, []ProjectDetail, error) {
-	var user models.User
-	var coworkers []TeamMemberWithRole
-	var todoList []SubtaskDetail
-	var projectList []ProjectDetail
+func (dr *dashboardRepository) GetDashboard(requestUserID uint) (*dashboard_dto.Dashboard, error) {
+	// a failing cache only costs a rebuild
+	epoch, err := dr.redis.GetDashboardEpoch(requestUserID)
+	if err == nil {
+		if data, err := dr.redis.GetCachedDashboard(requestUserID, epoch); err == nil && data != nil {
+			var dashboard dashboard_dto.Dashboard
+			if err := json.Unmarshal(data, &dashboard); err == nil {
+				return &dashboard, nil
+			}
+		}
+	}
 
-	// Logic for fetching username and profile picture
-	if err := dr.DB.First(&user, requestUserID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", "", 0, nil, nil, nil, errors.New("user not found")
+	dashboard, buildErr := dr.buildDashboard(requestUserID)
+	if buildErr != nil {
+		return nil, buildErr
+	}
+
+	if err == nil {
+		if data, err := json.Marshal(dashboard); err == nil {
+			if err := dr.redis.SetCachedDashboard(requestUserID, epoch, data, dr.cacheTTL); err != nil {
+				fmt.Println("failed to cache dashboard:", err)
+			}
 		}
-		return "", "", 0, nil, nil, nil, err
 	}
+	return dashboard, nil
+}
 
-	// Logic for fetching coworkers
-	var teamMember models.TeamMember
-	if err := dr.DB.Where("user_id = ? AND team_id IN (?)", requestUserID, dr.DB.Model(&models.TeamMember{}).Select("team_id")).First(&teamMember).Error; err != nil {
+// buildDashboard computes a dashboard with a fixed number of queries, whatever the number of teams, projects and tasks
+func (dr *dashboardRepository) buildDashboard(requestUserID uint) (*dashboard_dto.Dashboard, error) {
+	var user models.User
+	if err := dr.DB.Select("id", "username", "avatar_url").First(&user, requestUserID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", "", 0, nil, nil, nil, errors.New("team not found for user")
+			return nil, errors.New("user not found")
 		}
-		return "", "", 0, nil, nil, nil, err
+		return nil, err
 	}
 
-	// Fetching all coworkers in the same team excluding the request user
-	if err := dr.DB.Model(&models.TeamMember{}).
-		Where("team_id = ? AND user_id != ?", teamMember.TeamID, requestUserID).
-		Joins("JOIN users ON users.id = team_members.user_id").
-		Select("users.username, team_members.role").
-		Find(&coworkers).Error; err != nil {
-		return "", "", 0, nil, nil, nil, err
+	now := time.Now()
+	dashboard := &dashboard_dto.Dashboard{
+		Username:          user.Username,
+		ProfilePicture:    user.AvatarUrl,
+		TodoList:          []dashboard_dto.TodoSubtask{},
+		Projects:          []dashboard_dto.ProjectCompletion{},
+		Coworkers:         []dashboard_dto.Coworker{},
+		UpcomingDeadlines: []dashboard_dto.Deadline{},
+		GeneratedAt:       now,
 	}
 
-	// Logic for fetching the todoList (unfinished subtasks)
-	var tasks []models.Task
-	// Fetch all tasks the user is assigned to
-	if err := dr.DB.Model(&models.Task{}).
-		Joins("JOIN task_members ON task_members.task_id = tasks.id").
-		Where("task_members.user_id = ?", requestUserID).
-		Find(&tasks).Error; err != nil {
-		return "", "", 0, nil, nil, nil, err
+	coworkers, err := dr.coworkers(requestUserID)
+	if err != nil {
+		return nil, err
 	}
-
-	// Collect subtasks from these tasks that are not finished
-	var unfinishedSubtasks []models.Subtask
-	for _, task := range tasks {
-		var taskSubtasks []models.Subtask
-		if err := dr.DB.Where("task_id = ? AND is_completed = ?", task.ID, false).Find(&taskSubtasks).Error; err != nil {
-			return "", "", 0, nil, nil, nil, err
-		}
-		unfinishedSubtasks = append(unfinishedSubtasks, taskSubtasks...)
+	dashboard.Coworkers = coworkers
+
+	// unfinished subtasks of the tasks assigned to the user, the most urgent first, archived
+	// teams and projects are left out of the whole dashboard
+	todo := dr.DB.Table("subtasks").
+		Joins("JOIN tasks ON tasks.id = subtasks.task_id AND tasks.deleted_at IS NULL").
+		Joins("JOIN task_members ON task_members.task_id = tasks.id AND task_members.user_id = ?", requestUserID).
+		Joins("JOIN projects ON projects.id = tasks.project_id AND projects.archived_at IS NULL").
+		Joins("JOIN teams ON teams.id = projects.team_id AND teams.archived_at IS NULL").
+		Where("subtasks.is_completed = ?", false)
+	var todoCount int64
+	if err := todo.Session(&gorm.Session{}).Count(&todoCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count todo subtasks: %w", err)
 	}
-
-	// Logic for fetching projectList (project ID, name, and remaining tasks percentage)
-	var projects []models.Project
-	// Fetch all projects the user is involved in
-	if err := dr.DB.Model(&models.Project{}).
-		Joins("JOIN tasks ON tasks.project_id = projects.id").
-		Joins("JOIN task_members ON task_members.task_id = tasks.id").
-		Where("task_members.user_id = ?", requestUserID).
-		Find(&projects).Error; err != nil {
-		return "", "", 0, nil, nil, nil, err
+	dashboard.TodoCount = int(todoCount)
+	err = todo.Session(&gorm.Session{}).
+		Select("subtasks.id AS subtask_id, subtasks.title, tasks.id AS task_id, tasks.title AS task_title, projects.id AS project_id, projects.name AS project_name, tasks.due_date").
+		Order("tasks.due_date, subtasks.id").
+		Limit(dashboardTodoLimit).
+		Scan(&dashboard.TodoList).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todo subtasks: %w", err)
 	}
 
-	// For each project, calculate the average remaining percentage of all tasks
-	var projectDetails []ProjectDetail
-	for _, project := range projects {
-		var tasks []models.Task
-		if err := dr.DB.Where("project_id = ?", project.ID).Find(&tasks).Error; err != nil {
-			return "", "", 0, nil, nil, nil, err
-		}
-
-		var totalRemainingPct int
-		var taskCount int
-		for _, task := range tasks {
-			totalRemainingPct += 100 - task.ProgressPercentage
-			taskCount++
-		}
-
-		// Calculate the average remaining percentage
-		remainingPct := 0
-		if taskCount > 0 {
-			remainingPct = totalRemainingPct / taskCount
-		}
-
-		projectDetails = append(projectDetails, ProjectDetail{
-			ProjectName:   project.Name,
-			CompletionPct: remainingPct,
-		})
+	// unfinished tasks assigned to the user that have a due date
+	unfinished := dr.DB.Table("tasks").
+		Joins("JOIN task_members ON task_members.task_id = tasks.id AND task_members.user_id = ?", requestUserID).
+		Joins("JOIN projects ON projects.id = tasks.project_id AND projects.archived_at IS NULL").
+		Joins("JOIN teams ON teams.id = projects.team_id AND teams.archived_at IS NULL").
+		Joins("LEFT JOIN workflow_statuses ON workflow_statuses.id = tasks.status_id").
+		Where("tasks.deleted_at IS NULL AND workflow_statuses.is_done IS NOT TRUE AND tasks.due_date > ?", time.Time{})
+	var overdueCount int64
+	if err := unfinished.Session(&gorm.Session{}).Where("tasks.due_date < ?", now).Count(&overdueCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count overdue tasks: %w", err)
+	}
+	dashboard.OverdueCount = int(overdueCount)
+	err = unfinished.Session(&gorm.Session{}).
+		Select("tasks.id AS task_id, tasks.title, projects.id AS project_id, projects.name AS project_name, tasks.due_date").
+		Where("tasks.due_date >= ? AND tasks.due_date < ?", now, now.AddDate(0, 0, dashboardUpcomingDays)).
+		Order("tasks.due_date, tasks.id").
+		Limit(dashboardDeadlineLimit).
+		Scan(&dashboard.UpcomingDeadlines).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upcoming deadlines: %w", err)
 	}
 
-	return user.Username, "", len(todoList), coworkers, todoList, projectList, nil
-}
+	// completion of every project the user belongs to, the average progress of its tasks
+	err = dr.DB.Table("projects").
+		Select("projects.id AS project_id, projects.name AS project_name, CAST(COALESCE(AVG(tasks.progress_percentage), 0) AS INTEGER) AS completion_pct, COUNT(tasks.id) AS task_count").
+		Joins("JOIN users_projects ON users_projects.project_id = projects.id AND users_projects.user_id = ?", requestUserID).
+		Joins("JOIN teams ON teams.id = projects.team_id AND teams.archived_at IS NULL").
+		Joins("LEFT JOIN tasks ON tasks.project_id = projects.id AND tasks.deleted_at IS NULL").
+		Where("projects.deleted_at IS NULL AND projects.archived_at IS NULL").
+		Group("projects.id, projects.name").
+		Order("projects.name").
+		Scan(&dashboard.Projects).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project completion: %w", err)
+	}
 
-type TeamMemberWithRole struct {
-	Name string
-	Role string
+	return dashboard, nil
 }
 
-type SubtaskDetail struct {
-	SubtaskName string
-	Detail      string
-}
+// coworkers returns the members of all the user's teams, once per user with the teams they share
+func (dr *dashboardRepository) coworkers(requestUserID uint) ([]dashboard_dto.Coworker, error) {
+	var rows []struct {
+		UserID    uint
+		Username  string
+		AvatarUrl string
+		TeamID    uint
+		TeamName  string
+		Role      string
+	}
+	err := dr.DB.Table("team_members AS mine").
+		Select("users.id AS user_id, users.username, users.avatar_url, teams.id AS team_id, teams.name AS team_name, others.role").
+		Joins("JOIN team_members AS others ON others.team_id = mine.team_id AND others.user_id <> mine.user_id").
+		Joins("JOIN users ON users.id = others.user_id").
+		Joins("JOIN teams ON teams.id = mine.team_id AND teams.deleted_at IS NULL AND teams.archived_at IS NULL").
+		Where("mine.user_id = ?", requestUserID).
+		Order("users.username, teams.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coworkers: %w", err)
+	}
 
-type ProjectDetail struct {
-	ProjectName   string
-	CompletionPct int
+	coworkers := []dashboard_dto.Coworker{}
+	index := make(map[uint]int)
+	for _, row := range rows {
+		i, ok := index[row.UserID]
+		if !ok {
+			i = len(coworkers)
+			index[row.UserID] = i
+			coworkers = append(coworkers, dashboard_dto.Coworker{UserID: row.UserID, Username: row.Username, AvatarUrl: row.AvatarUrl})
+		}
+		coworkers[i].Teams = append(coworkers[i].Teams, dashboard_dto.CoworkerTeam{TeamID: row.TeamID, TeamName: row.TeamName, Role: row.Role})
+	}
+	return coworkers, nil
 }
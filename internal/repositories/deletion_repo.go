@@ -0,0 +1,347 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/env"
+	"mizito/internal/repositories/utils"
+	"mizito/internal/storage"
+	"mizito/pkg/models"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DeletionRepository interface {
+	// PurgeTeam permanently deletes a team with its projects, tasks, memberships, roles, templates,
+	// views, files and chat, whether the team is in the trash or not. Only the team's admins may purge it.
+	PurgeTeam(teamID uint, requestUserID uint) error
+	// PurgeTrash permanently deletes the teams, projects and tasks that were moved to the trash
+	// before a time and returns how many of them were purged, the purges are recorded as system activities
+	PurgeTrash(before time.Time) (int, error)
+	// WithRequest returns a copy of the repository that records its activities for the request
+	WithRequest(request RequestInfo) DeletionRepository
+}
+
+type deletionRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+	mongo          *database.MongoHandler
+	store          storage.BlobStore
+	cfg            *env.Config
+	request        RequestInfo
+}
+
+func NewDeletionRepository(postgreSql *database.DatabaseHandler, mongo *database.MongoHandler, store storage.BlobStore, cfg *env.Config) DeletionRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &deletionRepository{DB: postgreSql.DB, permissionRepo: permissionRepo, mongo: mongo, store: store, cfg: cfg}
+}
+
+func (dr *deletionRepository) WithRequest(request RequestInfo) DeletionRepository {
+	scoped := *dr
+	scoped.request = request
+	return &scoped
+}
+
+// purge walks the rows to delete inside a transaction and collects what has to be removed
+// outside of the database once the transaction committed
+type purge struct {
+	tx         *gorm.DB
+	blobKeys   []string
+	projectIDs []uint
+}
+
+// run purges inside a transaction, then removes the files that lost their last reference and the
+// chat of the purged projects. Those are only removed after the commit, a failure leaves orphaned
+// content behind instead of rows pointing to missing content.
+func (dr *deletionRepository) run(walk func(p *purge) error) error {
+	p := &purge{}
+	err := dr.DB.Transaction(func(tx *gorm.DB) error {
+		p.tx = tx
+		return walk(p)
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var cleanupErrs []error
+	for _, key := range p.blobKeys {
+		if err := dr.store.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			cleanupErrs = append(cleanupErrs, fmt.Errorf("failed to delete blob %s: %w", key, err))
+		}
+	}
+	if len(p.projectIDs) > 0 && dr.mongo != nil {
+		coll := dr.mongo.Client.Database(dr.cfg.MongoDatabase).Collection(dr.cfg.MongoCollection)
+		filter := bson.D{{Key: "project", Value: bson.D{{Key: "$in", Value: p.projectIDs}}}}
+		mongoCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if _, err := coll.DeleteMany(mongoCtx, filter); err != nil {
+			cleanupErrs = append(cleanupErrs, fmt.Errorf("failed to delete chat messages: %w", err))
+		}
+	}
+	return errors.Join(cleanupErrs...)
+}
+
+func (dr *deletionRepository) PurgeTeam(teamID uint, requestUserID uint) error {
+	// the team may be in the trash already, where it can't be resolved for permissions
+	if !dr.permissionRepo.CheckUserIsSystemAdmin(requestUserID) && !dr.permissionRepo.CheckUserIsAdminOfTeam(requestUserID, teamID) {
+		return errors.New("you don't have permission to delete the team")
+	}
+	var team models.Team
+	if err := dr.DB.Unscoped().First(&team, teamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("team %d not found", teamID)
+		}
+		return fmt.Errorf("failed to retrieve team %d: %w", teamID, err)
+	}
+
+	err := dr.run(func(p *purge) error {
+		if err := p.team(teamID); err != nil {
+			return err
+		}
+		return recordActivity(p.tx, dr.request, teamActivity(models.ActivityPurged, teamID, activityChanges(&team, nil), requestUserID))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to purge team %d: %w", teamID, err)
+	}
+	return nil
+}
+
+func (dr *deletionRepository) PurgeTrash(before time.Time) (int, error) {
+	purged := 0
+
+	var teamIDs []uint
+	if err := dr.DB.Unscoped().Model(&models.Team{}).Where("deleted_at < ?", before).Pluck("id", &teamIDs).Error; err != nil {
+		return purged, fmt.Errorf("failed to find deleted teams: %w", err)
+	}
+	for _, teamID := range teamIDs {
+		err := dr.run(func(p *purge) error {
+			if err := p.team(teamID); err != nil {
+				return err
+			}
+			return recordActivity(p.tx, RequestInfo{}, teamActivity(models.ActivityPurged, teamID, nil, 0))
+		})
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge team %d: %w", teamID, err)
+		}
+		purged++
+	}
+
+	var projects []models.Project
+	if err := dr.DB.Unscoped().Select("id", "team_id").Where("deleted_at < ?", before).Find(&projects).Error; err != nil {
+		return purged, fmt.Errorf("failed to find deleted projects: %w", err)
+	}
+	for _, project := range projects {
+		err := dr.run(func(p *purge) error {
+			if err := p.projects([]uint{project.ID}); err != nil {
+				return err
+			}
+			return recordActivity(p.tx, RequestInfo{}, projectActivity(models.ActivityPurged, &project, nil, 0))
+		})
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge project %d: %w", project.ID, err)
+		}
+		purged++
+	}
+
+	var tasks []models.Task
+	if err := dr.DB.Unscoped().Select("id", "project_id").Where("deleted_at < ?", before).Find(&tasks).Error; err != nil {
+		return purged, fmt.Errorf("failed to find deleted tasks: %w", err)
+	}
+	for _, task := range tasks {
+		err := dr.run(func(p *purge) error {
+			if err := p.tasks([]uint{task.ID}); err != nil {
+				return err
+			}
+			activity := models.Activity{
+				Action:     models.ActivityPurged,
+				TargetType: models.TaskTarget,
+				TargetID:   task.ID,
+				ProjectID:  &task.ProjectID,
+			}
+			return recordActivity(p.tx, RequestInfo{}, activity)
+		})
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge task %d: %w", task.ID, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// deleteWhere deletes the rows of every model matching a condition, soft deleted rows included
+func (p *purge) deleteWhere(query string, ids interface{}, records ...interface{}) error {
+	for _, record := range records {
+		if err := p.tx.Unscoped().Where(query, ids).Delete(record).Error; err != nil {
+			return fmt.Errorf("failed to delete %T: %w", record, err)
+		}
+	}
+	return nil
+}
+
+// attachments deletes the attachments of owners, blobs without any other reference go with them
+func (p *purge) attachments(ownerType models.AttachmentOwner, ownerIDs []uint) error {
+	if len(ownerIDs) == 0 {
+		return nil
+	}
+	var hashes []string
+	err := p.tx.Model(&models.Attachment{}).
+		Where("owner_type = ? AND owner_id IN ?", ownerType, ownerIDs).
+		Distinct().Pluck("blob_hash", &hashes).Error
+	if err != nil {
+		return fmt.Errorf("failed to find attachments: %w", err)
+	}
+	if err := p.tx.Where("owner_type = ? AND owner_id IN ?", ownerType, ownerIDs).Delete(&models.Attachment{}).Error; err != nil {
+		return fmt.Errorf("failed to delete attachments: %w", err)
+	}
+
+	for _, hash := range hashes {
+		var blob models.Blob
+		err := p.tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("hash = ?", hash).First(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to lock blob %s: %w", hash, err)
+		}
+
+		var references int64
+		if err := p.tx.Model(&models.Attachment{}).Where("blob_hash = ?", hash).Count(&references).Error; err != nil {
+			return fmt.Errorf("failed to count blob references: %w", err)
+		}
+		if references > 0 {
+			continue
+		}
+		if err := p.tx.Delete(&blob).Error; err != nil {
+			return fmt.Errorf("failed to delete blob %s: %w", hash, err)
+		}
+		p.blobKeys = append(p.blobKeys, blob.StorageKey)
+	}
+	return nil
+}
+
+// comments deletes the comments of tasks with their history, mentions and files
+func (p *purge) comments(taskIDs []uint) error {
+	var commentIDs []uint
+	if err := p.tx.Unscoped().Model(&models.TaskComment{}).Where("task_id IN ?", taskIDs).Pluck("id", &commentIDs).Error; err != nil {
+		return fmt.Errorf("failed to find comments: %w", err)
+	}
+	if len(commentIDs) == 0 {
+		return nil
+	}
+	if err := p.attachments(models.AttachmentComment, commentIDs); err != nil {
+		return err
+	}
+	if err := p.deleteWhere("comment_id IN ?", commentIDs, &models.CommentRevision{}, &models.CommentMention{}, &models.CommentAttachment{}); err != nil {
+		return err
+	}
+	return p.deleteWhere("id IN ?", commentIDs, &models.TaskComment{})
+}
+
+// tasks deletes tasks with their subtasks, assignments, dependencies, board moves, time entries,
+// reports, labels, field values, history, comments and files
+func (p *purge) tasks(taskIDs []uint) error {
+	if len(taskIDs) == 0 {
+		return nil
+	}
+	if err := p.comments(taskIDs); err != nil {
+		return err
+	}
+	if err := p.attachments(models.AttachmentTask, taskIDs); err != nil {
+		return err
+	}
+	if err := p.tx.Where("blocker_id IN ? OR blocked_id IN ?", taskIDs, taskIDs).Delete(&models.TaskDependency{}).Error; err != nil {
+		return fmt.Errorf("failed to delete task dependencies: %w", err)
+	}
+	err := p.deleteWhere("task_id IN ?", taskIDs,
+		&models.Subtask{}, &models.Report{}, &models.TimeEntry{}, &models.TaskFieldValue{},
+		&models.TaskMove{}, &models.TaskEvent{})
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"task_members", "task_labels"} {
+		if err := p.tx.Table(table).Where("task_id IN ?", taskIDs).Delete(nil).Error; err != nil {
+			return fmt.Errorf("failed to delete %s: %w", table, err)
+		}
+	}
+
+	// later occurrences of a purged recurring task stay, they just lose their series
+	err = p.tx.Unscoped().Model(&models.Task{}).
+		Where("recurrence_parent_id IN ? AND id NOT IN ?", taskIDs, taskIDs).
+		Update("recurrence_parent_id", nil).Error
+	if err != nil {
+		return fmt.Errorf("failed to detach recurring tasks: %w", err)
+	}
+	return p.deleteWhere("id IN ?", taskIDs, &models.Task{})
+}
+
+// projects deletes projects with their tasks, memberships, board, labels, custom fields, views,
+// files and chat
+func (p *purge) projects(projectIDs []uint) error {
+	if len(projectIDs) == 0 {
+		return nil
+	}
+	var taskIDs []uint
+	if err := p.tx.Unscoped().Model(&models.Task{}).Where("project_id IN ?", projectIDs).Pluck("id", &taskIDs).Error; err != nil {
+		return fmt.Errorf("failed to find tasks: %w", err)
+	}
+	if err := p.tasks(taskIDs); err != nil {
+		return err
+	}
+	if err := p.attachments(models.AttachmentProjectAvatar, projectIDs); err != nil {
+		return err
+	}
+	if err := p.attachments(models.AttachmentMessage, projectIDs); err != nil {
+		return err
+	}
+
+	err := p.deleteWhere("project_id IN ?", projectIDs,
+		&models.ProjectMember{}, &models.WorkflowTransition{}, &models.WorkflowStatus{},
+		&models.Label{}, &models.CustomField{}, &models.TaskDependency{}, &models.TaskEvent{},
+		&models.SavedView{}, &models.Message{})
+	if err != nil {
+		return err
+	}
+	if err := p.deleteWhere("id IN ?", projectIDs, &models.Project{}); err != nil {
+		return err
+	}
+	p.projectIDs = append(p.projectIDs, projectIDs...)
+	return nil
+}
+
+// team deletes a team with its projects, memberships, invitations, roles, templates and views
+func (p *purge) team(teamID uint) error {
+	var projectIDs []uint
+	if err := p.tx.Unscoped().Model(&models.Project{}).Where("team_id = ?", teamID).Pluck("id", &projectIDs).Error; err != nil {
+		return fmt.Errorf("failed to find projects: %w", err)
+	}
+	if err := p.projects(projectIDs); err != nil {
+		return err
+	}
+
+	roles := p.tx.Model(&models.CustomRole{}).Select("id").Where("team_id = ?", teamID)
+	if err := p.deleteWhere("role_id IN (?)", roles, &models.RolePermission{}); err != nil {
+		return err
+	}
+	templates := p.tx.Model(&models.ProjectTemplate{}).Select("id").Where("team_id = ?", teamID)
+	templateTasks := p.tx.Model(&models.TemplateTask{}).Select("id").Where("template_id IN (?)", templates)
+	if err := p.deleteWhere("template_task_id IN (?)", templateTasks, &models.TemplateSubtask{}); err != nil {
+		return err
+	}
+	if err := p.deleteWhere("template_id IN (?)", templates, &models.TemplateTask{}); err != nil {
+		return err
+	}
+
+	err := p.deleteWhere("team_id = ?", teamID,
+		&models.TeamMember{}, &models.TeamInvitation{}, &models.CustomRole{},
+		&models.ProjectTemplate{}, &models.SavedView{})
+	if err != nil {
+		return err
+	}
+	return p.deleteWhere("id = ?", teamID, &models.Team{})
+}
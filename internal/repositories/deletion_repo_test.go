@@ -0,0 +1,426 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/env"
+	"mizito/internal/storage"
+	"mizito/pkg/models"
+	messagedto "mizito/pkg/models/dtos/message"
+
+	config "github.com/caarlos0/env/v11"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// the deletion tests run against the postgres and mongodb of the environment's config, they are
+// skipped unless MIZITO_INTEGRATION is set. make test-integration starts the databases of
+// docker-compose.yml and runs them.
+type deletionTest struct {
+	t     *testing.T
+	cfg   *env.Config
+	db    *gorm.DB
+	mongo *database.MongoHandler
+	store storage.BlobStore
+	repo  DeletionRepository
+}
+
+func newDeletionTest(t *testing.T) *deletionTest {
+	if os.Getenv("MIZITO_INTEGRATION") == "" {
+		t.Skip("set MIZITO_INTEGRATION to run the tests against the configured databases")
+	}
+	var cfg env.Config
+	if err := config.Parse(&cfg); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	postgreSql := database.NewDatabaseHandler(&cfg)
+	mongo := database.NewMongoHandler(&cfg)
+	return &deletionTest{
+		t:     t,
+		cfg:   &cfg,
+		db:    postgreSql.DB,
+		mongo: mongo,
+		store: store,
+		repo:  NewDeletionRepository(postgreSql, mongo, store, &cfg),
+	}
+}
+
+func (dt *deletionTest) create(values ...interface{}) {
+	dt.t.Helper()
+	for _, value := range values {
+		if err := dt.db.Omit(clause.Associations).Create(value).Error; err != nil {
+			dt.t.Fatalf("failed to create %T: %v", value, err)
+		}
+	}
+}
+
+func (dt *deletionTest) user(name string) *models.User {
+	dt.t.Helper()
+	suffix := time.Now().UnixNano()
+	user := &models.User{Username: fmt.Sprintf("%s-%d", name, suffix), Email: fmt.Sprintf("%s-%d@gmail.com", name, suffix)}
+	dt.create(user)
+	return user
+}
+
+// blob stores a file for owners and returns its storage key
+func (dt *deletionTest) blob(uploader uint, ownerType models.AttachmentOwner, ownerID uint) (*models.Attachment, string) {
+	dt.t.Helper()
+	content := []byte(fmt.Sprintf("%s %d %d", ownerType, ownerID, time.Now().UnixNano()))
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	blob := &models.Blob{Hash: hash, Size: int64(len(content)), ContentType: "text/plain", StorageKey: blobKey(hash)}
+	if err := dt.store.Put(context.Background(), blob.StorageKey, bytes.NewReader(content), blob.Size, blob.ContentType); err != nil {
+		dt.t.Fatal(err)
+	}
+	attachment := &models.Attachment{
+		BlobHash: hash, Name: "file.txt", ContentType: blob.ContentType, Size: blob.Size,
+		UploadedBy: uploader, OwnerType: ownerType, OwnerID: ownerID,
+	}
+	dt.create(blob, attachment)
+	return attachment, blob.StorageKey
+}
+
+// teamFixture is a team with a row in every table its purge deletes
+type teamFixture struct {
+	owner      *models.User
+	team       *models.Team
+	project    *models.Project
+	task       *models.Task
+	roleID     uint
+	templateID uint
+	commentID  uint
+	blobKeys   []string
+}
+
+func (dt *deletionTest) seedTeam() *teamFixture {
+	dt.t.Helper()
+	f := &teamFixture{owner: dt.user("owner")}
+	member := dt.user("member")
+
+	f.team = &models.Team{Name: "purge team"}
+	dt.create(f.team)
+	teamID := f.team.ID
+	role := &models.CustomRole{TeamID: teamID, Name: "reviewer"}
+	dt.create(
+		&models.TeamMember{TeamID: teamID, UserID: f.owner.ID, Role: models.Admin},
+		&models.TeamMember{TeamID: teamID, UserID: member.ID, Role: models.Member},
+		&models.TeamInvitation{TeamID: teamID, InviterID: f.owner.ID, Email: "invitee@gmail.com", Role: models.Member, ExpiresAt: time.Now().Add(time.Hour)},
+		role,
+	)
+	f.roleID = role.ID
+	dt.create(&models.RolePermission{RoleID: role.ID, Permission: models.TaskView})
+
+	template := &models.ProjectTemplate{TeamID: teamID, Name: "template", CreatedBy: f.owner.ID}
+	dt.create(template)
+	f.templateID = template.ID
+	templateTask := &models.TemplateTask{TemplateID: template.ID, Title: "template task"}
+	dt.create(templateTask)
+	dt.create(&models.TemplateSubtask{TemplateTaskID: templateTask.ID, Title: "template subtask"})
+	dt.create(&models.SavedView{OwnerID: f.owner.ID, TeamID: &teamID, Name: "team view"})
+
+	f.project, f.task = dt.seedProject(f, teamID, member.ID)
+	return f
+}
+
+// seedProject creates a project of the team with a row in every table its purge deletes
+func (dt *deletionTest) seedProject(f *teamFixture, teamID uint, memberID uint) (*models.Project, *models.Task) {
+	dt.t.Helper()
+	project := &models.Project{TeamID: teamID, Name: "purge project"}
+	dt.create(project)
+	projectID := project.ID
+
+	todo := &models.WorkflowStatus{ProjectID: projectID, Name: "todo", Position: 0}
+	done := &models.WorkflowStatus{ProjectID: projectID, Name: "done", Position: 1, IsDone: true}
+	label := &models.Label{ProjectID: projectID, Name: "bug"}
+	field := &models.CustomField{ProjectID: projectID, Name: "points", Type: models.FieldText}
+	dt.create(
+		&models.ProjectMember{ProjectID: projectID, UserID: f.owner.ID, Role: models.ProjectOwner},
+		&models.ProjectMember{ProjectID: projectID, UserID: memberID, Role: models.ProjectContributor},
+		todo, done, label, field,
+		&models.SavedView{OwnerID: f.owner.ID, ProjectID: &projectID, Name: "project view"},
+		&models.Message{Sender: f.owner.ID, ProjectID: projectID, Content: []byte("hello")},
+	)
+	dt.create(&models.WorkflowTransition{ProjectID: projectID, FromStatusID: todo.ID, ToStatusID: done.ID})
+
+	task := dt.seedTask(f, project, todo.ID, done.ID, label.ID, field.ID, memberID)
+	blocked := &models.Task{ProjectID: projectID, Title: "blocked", StatusID: &todo.ID, DueDate: time.Now()}
+	dt.create(blocked)
+	dt.create(&models.TaskDependency{ProjectID: projectID, BlockerID: task.ID, BlockedID: blocked.ID, CreatedBy: f.owner.ID})
+
+	_, avatarKey := dt.blob(f.owner.ID, models.AttachmentProjectAvatar, projectID)
+	_, messageKey := dt.blob(f.owner.ID, models.AttachmentMessage, projectID)
+	f.blobKeys = append(f.blobKeys, avatarKey, messageKey)
+
+	coll := dt.mongo.Client.Database(dt.cfg.MongoDatabase).Collection(dt.cfg.MongoCollection)
+	if _, err := coll.InsertOne(context.Background(), &messagedto.Message{Project: projectID, Content: "hello", CreatedAt: time.Now()}); err != nil {
+		dt.t.Fatalf("failed to store chat message: %v", err)
+	}
+	return project, task
+}
+
+// seedTask creates a task of the project with a row in every table its purge deletes
+func (dt *deletionTest) seedTask(f *teamFixture, project *models.Project, todoID uint, doneID uint, labelID uint, fieldID uint, memberID uint) *models.Task {
+	dt.t.Helper()
+	now := time.Now()
+	task := &models.Task{ProjectID: project.ID, Title: "purged task", StatusID: &todoID, DueDate: now}
+	dt.create(task)
+	taskID := task.ID
+
+	comment := &models.TaskComment{TaskID: taskID, AuthorID: f.owner.ID, Body: "@member look"}
+	dt.create(comment)
+	f.commentID = comment.ID
+	commentFile, commentKey := dt.blob(f.owner.ID, models.AttachmentComment, comment.ID)
+	_, taskKey := dt.blob(f.owner.ID, models.AttachmentTask, taskID)
+	f.blobKeys = append(f.blobKeys, commentKey, taskKey)
+
+	ended := now.Add(time.Hour)
+	dt.create(
+		&models.CommentRevision{CommentID: comment.ID, Body: "look", EditedBy: f.owner.ID},
+		&models.CommentMention{CommentID: comment.ID, UserID: memberID},
+		&models.CommentAttachment{CommentID: comment.ID, AttachmentID: &commentFile.ID, Name: commentFile.Name, URL: attachmentContentURL(commentFile.ID)},
+		&models.Subtask{TaskID: taskID, Title: "subtask"},
+		&models.Report{TaskID: taskID, UserID: memberID, Message: "done", ReportDate: now},
+		&models.TimeEntry{TaskID: taskID, UserID: memberID, StartedAt: now, EndedAt: &ended},
+		&models.TaskFieldValue{TaskID: taskID, FieldID: fieldID, Value: "3"},
+		&models.TaskMove{TaskID: taskID, FromStatusID: &todoID, ToStatusID: doneID, MovedBy: memberID, MovedAt: now},
+		&models.TaskEvent{TaskID: taskID, ProjectID: project.ID, Type: models.TaskCreated, ActorID: f.owner.ID, OccurredAt: now},
+	)
+	if err := dt.db.Table("task_members").Create(map[string]interface{}{"task_id": taskID, "user_id": memberID}).Error; err != nil {
+		dt.t.Fatalf("failed to assign task: %v", err)
+	}
+	if err := dt.db.Table("task_labels").Create(map[string]interface{}{"task_id": taskID, "label_id": labelID}).Error; err != nil {
+		dt.t.Fatalf("failed to label task: %v", err)
+	}
+	return task
+}
+
+// assertGone fails when rows matching the condition are left in the table of model
+func (dt *deletionTest) assertGone(model interface{}, query string, args ...interface{}) {
+	dt.t.Helper()
+	db := dt.db.Unscoped()
+	if table, ok := model.(string); ok {
+		db = db.Table(table)
+	} else {
+		db = db.Model(model)
+	}
+	var count int64
+	if err := db.Where(query, args...).Count(&count).Error; err != nil {
+		dt.t.Fatalf("failed to count %v: %v", model, err)
+	}
+	if count > 0 {
+		dt.t.Errorf("%d rows of %v where %s %v are left", count, model, query, args)
+	}
+}
+
+// assertKept fails when the row of model with the id was deleted
+func (dt *deletionTest) assertKept(model interface{}, id uint) {
+	dt.t.Helper()
+	var count int64
+	if err := dt.db.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		dt.t.Fatalf("failed to count %T: %v", model, err)
+	}
+	if count != 1 {
+		dt.t.Errorf("%T %d outside of the trash was purged", model, id)
+	}
+}
+
+func (dt *deletionTest) assertTaskGone(taskID uint, commentID uint) {
+	dt.t.Helper()
+	dt.assertGone(&models.TaskComment{}, "task_id = ?", taskID)
+	dt.assertGone(&models.CommentRevision{}, "comment_id = ?", commentID)
+	dt.assertGone(&models.CommentMention{}, "comment_id = ?", commentID)
+	dt.assertGone(&models.CommentAttachment{}, "comment_id = ?", commentID)
+	dt.assertGone(&models.Attachment{}, "owner_type = ? AND owner_id = ?", models.AttachmentComment, commentID)
+	dt.assertGone(&models.Attachment{}, "owner_type = ? AND owner_id = ?", models.AttachmentTask, taskID)
+	dt.assertGone(&models.TaskDependency{}, "blocker_id = ? OR blocked_id = ?", taskID, taskID)
+	for _, model := range []interface{}{
+		&models.Subtask{}, &models.Report{}, &models.TimeEntry{}, &models.TaskFieldValue{},
+		&models.TaskMove{}, &models.TaskEvent{}, "task_members", "task_labels",
+	} {
+		dt.assertGone(model, "task_id = ?", taskID)
+	}
+	dt.assertGone(&models.Task{}, "id = ?", taskID)
+}
+
+func (dt *deletionTest) assertProjectGone(projectID uint) {
+	dt.t.Helper()
+	dt.assertGone(&models.Task{}, "project_id = ?", projectID)
+	dt.assertGone(&models.Attachment{}, "owner_type IN ? AND owner_id = ?",
+		[]models.AttachmentOwner{models.AttachmentProjectAvatar, models.AttachmentMessage}, projectID)
+	for _, model := range []interface{}{
+		&models.ProjectMember{}, &models.WorkflowTransition{}, &models.WorkflowStatus{},
+		&models.Label{}, &models.CustomField{}, &models.TaskDependency{}, &models.TaskEvent{},
+		&models.SavedView{}, &models.Message{},
+	} {
+		dt.assertGone(model, "project_id = ?", projectID)
+	}
+	dt.assertGone(&models.Project{}, "id = ?", projectID)
+
+	coll := dt.mongo.Client.Database(dt.cfg.MongoDatabase).Collection(dt.cfg.MongoCollection)
+	count, err := coll.CountDocuments(context.Background(), bson.D{{Key: "project", Value: projectID}})
+	if err != nil {
+		dt.t.Fatalf("failed to count chat messages: %v", err)
+	}
+	if count > 0 {
+		dt.t.Errorf("%d chat messages of project %d are left", count, projectID)
+	}
+}
+
+func (dt *deletionTest) assertTeamGone(f *teamFixture) {
+	dt.t.Helper()
+	teamID := f.team.ID
+	dt.assertGone(&models.RolePermission{}, "role_id = ?", f.roleID)
+	dt.assertGone(&models.TemplateSubtask{}, "template_task_id IN (?)",
+		dt.db.Model(&models.TemplateTask{}).Select("id").Where("template_id = ?", f.templateID))
+	dt.assertGone(&models.TemplateTask{}, "template_id = ?", f.templateID)
+	for _, model := range []interface{}{
+		&models.TeamMember{}, &models.TeamInvitation{}, &models.CustomRole{},
+		&models.ProjectTemplate{}, &models.SavedView{}, &models.Project{},
+	} {
+		dt.assertGone(model, "team_id = ?", teamID)
+	}
+	dt.assertGone(&models.Team{}, "id = ?", teamID)
+}
+
+func (dt *deletionTest) assertBlobsGone(keys []string) {
+	dt.t.Helper()
+	for _, key := range keys {
+		dt.assertGone(&models.Blob{}, "storage_key = ?", key)
+		content, err := dt.store.Get(context.Background(), key)
+		if err == nil {
+			content.Close()
+			dt.t.Errorf("content of blob %s is left", key)
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			dt.t.Fatalf("failed to get blob %s: %v", key, err)
+		}
+	}
+}
+
+func TestPurgeTeam(t *testing.T) {
+	dt := newDeletionTest(t)
+	f := dt.seedTeam()
+
+	if err := dt.repo.PurgeTeam(f.team.ID, f.owner.ID); err != nil {
+		t.Fatalf("failed to purge team: %v", err)
+	}
+
+	dt.assertTaskGone(f.task.ID, f.commentID)
+	dt.assertProjectGone(f.project.ID)
+	dt.assertTeamGone(f)
+	dt.assertBlobsGone(f.blobKeys)
+}
+
+func TestPurgeTeamRequiresAdmin(t *testing.T) {
+	dt := newDeletionTest(t)
+	f := dt.seedTeam()
+	outsider := dt.user("outsider")
+
+	if err := dt.repo.PurgeTeam(f.team.ID, outsider.ID); err == nil {
+		t.Fatal("a user outside of the team purged it")
+	}
+	var count int64
+	dt.db.Model(&models.Team{}).Where("id = ?", f.team.ID).Count(&count)
+	if count != 1 {
+		t.Fatal("team is gone after a refused purge")
+	}
+	if err := dt.repo.PurgeTeam(f.team.ID, f.owner.ID); err != nil {
+		t.Fatalf("failed to clean up team: %v", err)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	dt := newDeletionTest(t)
+	deletedAt := time.Now().Add(-2 * time.Hour)
+	before := time.Now().Add(-time.Hour)
+
+	// a team in the trash
+	trashedTeam := dt.seedTeam()
+	if err := dt.db.Model(trashedTeam.team).Update("deleted_at", deletedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// a project in the trash of a live team, and a task in the trash of a live project
+	live := dt.seedTeam()
+	member := dt.user("member")
+	liveKeys := live.blobKeys
+	trashedProject, trashedProjectTask := dt.seedProject(live, live.team.ID, member.ID)
+	trashedProjectComment := live.commentID
+	projectKeys := live.blobKeys[len(liveKeys):]
+	if err := dt.db.Model(trashedProject).Update("deleted_at", deletedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := dt.db.Model(&models.Task{}).Where("project_id = ?", trashedProject.ID).Update("deleted_at", deletedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var todo models.WorkflowStatus
+	if err := dt.db.Where("project_id = ?", live.project.ID).Order("position").First(&todo).Error; err != nil {
+		t.Fatal(err)
+	}
+	var done models.WorkflowStatus
+	if err := dt.db.Where("project_id = ? AND is_done = ?", live.project.ID, true).First(&done).Error; err != nil {
+		t.Fatal(err)
+	}
+	var label models.Label
+	dt.db.Where("project_id = ?", live.project.ID).First(&label)
+	var field models.CustomField
+	dt.db.Where("project_id = ?", live.project.ID).First(&field)
+	trashedTask := dt.seedTask(live, live.project, todo.ID, done.ID, label.ID, field.ID, member.ID)
+	trashedTaskComment := live.commentID
+	taskKeys := live.blobKeys[len(liveKeys)+len(projectKeys):]
+	// the next occurrence of a recurring task outlives the purged one
+	occurrence := &models.Task{ProjectID: live.project.ID, Title: "next occurrence", StatusID: &todo.ID, DueDate: time.Now(), RecurrenceParentID: &trashedTask.ID}
+	dt.create(occurrence)
+	if err := dt.db.Model(trashedTask).Update("deleted_at", deletedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := dt.repo.PurgeTrash(before)
+	if err != nil {
+		t.Fatalf("failed to purge trash: %v", err)
+	}
+	if purged < 3 {
+		t.Errorf("purged %d entries, want at least 3", purged)
+	}
+
+	dt.assertTaskGone(trashedTeam.task.ID, trashedTeam.commentID)
+	dt.assertProjectGone(trashedTeam.project.ID)
+	dt.assertTeamGone(trashedTeam)
+	dt.assertBlobsGone(trashedTeam.blobKeys)
+
+	dt.assertTaskGone(trashedProjectTask.ID, trashedProjectComment)
+	dt.assertProjectGone(trashedProject.ID)
+	dt.assertBlobsGone(projectKeys)
+
+	dt.assertTaskGone(trashedTask.ID, trashedTaskComment)
+	dt.assertBlobsGone(taskKeys)
+
+	var kept models.Task
+	if err := dt.db.First(&kept, occurrence.ID).Error; err != nil {
+		t.Fatalf("next occurrence of the purged task is gone: %v", err)
+	}
+	if kept.RecurrenceParentID != nil {
+		t.Errorf("next occurrence still points to purged task %d", *kept.RecurrenceParentID)
+	}
+	dt.assertKept(&models.Team{}, live.team.ID)
+	dt.assertKept(&models.Project{}, live.project.ID)
+	dt.assertKept(&models.Task{}, live.task.ID)
+
+	if err := dt.repo.PurgeTeam(live.team.ID, live.owner.ID); err != nil {
+		t.Fatalf("failed to clean up team: %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TaskDependencies struct {
+	BlockedBy []models.Task `json:"blocked_by"`
+	Blocks    []models.Task `json:"blocks"`
+}
+
+type DependencyNode struct {
+	ID       uint   `json:"id"`
+	Title    string `json:"title"`
+	StatusID *uint  `json:"status_id"`
+	Done     bool   `json:"done"`
+	Blocked  bool   `json:"blocked"`
+}
+
+type DependencyGraph struct {
+	Tasks []DependencyNode        `json:"tasks"`
+	Edges []models.TaskDependency `json:"edges"`
+	// CriticalPath is the longest chain of unfinished tasks, ordered from the first blocker
+	CriticalPath []uint `json:"critical_path"`
+}
+
+type DependencyRepository interface {
+	// AddDependency makes blockedID wait for blockerID, rejecting links that would create a cycle
+	AddDependency(blockerID uint, blockedID uint, requestUserID uint) (*models.TaskDependency, error)
+	RemoveDependency(blockerID uint, blockedID uint, requestUserID uint) error
+	GetTaskDependencies(taskID uint, requestUserID uint) (*TaskDependencies, error)
+	GetDependencyGraph(projectID uint, requestUserID uint) (*DependencyGraph, error)
+	// WithRequest returns a copy of the repository that records its activities for the request
+	WithRequest(request RequestInfo) DependencyRepository
+}
+
+type dependencyRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+	request        RequestInfo
+}
+
+func NewDependencyRepository(postgreSql *database.DatabaseHandler) DependencyRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &dependencyRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+func (dr *dependencyRepository) WithRequest(request RequestInfo) DependencyRepository {
+	scoped := *dr
+	scoped.request = request
+	return &scoped
+}
+
+// dependencyActivity is a change of the blockers of the blocked task, placed in the task's feed
+func dependencyActivity(dependency *models.TaskDependency, changes models.ActivityChanges, requestUserID uint) models.Activity {
+	return models.Activity{
+		ActorID:    requestUserID,
+		Action:     models.ActivityUpdated,
+		TargetType: models.TaskTarget,
+		TargetID:   dependency.BlockedID,
+		ProjectID:  &dependency.ProjectID,
+		Changes:    changes,
+	}
+}
+
+// unfinishedBlockers returns the blockers of a task that aren't in a done status yet, blockers in the trash don't block
+func unfinishedBlockers(tx *gorm.DB, taskID uint) ([]uint, error) {
+	var blockerIDs []uint
+	err := tx.Model(&models.TaskDependency{}).
+		Joins("JOIN tasks ON tasks.id = task_dependencies.blocker_id").
+		Joins("LEFT JOIN workflow_statuses ON workflow_statuses.id = tasks.status_id").
+		Where("task_dependencies.blocked_id = ? AND tasks.deleted_at IS NULL AND (workflow_statuses.is_done IS NULL OR workflow_statuses.is_done = ?)", taskID, false).
+		Pluck("task_dependencies.blocker_id", &blockerIDs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check blockers of task %d: %w", taskID, err)
+	}
+	return blockerIDs, nil
+}
+
+func (dr *dependencyRepository) AddDependency(blockerID uint, blockedID uint, requestUserID uint) (*models.TaskDependency, error) {
+	if blockerID == blockedID {
+		return nil, errors.New("a task can't block itself")
+	}
+	if !dr.permissionRepo.Authorize(requestUserID, models.TaskUpdate, utils.TaskResource(blockedID)) {
+		return nil, errors.New("user does not have permission to change this task")
+	}
+	if dr.permissionRepo.CheckArchived(utils.TaskResource(blockedID)) {
+		return nil, ErrArchived
+	}
+
+	var tasks []models.Task
+	if err := dr.DB.Select("id", "project_id").Where("id IN ?", []uint{blockerID, blockedID}).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	if len(tasks) != 2 {
+		return nil, errors.New("task not found")
+	}
+	if tasks[0].ProjectID != tasks[1].ProjectID {
+		return nil, errors.New("dependencies can only link tasks of the same project")
+	}
+	projectID := tasks[0].ProjectID
+
+	tx := dr.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	// locking the project serializes dependency changes, so two concurrent links can't form a cycle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Project{}, projectID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var edges []models.TaskDependency
+	if err := tx.Where("project_id = ?", projectID).Find(&edges).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	blocks := make(map[uint][]uint)
+	for _, edge := range edges {
+		if edge.BlockerID == blockerID && edge.BlockedID == blockedID {
+			tx.Rollback()
+			return nil, fmt.Errorf("task %d already blocks task %d", blockerID, blockedID)
+		}
+		blocks[edge.BlockerID] = append(blocks[edge.BlockerID], edge.BlockedID)
+	}
+
+	// the new link closes a cycle when the blocker is already reachable from the blocked task
+	visited := map[uint]bool{blockedID: true}
+	queue := []uint{blockedID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range blocks[current] {
+			if next == blockerID {
+				tx.Rollback()
+				return nil, fmt.Errorf("task %d already depends on task %d, the link would create a cycle", blockerID, blockedID)
+			}
+			if !visited[next] {
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	dependency := models.TaskDependency{
+		ProjectID: projectID,
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+		CreatedBy: requestUserID,
+	}
+	if err := tx.Create(&dependency).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to add dependency: %w", err)
+	}
+	changes := models.ActivityChanges{"BlockerID": {After: blockerID}}
+	if err := recordActivity(tx, dr.request, dependencyActivity(&dependency, changes, requestUserID)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &dependency, nil
+}
+
+func (dr *dependencyRepository) RemoveDependency(blockerID uint, blockedID uint, requestUserID uint) error {
+	if !dr.permissionRepo.Authorize(requestUserID, models.TaskUpdate, utils.TaskResource(blockedID)) {
+		return errors.New("user does not have permission to change this task")
+	}
+	if dr.permissionRepo.CheckArchived(utils.TaskResource(blockedID)) {
+		return ErrArchived
+	}
+
+	return dr.DB.Transaction(func(tx *gorm.DB) error {
+		var dependency models.TaskDependency
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+			First(&dependency).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("task %d doesn't block task %d", blockerID, blockedID)
+		} else if err != nil {
+			return fmt.Errorf("failed to remove dependency: %w", err)
+		}
+
+		if err := tx.Delete(&dependency).Error; err != nil {
+			return fmt.Errorf("failed to remove dependency: %w", err)
+		}
+		changes := models.ActivityChanges{"BlockerID": {Before: blockerID}}
+		return recordActivity(tx, dr.request, dependencyActivity(&dependency, changes, requestUserID))
+	})
+}
+
+func (dr *dependencyRepository) GetTaskDependencies(taskID uint, requestUserID uint) (*TaskDependencies, error) {
+	if !dr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(taskID)) {
+		return nil, errors.New("you don't have access to the task")
+	}
+
+	dependencies := TaskDependencies{BlockedBy: []models.Task{}, Blocks: []models.Task{}}
+	err := dr.DB.
+		Joins("JOIN task_dependencies ON task_dependencies.blocker_id = tasks.id").
+		Where("task_dependencies.blocked_id = ?", taskID).
+		Find(&dependencies.BlockedBy).Error
+	if err != nil {
+		return nil, err
+	}
+	err = dr.DB.
+		Joins("JOIN task_dependencies ON task_dependencies.blocked_id = tasks.id").
+		Where("task_dependencies.blocker_id = ?", taskID).
+		Find(&dependencies.Blocks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &dependencies, nil
+}
+
+func (dr *dependencyRepository) GetDependencyGraph(projectID uint, requestUserID uint) (*DependencyGraph, error) {
+	if !dr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.ProjectResource(projectID)) {
+		return nil, errors.New("you don't have access to the project")
+	}
+
+	statuses, err := projectWorkflow(dr.DB, projectID)
+	if err != nil {
+		return nil, err
+	}
+	doneStatuses := make(map[uint]bool)
+	for _, status := range statuses {
+		doneStatuses[status.ID] = status.IsDone
+	}
+
+	var tasks []models.Task
+	if err := dr.DB.Select("id", "title", "status_id").Where("project_id = ?", projectID).Order("id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	// edges of tasks in the trash are left out with their tasks
+	liveTasks := dr.DB.Model(&models.Task{}).Select("id").Where("project_id = ?", projectID)
+	var edges []models.TaskDependency
+	if err := dr.DB.Where("project_id = ? AND blocker_id IN (?) AND blocked_id IN (?)", projectID, liveTasks, liveTasks).Order("id").Find(&edges).Error; err != nil {
+		return nil, err
+	}
+
+	graph := DependencyGraph{
+		Tasks:        make([]DependencyNode, 0, len(tasks)),
+		Edges:        edges,
+		CriticalPath: []uint{},
+	}
+	done := make(map[uint]bool)
+	for _, task := range tasks {
+		done[task.ID] = task.StatusID != nil && doneStatuses[*task.StatusID]
+	}
+	blocked := make(map[uint]bool)
+	for _, edge := range edges {
+		if !done[edge.BlockerID] {
+			blocked[edge.BlockedID] = true
+		}
+	}
+	for _, task := range tasks {
+		graph.Tasks = append(graph.Tasks, DependencyNode{
+			ID:       task.ID,
+			Title:    task.Title,
+			StatusID: task.StatusID,
+			Done:     done[task.ID],
+			Blocked:  blocked[task.ID],
+		})
+	}
+
+	graph.CriticalPath = criticalPath(tasks, edges, done)
+	return &graph, nil
+}
+
+// criticalPath finds the longest chain of unfinished tasks, every task counting as one step.
+// the graph is acyclic since AddDependency rejects cycles, so a topological order exists.
+func criticalPath(tasks []models.Task, edges []models.TaskDependency, done map[uint]bool) []uint {
+	blocks := make(map[uint][]uint)
+	inDegree := make(map[uint]int)
+	for _, edge := range edges {
+		if done[edge.BlockerID] || done[edge.BlockedID] {
+			continue
+		}
+		blocks[edge.BlockerID] = append(blocks[edge.BlockerID], edge.BlockedID)
+		inDegree[edge.BlockedID]++
+	}
+
+	var queue []uint
+	for _, task := range tasks {
+		if !done[task.ID] && inDegree[task.ID] == 0 {
+			queue = append(queue, task.ID)
+		}
+	}
+
+	length := make(map[uint]int)
+	previous := make(map[uint]uint)
+	var end uint
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		length[current]++
+		if end == 0 || length[current] > length[end] {
+			end = current
+		}
+
+		next := blocks[current]
+		sort.Slice(next, func(i, j int) bool { return next[i] < next[j] })
+		for _, blockedID := range next {
+			if length[current] > length[blockedID] {
+				length[blockedID] = length[current]
+				previous[blockedID] = current
+			}
+			inDegree[blockedID]--
+			if inDegree[blockedID] == 0 {
+				queue = append(queue, blockedID)
+			}
+		}
+	}
+
+	path := []uint{}
+	for current := end; current != 0; current = previous[current] {
+		path = append([]uint{current}, path...)
+	}
+	return path
+}
@@ -0,0 +1,311 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type InvitationRepository interface {
+	// InviteToTeam invites existing users by username and anyone by email, re-inviting refreshes a pending invitation.
+	InviteToTeam(teamID uint, usernames []string, emails []string, role models.Role, requestUserID uint) ([]models.TeamInvitation, error)
+	GetPendingInvitations(teamID uint, requestUserID uint) ([]models.TeamInvitation, error)
+	RevokeInvitation(teamID uint, invitationID uint, requestUserID uint) error
+	GetUserInvitations(userID uint) ([]models.TeamInvitation, error)
+	AcceptInvitation(invitationID uint, userID uint) (uint, error)
+	DeclineInvitation(invitationID uint, userID uint) error
+	// WithRequest returns a copy of the repository that records its activities for the request
+	WithRequest(request RequestInfo) InvitationRepository
+}
+
+type invitationRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+	ttl            time.Duration
+	request        RequestInfo
+}
+
+func NewInvitationRepository(postgreSql *database.DatabaseHandler) InvitationRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &invitationRepository{DB: postgreSql.DB, permissionRepo: permissionRepo, ttl: postgreSql.Cfg.TeamInvitationTTL}
+}
+
+func (ir *invitationRepository) WithRequest(request RequestInfo) InvitationRepository {
+	scoped := *ir
+	scoped.request = request
+	return &scoped
+}
+
+func (ir *invitationRepository) InviteToTeam(teamID uint, usernames []string, emails []string, role models.Role, requestUserID uint) ([]models.TeamInvitation, error) {
+	if len(usernames) == 0 && len(emails) == 0 {
+		return nil, errors.New("no usernames or emails provided")
+	}
+	if !ir.permissionRepo.Authorize(requestUserID, models.MemberInvite, utils.TeamResource(teamID)) {
+		return nil, errors.New("you don't have permission to invite users to the team")
+	}
+	if ir.permissionRepo.CheckArchived(utils.TeamResource(teamID)) {
+		return nil, ErrArchived
+	}
+	if err := ir.permissionRepo.CheckRoleGrant(requestUserID, utils.TeamResource(teamID), role); err != nil {
+		return nil, err
+	}
+
+	tx := ir.DB.Begin()
+	if tx.Error != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", tx.Error)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var invitations []models.TeamInvitation
+	for _, username := range usernames {
+		var user models.User
+		if err := tx.Where("username = ?", username).First(&user).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("user %s not found", username)
+			}
+			return nil, fmt.Errorf("failed to retrieve user %s: %w", username, err)
+		}
+
+		invitation, err := ir.upsertInvitation(tx, teamID, &user, user.Email, role, requestUserID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		invitations = append(invitations, *invitation)
+	}
+
+	for _, email := range emails {
+		var user models.User
+		var invitee *models.User
+		// an unverified account doesn't prove it owns the address, it's matched once verified
+		err := tx.Where("email = ? AND email_verified = ?", email, true).First(&user).Error
+		if err == nil {
+			invitee = &user
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to retrieve user with email %s: %w", email, err)
+		}
+
+		invitation, err := ir.upsertInvitation(tx, teamID, invitee, email, role, requestUserID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		invitations = append(invitations, *invitation)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return invitations, nil
+}
+
+func (ir *invitationRepository) upsertInvitation(tx *gorm.DB, teamID uint, invitee *models.User, email string, role models.Role, requestUserID uint) (*models.TeamInvitation, error) {
+	if invitee != nil {
+		var count int64
+		if err := tx.Model(&models.TeamMember{}).Where("user_id = ? AND team_id = ?", invitee.ID, teamID).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to check existing membership for user %s: %w", invitee.Username, err)
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("user %s is already a member of team %d", invitee.Username, teamID)
+		}
+	}
+
+	var invitation models.TeamInvitation
+	err := tx.Where("team_id = ? AND email = ? AND status = ?", teamID, email, models.InvitationPending).First(&invitation).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to check existing invitation for %s: %w", email, err)
+	}
+
+	invitation.TeamID = teamID
+	invitation.InviterID = requestUserID
+	invitation.Email = email
+	invitation.Role = role
+	invitation.Status = models.InvitationPending
+	invitation.ExpiresAt = time.Now().Add(ir.ttl)
+	if invitee != nil {
+		invitation.InviteeID = &invitee.ID
+	}
+
+	if err := tx.Save(&invitation).Error; err != nil {
+		return nil, fmt.Errorf("failed to invite %s to team %d: %w", email, teamID, err)
+	}
+	return &invitation, nil
+}
+
+func (ir *invitationRepository) GetPendingInvitations(teamID uint, requestUserID uint) ([]models.TeamInvitation, error) {
+	if !ir.permissionRepo.Authorize(requestUserID, models.MemberInvite, utils.TeamResource(teamID)) {
+		return nil, errors.New("you don't have permission to view the team invitations")
+	}
+
+	var invitations []models.TeamInvitation
+	err := ir.DB.
+		Where("team_id = ? AND status = ? AND expires_at > ?", teamID, models.InvitationPending, time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitations for team %d: %w", teamID, err)
+	}
+	return invitations, nil
+}
+
+func (ir *invitationRepository) RevokeInvitation(teamID uint, invitationID uint, requestUserID uint) error {
+	if !ir.permissionRepo.Authorize(requestUserID, models.MemberInvite, utils.TeamResource(teamID)) {
+		return errors.New("you don't have permission to revoke team invitations")
+	}
+	if ir.permissionRepo.CheckArchived(utils.TeamResource(teamID)) {
+		return ErrArchived
+	}
+
+	result := ir.DB.Model(&models.TeamInvitation{}).
+		Where("id = ? AND team_id = ? AND status = ?", invitationID, teamID, models.InvitationPending).
+		Update("status", models.InvitationRevoked)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke invitation %d: %w", invitationID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("pending invitation %d not found", invitationID)
+	}
+	return nil
+}
+
+func (ir *invitationRepository) GetUserInvitations(userID uint) ([]models.TeamInvitation, error) {
+	var user models.User
+	if err := ir.DB.First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve user %d: %w", userID, err)
+	}
+
+	// invitations sent to an address are only shown once the user verified it
+	addressedToUser := ir.DB.Where("invitee_id = ?", userID)
+	if user.EmailVerified {
+		addressedToUser = addressedToUser.Or("email = ?", user.Email)
+	}
+
+	var invitations []models.TeamInvitation
+	err := ir.DB.
+		Where(addressedToUser).
+		Where("status = ? AND expires_at > ?", models.InvitationPending, time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitations for user %d: %w", userID, err)
+	}
+	return invitations, nil
+}
+
+// findUserInvitation loads a pending, unexpired invitation addressed to the user either by id or by
+// their verified email.
+func (ir *invitationRepository) findUserInvitation(tx *gorm.DB, invitationID uint, userID uint) (*models.TeamInvitation, error) {
+	var user models.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve user %d: %w", userID, err)
+	}
+
+	var invitation models.TeamInvitation
+	if err := tx.First(&invitation, invitationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("invitation %d not found", invitationID)
+		}
+		return nil, fmt.Errorf("failed to retrieve invitation %d: %w", invitationID, err)
+	}
+
+	addressedToUser := (invitation.InviteeID != nil && *invitation.InviteeID == userID) ||
+		(user.EmailVerified && invitation.Email == user.Email)
+	if !addressedToUser {
+		return nil, fmt.Errorf("invitation %d not found", invitationID)
+	}
+	if invitation.Status != models.InvitationPending {
+		return nil, fmt.Errorf("invitation %d is already %s", invitationID, invitation.Status)
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return nil, fmt.Errorf("invitation %d has expired", invitationID)
+	}
+	return &invitation, nil
+}
+
+func (ir *invitationRepository) AcceptInvitation(invitationID uint, userID uint) (uint, error) {
+	tx := ir.DB.Begin()
+	if tx.Error != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", tx.Error)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	invitation, err := ir.findUserInvitation(tx, invitationID, userID)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if ir.permissionRepo.CheckArchived(utils.TeamResource(invitation.TeamID)) {
+		tx.Rollback()
+		return 0, ErrArchived
+	}
+
+	var existingMember models.TeamMember
+	err = tx.Where("user_id = ? AND team_id = ?", userID, invitation.TeamID).First(&existingMember).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		tm := models.TeamMember{
+			UserID: userID,
+			TeamID: invitation.TeamID,
+			Role:   invitation.Role,
+		}
+		if err := tx.Create(&tm).Error; err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to add user %d to team %d: %w", userID, invitation.TeamID, err)
+		}
+		changes := models.ActivityChanges{"UserID": {After: userID}, "Role": {After: invitation.Role}, "InvitationID": {After: invitation.ID}}
+		if err := recordActivity(tx, ir.request, teamActivity(models.ActivityMemberAdded, invitation.TeamID, changes, userID)); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	} else if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to check existing membership for user %d: %w", userID, err)
+	}
+
+	if err := tx.Model(invitation).Updates(map[string]interface{}{
+		"status":     models.InvitationAccepted,
+		"invitee_id": userID,
+	}).Error; err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to accept invitation %d: %w", invitationID, err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return invitation.TeamID, nil
+}
+
+func (ir *invitationRepository) DeclineInvitation(invitationID uint, userID uint) error {
+	invitation, err := ir.findUserInvitation(ir.DB, invitationID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := ir.DB.Model(invitation).Updates(map[string]interface{}{
+		"status":     models.InvitationDeclined,
+		"invitee_id": userID,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to decline invitation %d: %w", invitationID, err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const defaultLabelColor = "#9e9e9e"
+
+var labelColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type LabelRepository interface {
+	GetProjectLabels(projectID uint, requestUserID uint) ([]models.Label, error)
+	CreateLabel(label *models.Label, requestUserID uint) (uint, error)
+	UpdateLabel(label *models.Label, requestUserID uint) (uint, error)
+	DeleteLabel(projectID uint, labelID uint, requestUserID uint) (uint, error)
+	// SetTaskLabels replaces the labels of a task, the labels must belong to the task's project
+	SetTaskLabels(taskID uint, labelIDs []uint, requestUserID uint) ([]models.Label, error)
+}
+
+type labelRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+}
+
+func NewLabelRepository(postgreSql *database.DatabaseHandler) LabelRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &labelRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+func validateLabel(label *models.Label) error {
+	label.Name = strings.TrimSpace(label.Name)
+	if label.Name == "" {
+		return errors.New("label name is required")
+	}
+	if label.Color == "" {
+		label.Color = defaultLabelColor
+	}
+	if !labelColorPattern.MatchString(label.Color) {
+		return fmt.Errorf("invalid label color %s, expected #rrggbb", label.Color)
+	}
+	label.Color = strings.ToLower(label.Color)
+	return nil
+}
+
+func (lr *labelRepository) GetProjectLabels(projectID uint, requestUserID uint) ([]models.Label, error) {
+	if !lr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.ProjectResource(projectID)) {
+		return nil, errors.New("you don't have access to the project")
+	}
+
+	labels := []models.Label{}
+	if err := lr.DB.Where("project_id = ?", projectID).Order("name").Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("failed to get labels of project %d: %w", projectID, err)
+	}
+	return labels, nil
+}
+
+func (lr *labelRepository) CreateLabel(label *models.Label, requestUserID uint) (uint, error) {
+	if !lr.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(label.ProjectID)) {
+		return 0, errors.New("you don't have permission to manage the project labels")
+	}
+	if lr.permissionRepo.CheckArchived(utils.ProjectResource(label.ProjectID)) {
+		return 0, ErrArchived
+	}
+	if err := validateLabel(label); err != nil {
+		return 0, err
+	}
+
+	label.ID = 0
+	if err := lr.DB.Create(label).Error; err != nil {
+		return 0, fmt.Errorf("failed to create label, names must be unique in a project: %w", err)
+	}
+	return label.ID, nil
+}
+
+func (lr *labelRepository) projectLabel(projectID uint, labelID uint) (*models.Label, error) {
+	var label models.Label
+	if err := lr.DB.Where("id = ? AND project_id = ?", labelID, projectID).First(&label).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("label %d not found", labelID)
+		}
+		return nil, err
+	}
+	return &label, nil
+}
+
+func (lr *labelRepository) UpdateLabel(label *models.Label, requestUserID uint) (uint, error) {
+	if !lr.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(label.ProjectID)) {
+		return 0, errors.New("you don't have permission to manage the project labels")
+	}
+	if lr.permissionRepo.CheckArchived(utils.ProjectResource(label.ProjectID)) {
+		return 0, ErrArchived
+	}
+	existingLabel, err := lr.projectLabel(label.ProjectID, label.ID)
+	if err != nil {
+		return 0, err
+	}
+	if err := validateLabel(label); err != nil {
+		return 0, err
+	}
+
+	err = lr.DB.Model(existingLabel).Updates(map[string]interface{}{"name": label.Name, "color": label.Color}).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to update label %d: %w", label.ID, err)
+	}
+	return existingLabel.ID, nil
+}
+
+func (lr *labelRepository) DeleteLabel(projectID uint, labelID uint, requestUserID uint) (uint, error) {
+	if !lr.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(projectID)) {
+		return 0, errors.New("you don't have permission to manage the project labels")
+	}
+	if lr.permissionRepo.CheckArchived(utils.ProjectResource(projectID)) {
+		return 0, ErrArchived
+	}
+	label, err := lr.projectLabel(projectID, labelID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = lr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("task_labels").Where("label_id = ?", label.ID).Delete(nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(label).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete label %d: %w", labelID, err)
+	}
+	return label.ID, nil
+}
+
+func (lr *labelRepository) SetTaskLabels(taskID uint, labelIDs []uint, requestUserID uint) ([]models.Label, error) {
+	if !lr.permissionRepo.Authorize(requestUserID, models.TaskUpdate, utils.TaskResource(taskID)) {
+		return nil, errors.New("user does not have permission to change this task")
+	}
+	if lr.permissionRepo.CheckArchived(utils.TaskResource(taskID)) {
+		return nil, ErrArchived
+	}
+
+	var task models.Task
+	if err := lr.DB.First(&task, taskID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("task not found")
+		}
+		return nil, err
+	}
+
+	labels := []models.Label{}
+	if len(labelIDs) > 0 {
+		if err := lr.DB.Where("id IN ? AND project_id = ?", labelIDs, task.ProjectID).Order("name").Find(&labels).Error; err != nil {
+			return nil, err
+		}
+	}
+	found := make(map[uint]bool)
+	for _, label := range labels {
+		found[label.ID] = true
+	}
+	for _, labelID := range labelIDs {
+		if !found[labelID] {
+			return nil, fmt.Errorf("label %d doesn't belong to project %d", labelID, task.ProjectID)
+		}
+	}
+
+	err := lr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&task).Association("Labels").Replace(labels); err != nil {
+			return err
+		}
+		return bumpVersion(tx, &models.Task{ID: task.ID, ProjectID: task.ProjectID})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set labels of task %d: %w", taskID, err)
+	}
+	return labels, nil
+}
@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict is returned when a record changed since the version the client read
+var ErrVersionConflict = errors.New("the record was changed by someone else, reload it and try again")
+
+// TaskPatch holds the fields of a task an update sets, fields left nil keep their value. status,
+// position, relations and the recurrence bookkeeping change through their own endpoints.
+type TaskPatch struct {
+	Title              *string
+	Description        *string
+	TaskPriority       *int
+	DueDate            *time.Time
+	ProgressPercentage *int
+	EstimateMinutes    *int
+	RecurrenceRule     *string
+}
+
+func (p *TaskPatch) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if p.Title != nil {
+		changes["title"] = *p.Title
+	}
+	if p.Description != nil {
+		changes["description"] = *p.Description
+	}
+	if p.TaskPriority != nil {
+		changes["task_priority"] = *p.TaskPriority
+	}
+	if p.DueDate != nil {
+		changes["due_date"] = *p.DueDate
+	}
+	if p.ProgressPercentage != nil {
+		changes["progress_percentage"] = *p.ProgressPercentage
+	}
+	if p.EstimateMinutes != nil {
+		changes["estimate_minutes"] = *p.EstimateMinutes
+	}
+	if p.RecurrenceRule != nil {
+		changes["recurrence_rule"] = *p.RecurrenceRule
+	}
+	return changes
+}
+
+// ProjectPatch holds the fields of a project an update sets, fields left nil keep their value
+type ProjectPatch struct {
+	Name         *string
+	ImageUrl     *string
+	ProgressMode *models.ProgressMode
+}
+
+func (p *ProjectPatch) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if p.Name != nil {
+		changes["name"] = *p.Name
+	}
+	if p.ImageUrl != nil {
+		changes["image_url"] = *p.ImageUrl
+	}
+	if p.ProgressMode != nil {
+		changes["progress_mode"] = *p.ProgressMode
+	}
+	return changes
+}
+
+// TeamPatch holds the fields of a team an update sets, fields left nil keep their value
+type TeamPatch struct {
+	Name *string
+}
+
+func (p *TeamPatch) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if p.Name != nil {
+		changes["name"] = *p.Name
+	}
+	return changes
+}
+
+// StatusPatch holds the fields of a workflow status an update sets, fields left nil keep their value
+type StatusPatch struct {
+	Name     *string
+	Position *int
+	IsDone   *bool
+}
+
+func (p *StatusPatch) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if p.Name != nil {
+		changes["name"] = *p.Name
+	}
+	if p.Position != nil {
+		changes["position"] = *p.Position
+	}
+	if p.IsDone != nil {
+		changes["is_done"] = *p.IsDone
+	}
+	return changes
+}
+
+// SubtaskPatch holds the fields of a subtask an update sets, fields left nil keep their value
+type SubtaskPatch struct {
+	Title       *string
+	IsCompleted *bool
+	Weight      *int
+}
+
+func (p *SubtaskPatch) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if p.Title != nil {
+		changes["title"] = *p.Title
+	}
+	if p.IsCompleted != nil {
+		changes["is_completed"] = *p.IsCompleted
+	}
+	if p.Weight != nil {
+		changes["weight"] = *p.Weight
+	}
+	return changes
+}
+
+// applyPatch writes the changed columns of the record read at version and bumps its version.
+// expectedVersion is the version the client read, 0 when it didn't send one. the update only
+// matches the version it was read at so a concurrent update fails instead of being overwritten.
+// model carries the record's id and parents for the callbacks to tell whose record changed.
+func applyPatch(tx *gorm.DB, model interface{}, id uint, version uint, expectedVersion uint, changes map[string]interface{}) error {
+	if expectedVersion != 0 && expectedVersion != version {
+		return ErrVersionConflict
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	changes["version"] = version + 1
+	result := tx.Model(model).Where("id = ? AND version = ?", id, version).Updates(changes)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// bumpVersion marks a record as changed by a write that doesn't go through applyPatch, so a
+// client still holding the previous version gets a conflict. model identifies the record.
+func bumpVersion(tx *gorm.DB, model interface{}) error {
+	return tx.Model(model).Update("version", gorm.Expr("version + 1")).Error
+}
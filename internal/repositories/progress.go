@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"fmt"
+
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// projectProgressMode returns the progress mode of a project, manual for projects created before modes existed
+func projectProgressMode(tx *gorm.DB, projectID uint) (models.ProgressMode, error) {
+	var project models.Project
+	if err := tx.Select("id", "progress_mode").First(&project, projectID).Error; err != nil {
+		return "", fmt.Errorf("failed to get progress mode of project %d: %w", projectID, err)
+	}
+	if project.ProgressMode == "" {
+		return models.ProgressManual, nil
+	}
+	return project.ProgressMode, nil
+}
+
+// recomputeTaskProgress derives the progress of a task from its subtasks according to the project's mode.
+// the task row is locked so concurrent subtask changes recompute one after the other.
+func recomputeTaskProgress(tx *gorm.DB, taskID uint) error {
+	var task models.Task
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "project_id").First(&task, taskID).Error; err != nil {
+		return fmt.Errorf("failed to get task %d: %w", taskID, err)
+	}
+
+	mode, err := projectProgressMode(tx, task.ProjectID)
+	if err != nil {
+		return err
+	}
+	if mode == models.ProgressManual {
+		return nil
+	}
+
+	var totals struct {
+		Total     int64
+		Completed int64
+	}
+	query := tx.Model(&models.Subtask{}).Where("task_id = ?", taskID)
+	if mode == models.ProgressWeightedSubtasks {
+		query = query.Select("COALESCE(SUM(weight), 0) AS total, COALESCE(SUM(CASE WHEN is_completed THEN weight ELSE 0 END), 0) AS completed")
+	} else {
+		query = query.Select("COUNT(*) AS total, COUNT(CASE WHEN is_completed THEN 1 END) AS completed")
+	}
+	if err := query.Scan(&totals).Error; err != nil {
+		return fmt.Errorf("failed to compute progress of task %d: %w", taskID, err)
+	}
+
+	// derived progress can't be edited by hand, a task without subtasks has none
+	progress := 0
+	if totals.Total > 0 {
+		progress = int(totals.Completed * 100 / totals.Total)
+	}
+	err = tx.Model(&models.Task{ID: taskID, ProjectID: task.ProjectID}).
+		Where("progress_percentage <> ?", progress).
+		Updates(map[string]interface{}{"progress_percentage": progress, "version": gorm.Expr("version + 1")}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update progress of task %d: %w", taskID, err)
+	}
+	return nil
+}
+
+// recomputeProjectProgress recomputes every task of a project, used when its progress mode changes
+func recomputeProjectProgress(tx *gorm.DB, projectID uint) error {
+	var taskIDs []uint
+	if err := tx.Model(&models.Task{}).Where("project_id = ?", projectID).Pluck("id", &taskIDs).Error; err != nil {
+		return err
+	}
+	for _, taskID := range taskIDs {
+		if err := recomputeTaskProgress(tx, taskID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -7,35 +7,53 @@ import (
 	"mizito/internal/database"
 	"mizito/internal/repositories/utils"
 	"mizito/pkg/models"
+	"time"
 )
 
+// ErrArchived is returned for writes to an archived team or project and to anything inside them
+var ErrArchived = errors.New("the team or project is archived and read-only")
+
 type ProjectCrudRepo interface {
 	CreateProject(project *models.Project, requestUserID uint) (uint, error)
-	UpdateProject(projectID uint, project *models.Project, requestUserID uint) (uint, error)
+	// UpdateProject sets the fields of patch on the project, it fails with ErrVersionConflict when
+	// expectedVersion isn't 0 and the project was updated since, it returns the new version
+	UpdateProject(projectID uint, patch *ProjectPatch, expectedVersion uint, requestUserID uint) (uint, error)
+	// DeleteProject moves the project and its tasks to the trash
 	DeleteProject(projectID uint, requestUserID uint) (uint, error)
+	// RestoreProject brings a project back from the trash with the tasks deleted together with it
+	RestoreProject(projectID uint, requestUserID uint) (uint, error)
+	// ArchiveProject makes the project read-only and hides it from the listings
+	ArchiveProject(projectID uint, requestUserID uint) (uint, error)
+	// UnarchiveProject makes an archived project writable again, only team admins may do it
+	UnarchiveProject(projectID uint, requestUserID uint) (uint, error)
 	GetProjectByID(projectID uint, requestUserID uint) (*models.Project, error)
 }
 
 type ProjectDetailRepo interface {
-	GetProjectsByUser(userID uint) ([]models.Project, error)
+	// GetProjectsByUser lists the projects of the user's teams, archived ones only when includeArchived is set
+	GetProjectsByUser(userID uint, includeArchived bool) ([]models.Project, error)
 	// GetProjectMembers will use redis to cache the members for clients
 	// one problem is that each message might require a query lookup for db to find the corresponding users
 	// associated with ProjectID which is derived from event
 	// the method can leverage redis handler and main db
 	// one pattern might be cache aside pattern
 	GetProjectMembers(ProjectID uint) ([]models.TeamMember, error)
-	AddUserToProject(ProjectID uint, userID uint, requestUserID uint) error
-	GetUsersByProjectID(ProjectID uint, requestUserID uint) ([]uint, error)
+	// AddUserToProject adds a team member to the project with a project role, contributor when empty
+	AddUserToProject(ProjectID uint, userID uint, role models.Role, requestUserID uint) error
+	GetUsersByProjectID(ProjectID uint, requestUserID uint) ([]models.ProjectMember, error)
 }
 
 type ProjectRepository interface {
 	ProjectDetailRepo
 	ProjectCrudRepo
+	// WithRequest returns a copy of the repository that records its activities for the request
+	WithRequest(request RequestInfo) ProjectRepository
 }
 
 type projectRepository struct {
 	permissionRepo utils.PermissionRepository
 	DB             *gorm.DB
+	request        RequestInfo
 }
 
 func NewProjectRepository(postgreSql *database.DatabaseHandler) ProjectRepository {
@@ -43,7 +61,26 @@ func NewProjectRepository(postgreSql *database.DatabaseHandler) ProjectRepositor
 	return &projectRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
 }
 
-func (th *projectRepository) GetProjectsByUser(userID uint) ([]models.Project, error) {
+func (th *projectRepository) WithRequest(request RequestInfo) ProjectRepository {
+	scoped := *th
+	scoped.request = request
+	return &scoped
+}
+
+// projectActivity is an activity on a project placed in the feeds of the project and its team
+func projectActivity(action models.ActivityAction, project *models.Project, changes models.ActivityChanges, requestUserID uint) models.Activity {
+	return models.Activity{
+		ActorID:    requestUserID,
+		Action:     action,
+		TargetType: models.ProjectTarget,
+		TargetID:   project.ID,
+		TeamID:     &project.TeamID,
+		ProjectID:  &project.ID,
+		Changes:    changes,
+	}
+}
+
+func (th *projectRepository) GetProjectsByUser(userID uint, includeArchived bool) ([]models.Project, error) {
 	var projects []models.Project
 
 	var teamMembers []models.TeamMember
@@ -57,11 +94,24 @@ func (th *projectRepository) GetProjectsByUser(userID uint) ([]models.Project, e
 		teamIDs = append(teamIDs, tm.TeamID)
 	}
 
-	if err := th.DB.Where("id IN ?", teamIDs).Find(&teams).Error; err != nil {
+	teamsQuery := th.DB.Where("id IN ?", teamIDs)
+	if !includeArchived {
+		teamsQuery = teamsQuery.Where("archived_at IS NULL")
+	}
+	if err := teamsQuery.Find(&teams).Error; err != nil {
 		return nil, err
 	}
 
-	if err := th.DB.Where("team_id IN ?", teamIDs).Find(&projects).Error; err != nil {
+	teamIDs = teamIDs[:0]
+	for _, team := range teams {
+		teamIDs = append(teamIDs, team.ID)
+	}
+
+	projectsQuery := th.DB.Where("team_id IN ?", teamIDs)
+	if !includeArchived {
+		projectsQuery = projectsQuery.Where("archived_at IS NULL")
+	}
+	if err := projectsQuery.Find(&projects).Error; err != nil {
 		return nil, err
 	}
 
@@ -69,8 +119,19 @@ func (th *projectRepository) GetProjectsByUser(userID uint) ([]models.Project, e
 }
 
 func (th *projectRepository) CreateProject(project *models.Project, requestUserID uint) (uint, error) {
-	if !th.permissionRepo.CheckUserIsAdminOfTeam(requestUserID, project.TeamID) {
-		return 0, errors.New("you are not an admin of the team")
+	if !th.permissionRepo.Authorize(requestUserID, models.ProjectCreate, utils.TeamResource(project.TeamID)) {
+		return 0, errors.New("you don't have permission to create projects in the team")
+	}
+	if th.permissionRepo.CheckArchived(utils.TeamResource(project.TeamID)) {
+		return 0, ErrArchived
+	}
+	// projects are archived through ArchiveProject
+	project.ArchivedAt = nil
+
+	if project.ProgressMode == "" {
+		project.ProgressMode = models.ProgressManual
+	} else if !models.IsValidProgressMode(project.ProgressMode) {
+		return 0, fmt.Errorf("unknown progress mode %s", project.ProgressMode)
 	}
 
 	tx := th.DB.Begin()
@@ -87,12 +148,25 @@ func (th *projectRepository) CreateProject(project *models.Project, requestUserI
 		return 0, err
 	}
 
-	// Add the user to the project as a member within the transaction
-	if err := tx.Model(project).Association("ProjectMembers").Append(&models.User{ID: requestUserID}); err != nil {
+	// Add the user to the project as its owner within the transaction
+	owner := models.ProjectMember{ProjectID: project.ID, UserID: requestUserID, Role: models.ProjectOwner}
+	if err := tx.Create(&owner).Error; err != nil {
 		tx.Rollback()
 		return 0, fmt.Errorf("failed to add user to project: %w", err)
 	}
 
+	// Every project starts with the default board columns
+	if err := createWorkflow(tx, project.ID); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	created := projectActivity(models.ActivityCreated, project, activityChanges(nil, project), requestUserID)
+	if err := recordActivity(tx, th.request, created); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
 	// Commit the transaction if everything is successful
 	if err := tx.Commit().Error; err != nil {
 		tx.Rollback()
@@ -103,7 +177,7 @@ func (th *projectRepository) CreateProject(project *models.Project, requestUserI
 }
 
 func (th *projectRepository) GetProjectByID(projectID uint, requestUserID uint) (*models.Project, error) {
-	if !th.permissionRepo.CheckUserHasAccessToProject(projectID, requestUserID) {
+	if !th.permissionRepo.Authorize(requestUserID, models.ProjectView, utils.ProjectResource(projectID)) {
 		return nil, errors.New("you don't have access to the project")
 	}
 
@@ -114,9 +188,12 @@ func (th *projectRepository) GetProjectByID(projectID uint, requestUserID uint)
 	return &project, nil
 }
 
-func (th *projectRepository) UpdateProject(projectID uint, project *models.Project, requestUserID uint) (uint, error) {
-	if !th.permissionRepo.CheckUserIsAdminOfProject(projectID, requestUserID) {
-		return 0, errors.New("only admins can update the project")
+func (th *projectRepository) UpdateProject(projectID uint, patch *ProjectPatch, expectedVersion uint, requestUserID uint) (uint, error) {
+	if !th.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(projectID)) {
+		return 0, errors.New("you don't have permission to update the project")
+	}
+	if th.permissionRepo.CheckArchived(utils.ProjectResource(projectID)) {
+		return 0, ErrArchived
 	}
 
 	var existingProject models.Project
@@ -124,33 +201,80 @@ func (th *projectRepository) UpdateProject(projectID uint, project *models.Proje
 		return 0, err
 	}
 
-	if err := th.DB.Model(&existingProject).Updates(project).Error; err != nil {
+	if patch.ProgressMode != nil && !models.IsValidProgressMode(*patch.ProgressMode) {
+		return 0, fmt.Errorf("unknown progress mode %s", *patch.ProgressMode)
+	}
+	modeChanged := patch.ProgressMode != nil && *patch.ProgressMode != existingProject.ProgressMode
+
+	// Switching the progress mode recomputes every task of the project
+	var version uint
+	err := th.DB.Transaction(func(tx *gorm.DB) error {
+		if err := applyPatch(tx, &models.Project{ID: projectID, TeamID: existingProject.TeamID}, projectID, existingProject.Version, expectedVersion, patch.changes()); err != nil {
+			return err
+		}
+		var after models.Project
+		if err := tx.First(&after, projectID).Error; err != nil {
+			return err
+		}
+		version = after.Version
+		updated := projectActivity(models.ActivityUpdated, &after, activityChanges(&existingProject, &after), requestUserID)
+		if err := recordActivity(tx, th.request, updated); err != nil {
+			return err
+		}
+		if modeChanged {
+			return recomputeProjectProgress(tx, existingProject.ID)
+		}
+		return nil
+	})
+	if err != nil {
 		return 0, err
 	}
-	return existingProject.ID, nil
+	return version, nil
 }
 
 func (th *projectRepository) DeleteProject(projectID uint, requestUserID uint) (uint, error) {
-	if !th.permissionRepo.CheckUserIsAdminOfProject(projectID, requestUserID) {
-		return 0, errors.New("only admins can update the project")
+	if !th.permissionRepo.Authorize(requestUserID, models.ProjectDelete, utils.ProjectResource(projectID)) {
+		return 0, errors.New("you don't have permission to delete the project")
 	}
 
-	if err := th.DB.Delete(&models.Project{}, projectID).Error; err != nil {
+	var project models.Project
+	if err := th.DB.First(&project, projectID).Error; err != nil {
+		return 0, err
+	}
+	// an archived project can be moved to the trash, unless its team is archived as well
+	if th.permissionRepo.CheckArchived(utils.TeamResource(project.TeamID)) {
+		return 0, ErrArchived
+	}
+
+	// the tasks share the deletion time of the project, which tells them apart from the tasks
+	// that were in the trash already on a restore
+	deletedAt := time.Now()
+	err := th.DB.Transaction(func(tx *gorm.DB) error {
+		deleted := projectActivity(models.ActivityDeleted, &project, activityChanges(&project, nil), requestUserID)
+		if err := recordActivity(tx, th.request, deleted); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Task{}).Where("project_id = ?", project.ID).Update("deleted_at", deletedAt).Error; err != nil {
+			return err
+		}
+		return tx.Model(&project).Update("deleted_at", deletedAt).Error
+	})
+	if err != nil {
 		return 0, err
 	}
 	return projectID, nil
 }
 
-func (th *projectRepository) GetUsersByProjectID(projectID uint, requestUserID uint) ([]uint, error) {
-	if !th.permissionRepo.CheckUserHasAccessToProject(projectID, requestUserID) {
+func (th *projectRepository) GetUsersByProjectID(projectID uint, requestUserID uint) ([]models.ProjectMember, error) {
+	if !th.permissionRepo.Authorize(requestUserID, models.ProjectView, utils.ProjectResource(projectID)) {
 		return nil, errors.New("you don't have access to the project")
 	}
 
-	var userIDs []uint
-	if err := th.DB.Model(&models.TeamMember{}).Where("project_id = ?", projectID).Pluck("user_id", &userIDs).Error; err != nil {
+	var members []models.ProjectMember
+	if err := th.DB.Where("project_id = ?", projectID).Find(&members).Error; err != nil {
 		return nil, err
 	}
-	return userIDs, nil
+	return members, nil
 }
 
 func (th *projectRepository) GetProjectMembers(userID uint) ([]models.TeamMember, error) {
@@ -161,9 +285,12 @@ func (th *projectRepository) GetProjectMembers(userID uint) ([]models.TeamMember
 	return teamMembers, nil
 }
 
-func (th *projectRepository) AddUserToProject(projectID uint, userID uint, requestUserID uint) error {
-	if !th.permissionRepo.CheckUserIsAdminOfProject(projectID, requestUserID) {
-		return errors.New("only admins can update the project")
+func (th *projectRepository) AddUserToProject(projectID uint, userID uint, role models.Role, requestUserID uint) error {
+	if !th.permissionRepo.Authorize(requestUserID, models.ProjectAddMember, utils.ProjectResource(projectID)) {
+		return errors.New("you don't have permission to add members to the project")
+	}
+	if th.permissionRepo.CheckArchived(utils.ProjectResource(projectID)) {
+		return ErrArchived
 	}
 	var project models.Project
 	if err := th.DB.First(&project, projectID).Error; err != nil {
@@ -173,6 +300,13 @@ func (th *projectRepository) AddUserToProject(projectID uint, userID uint, reque
 		return fmt.Errorf("failed to fetch project: %w", err)
 	}
 
+	if role == "" {
+		role = models.ProjectContributor
+	}
+	if err := th.permissionRepo.CheckRoleGrant(requestUserID, utils.ProjectResource(projectID), role); err != nil {
+		return err
+	}
+
 	var user models.User
 	if err := th.DB.First(&user, userID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -181,9 +315,147 @@ func (th *projectRepository) AddUserToProject(projectID uint, userID uint, reque
 		return fmt.Errorf("failed to fetch user: %w", err)
 	}
 
-	if err := th.DB.Model(&project).Association("ProjectMembers").Append(&user); err != nil {
+	if !th.permissionRepo.CheckUserHasAccessToTeam(userID, project.TeamID) {
+		return fmt.Errorf("user with ID %d is not a member of the project's team", userID)
+	}
+
+	// the role of an existing member is replaced
+	var previousRole interface{}
+	var existing models.ProjectMember
+	if err := th.DB.Where("project_id = ? AND user_id = ?", project.ID, user.ID).Limit(1).Find(&existing).Error; err != nil {
+		return fmt.Errorf("failed to fetch project member: %w", err)
+	}
+	if existing.UserID != 0 {
+		previousRole = existing.Role
+	}
+
+	member := models.ProjectMember{ProjectID: project.ID, UserID: user.ID, Role: role}
+	err := th.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&member).Error; err != nil {
+			return err
+		}
+		changes := models.ActivityChanges{"UserID": {After: user.ID}, "Role": {Before: previousRole, After: role}}
+		return recordActivity(tx, th.request, projectActivity(models.ActivityMemberAdded, &project, changes, requestUserID))
+	})
+	if err != nil {
 		return fmt.Errorf("failed to add user to project: %w", err)
 	}
 
 	return nil
 }
+
+func (th *projectRepository) RestoreProject(projectID uint, requestUserID uint) (uint, error) {
+	var project models.Project
+	if err := th.DB.Unscoped().First(&project, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("project with ID %d does not exist", projectID)
+		}
+		return 0, err
+	}
+	if !project.DeletedAt.Valid {
+		return 0, fmt.Errorf("project %d is not in the trash", projectID)
+	}
+
+	var team models.Team
+	if err := th.DB.Select("id").First(&team, project.TeamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("team %d of the project is in the trash, restore it first", project.TeamID)
+		}
+		return 0, err
+	}
+	if !th.permissionRepo.Authorize(requestUserID, models.ProjectDelete, utils.TeamResource(project.TeamID)) {
+		return 0, errors.New("you don't have permission to restore the project")
+	}
+	if th.permissionRepo.CheckArchived(utils.TeamResource(project.TeamID)) {
+		return 0, ErrArchived
+	}
+
+	err := th.DB.Transaction(func(tx *gorm.DB) error {
+		if err := restoreProjectTasks(tx, []uint{project.ID}, project.DeletedAt.Time); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&project).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, th.request, projectActivity(models.ActivityRestored, &project, nil, requestUserID))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore project %d: %w", projectID, err)
+	}
+	return project.ID, nil
+}
+
+func (th *projectRepository) ArchiveProject(projectID uint, requestUserID uint) (uint, error) {
+	if !th.permissionRepo.Authorize(requestUserID, models.ProjectDelete, utils.ProjectResource(projectID)) {
+		return 0, errors.New("you don't have permission to archive the project")
+	}
+
+	var project models.Project
+	if err := th.DB.First(&project, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("project with ID %d does not exist", projectID)
+		}
+		return 0, err
+	}
+	if project.ArchivedAt != nil {
+		return 0, fmt.Errorf("project %d is already archived", projectID)
+	}
+	if th.permissionRepo.CheckArchived(utils.TeamResource(project.TeamID)) {
+		return 0, ErrArchived
+	}
+
+	archivedAt := time.Now()
+	err := th.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&project).Update("archived_at", archivedAt).Error; err != nil {
+			return err
+		}
+		changes := models.ActivityChanges{"ArchivedAt": {After: archivedAt}}
+		return recordActivity(tx, th.request, projectActivity(models.ActivityArchived, &project, changes, requestUserID))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive project %d: %w", projectID, err)
+	}
+	return project.ID, nil
+}
+
+func (th *projectRepository) UnarchiveProject(projectID uint, requestUserID uint) (uint, error) {
+	var project models.Project
+	if err := th.DB.First(&project, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("project with ID %d does not exist", projectID)
+		}
+		return 0, err
+	}
+	if !th.permissionRepo.CheckUserIsSystemAdmin(requestUserID) && !th.permissionRepo.CheckUserIsAdminOfTeam(requestUserID, project.TeamID) {
+		return 0, errors.New("you don't have permission to unarchive the project")
+	}
+	if project.ArchivedAt == nil {
+		return 0, fmt.Errorf("project %d is not archived", projectID)
+	}
+	if th.permissionRepo.CheckArchived(utils.TeamResource(project.TeamID)) {
+		return 0, fmt.Errorf("team %d of the project is archived, unarchive it first", project.TeamID)
+	}
+
+	err := th.DB.Transaction(func(tx *gorm.DB) error {
+		changes := models.ActivityChanges{"ArchivedAt": {Before: *project.ArchivedAt}}
+		if err := tx.Model(&project).Update("archived_at", nil).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, th.request, projectActivity(models.ActivityUnarchived, &project, changes, requestUserID))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to unarchive project %d: %w", projectID, err)
+	}
+	return project.ID, nil
+}
+
+// restoreProjectTasks restores the tasks of projects that were deleted together with them
+func restoreProjectTasks(tx *gorm.DB, projectIDs []uint, deletedAt time.Time) error {
+	err := tx.Unscoped().Model(&models.Task{}).
+		Where("project_id IN ? AND deleted_at = ?", projectIDs, deletedAt).
+		Update("deleted_at", nil).Error
+	if err != nil {
+		return fmt.Errorf("failed to restore tasks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type RecurrenceRepository interface {
+	// GenerateOccurrences creates the next occurrence of every recurring task that is done or overdue,
+	// outside archived teams and projects, it returns how many tasks were created
+	GenerateOccurrences(now time.Time) (int, error)
+}
+
+type recurrenceRepository struct {
+	DB *gorm.DB
+}
+
+func NewRecurrenceRepository(postgreSql *database.DatabaseHandler) RecurrenceRepository {
+	return &recurrenceRepository{DB: postgreSql.DB}
+}
+
+func (rr *recurrenceRepository) GenerateOccurrences(now time.Time) (int, error) {
+	var taskIDs []uint
+	err := rr.DB.Model(&models.Task{}).
+		Joins("LEFT JOIN workflow_statuses ON workflow_statuses.id = tasks.status_id").
+		// archived teams and projects are read-only, their series pause until they are unarchived
+		Joins("JOIN projects ON projects.id = tasks.project_id AND projects.archived_at IS NULL").
+		Joins("JOIN teams ON teams.id = projects.team_id AND teams.archived_at IS NULL").
+		Where("tasks.recurrence_rule <> '' AND tasks.recurrence_spawned = ?", false).
+		Where("tasks.due_date < ? OR workflow_statuses.is_done = ?", now, true).
+		Pluck("tasks.id", &taskIDs).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to find recurring tasks: %w", err)
+	}
+
+	created := 0
+	for _, taskID := range taskIDs {
+		ok, err := rr.spawnNext(taskID, now)
+		if err != nil {
+			return created, err
+		}
+		if ok {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// spawnNext creates the occurrence following a task. the task is claimed by flipping
+// RecurrenceSpawned inside the transaction, so several schedulers never create it twice.
+func (rr *recurrenceRepository) spawnNext(taskID uint, now time.Time) (bool, error) {
+	tx := rr.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	claim := tx.Model(&models.Task{}).
+		Where("id = ? AND recurrence_spawned = ?", taskID, false).
+		Updates(map[string]interface{}{"recurrence_spawned": true, "version": gorm.Expr("version + 1")})
+	if claim.Error != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to claim task %d: %w", taskID, claim.Error)
+	}
+	if claim.RowsAffected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	var task models.Task
+	if err := tx.Preload("Subtasks").Preload("Members").Preload("Labels").Preload("FieldValues").First(&task, taskID).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	rule, err := utils.ParseRRule(task.RecurrenceRule)
+	if err != nil {
+		// a broken rule stays claimed so it isn't retried forever
+		return false, tx.Commit().Error
+	}
+	// a series that fell behind resumes at its first occurrence after now instead of creating
+	// every missed one, the skipped occurrences still count towards the rule's COUNT
+	dueDate, index := task.DueDate, task.RecurrenceIndex
+	for {
+		next, ok := rule.Next(dueDate, index)
+		if !ok {
+			return false, tx.Commit().Error
+		}
+		dueDate, index = next, index+1
+		if dueDate.After(now) {
+			break
+		}
+	}
+
+	parentID := task.ID
+	if task.RecurrenceParentID != nil {
+		parentID = *task.RecurrenceParentID
+	}
+
+	statuses, err := projectWorkflow(tx, task.ProjectID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	lastRank, err := lastRankInStatus(tx, statuses[0].ID, 0)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	next := models.Task{
+		ProjectID:          task.ProjectID,
+		Title:              task.Title,
+		Description:        task.Description,
+		TaskPriority:       task.TaskPriority,
+		Members:            task.Members,
+		Labels:             task.Labels,
+		DueDate:            dueDate,
+		StatusID:           &statuses[0].ID,
+		Rank:               utils.RankBetween(lastRank, ""),
+		RecurrenceRule:     task.RecurrenceRule,
+		RecurrenceParentID: &parentID,
+		RecurrenceIndex:    index,
+	}
+	for _, subtask := range task.Subtasks {
+		next.Subtasks = append(next.Subtasks, models.Subtask{Title: subtask.Title, Weight: subtask.Weight})
+	}
+	for _, value := range task.FieldValues {
+		next.FieldValues = append(next.FieldValues, models.TaskFieldValue{
+			FieldID:     value.FieldID,
+			Value:       value.Value,
+			NumberValue: value.NumberValue,
+			DateValue:   value.DateValue,
+		})
+	}
+
+	// members and labels already exist, only the join rows are created
+	if err := tx.Omit("Members.*", "Labels.*").Create(&next).Error; err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to create next occurrence of task %d: %w", taskID, err)
+	}
+	if err := recordTaskCreated(tx, &next, 0); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	// the scheduler isn't serving a request, the occurrence is recorded as made by the system
+	created := models.Activity{
+		Action:     models.ActivityCreated,
+		TargetType: models.TaskTarget,
+		TargetID:   next.ID,
+		ProjectID:  &next.ProjectID,
+		Changes:    activityChanges(nil, &next),
+	}
+	if err := recordActivity(tx, RequestInfo{}, created); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
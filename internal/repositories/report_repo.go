@@ -0,0 +1,243 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type ReportDetail struct {
+	models.Report
+	Username  string `json:"username"`
+	TaskTitle string `json:"task_title"`
+}
+
+type MemberReports struct {
+	UserID   uint           `json:"user_id"`
+	Username string         `json:"username"`
+	Reports  []ReportDetail `json:"reports"`
+}
+
+type MemberReportSummary struct {
+	UserID       uint           `json:"user_id"`
+	Username     string         `json:"username"`
+	ReportCount  int            `json:"report_count"`
+	DaysReported int            `json:"days_reported"`
+	Blockers     []string       `json:"blockers"`
+	Reports      []ReportDetail `json:"reports"`
+}
+
+type ReportRepository interface {
+	// CreateReport files the report of a task member for a day, once per task and day
+	CreateReport(report *models.Report, requestUserID uint) (uint, error)
+	GetTaskReports(taskID uint, requestUserID uint) ([]ReportDetail, error)
+	UpdateReport(report *models.Report, requestUserID uint) (uint, error)
+	DeleteReport(reportID uint, requestUserID uint) (uint, error)
+	// GetProjectStandup groups the reports of a project for one day by member
+	GetProjectStandup(projectID uint, date time.Time, requestUserID uint) ([]MemberReports, error)
+	GetProjectReportSummary(projectID uint, from time.Time, to time.Time, requestUserID uint) ([]MemberReportSummary, error)
+}
+
+type reportRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+}
+
+func NewReportRepository(postgreSql *database.DatabaseHandler) ReportRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &reportRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (rr *reportRepository) isTaskMember(taskID uint, userID uint) bool {
+	var count int64
+	err := rr.DB.Table("task_members").Where("task_id = ? AND user_id = ?", taskID, userID).Count(&count).Error
+	return err == nil && count > 0
+}
+
+func (rr *reportRepository) CreateReport(report *models.Report, requestUserID uint) (uint, error) {
+	if !rr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(report.TaskID)) {
+		return 0, errors.New("you don't have access to the task")
+	}
+	if rr.permissionRepo.CheckArchived(utils.TaskResource(report.TaskID)) {
+		return 0, ErrArchived
+	}
+	if !rr.isTaskMember(report.TaskID, requestUserID) {
+		return 0, errors.New("only members of the task can report on it")
+	}
+	if strings.TrimSpace(report.Message) == "" {
+		return 0, errors.New("report message is required")
+	}
+
+	if report.ReportDate.IsZero() {
+		report.ReportDate = time.Now()
+	}
+	report.ReportDate = truncateToDay(report.ReportDate)
+	if report.ReportDate.After(time.Now()) {
+		return 0, errors.New("reports can't be filed for future days")
+	}
+
+	var existing int64
+	err := rr.DB.Model(&models.Report{}).
+		Where("task_id = ? AND user_id = ? AND report_date = ?", report.TaskID, requestUserID, report.ReportDate).
+		Count(&existing).Error
+	if err != nil {
+		return 0, err
+	}
+	if existing > 0 {
+		return 0, fmt.Errorf("you already reported on task %d for %s, edit that report instead", report.TaskID, report.ReportDate.Format("2006-01-02"))
+	}
+
+	report.ID = 0
+	report.UserID = requestUserID
+	if err := rr.DB.Create(report).Error; err != nil {
+		return 0, fmt.Errorf("failed to create report: %w", err)
+	}
+	return report.ID, nil
+}
+
+func (rr *reportRepository) reportDetails(query *gorm.DB) ([]ReportDetail, error) {
+	reports := []ReportDetail{}
+	err := query.Model(&models.Report{}).
+		Select("reports.*, users.username, tasks.title AS task_title").
+		Joins("JOIN users ON users.id = reports.user_id").
+		Joins("JOIN tasks ON tasks.id = reports.task_id AND tasks.deleted_at IS NULL").
+		Order("reports.report_date DESC, reports.id").
+		Scan(&reports).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reports: %w", err)
+	}
+	return reports, nil
+}
+
+func (rr *reportRepository) GetTaskReports(taskID uint, requestUserID uint) ([]ReportDetail, error) {
+	if !rr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(taskID)) {
+		return nil, errors.New("you don't have access to the task")
+	}
+	return rr.reportDetails(rr.DB.Where("reports.task_id = ?", taskID))
+}
+
+// ownReport loads a changeable report written by the user, nobody edits the reports of others
+func (rr *reportRepository) ownReport(reportID uint, requestUserID uint) (*models.Report, error) {
+	var report models.Report
+	if err := rr.DB.First(&report, reportID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("report %d not found", reportID)
+		}
+		return nil, err
+	}
+	if report.UserID != requestUserID {
+		return nil, errors.New("you can only change your own reports")
+	}
+	if rr.permissionRepo.CheckArchived(utils.TaskResource(report.TaskID)) {
+		return nil, ErrArchived
+	}
+	return &report, nil
+}
+
+func (rr *reportRepository) UpdateReport(report *models.Report, requestUserID uint) (uint, error) {
+	existingReport, err := rr.ownReport(report.ID, requestUserID)
+	if err != nil {
+		return 0, err
+	}
+	if strings.TrimSpace(report.Message) == "" {
+		return 0, errors.New("report message is required")
+	}
+
+	err = rr.DB.Model(existingReport).Updates(map[string]interface{}{
+		"message":  report.Message,
+		"plan":     report.Plan,
+		"blockers": report.Blockers,
+	}).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to update report %d: %w", report.ID, err)
+	}
+	return existingReport.ID, nil
+}
+
+func (rr *reportRepository) DeleteReport(reportID uint, requestUserID uint) (uint, error) {
+	report, err := rr.ownReport(reportID, requestUserID)
+	if err != nil {
+		return 0, err
+	}
+	if err := rr.DB.Delete(report).Error; err != nil {
+		return 0, fmt.Errorf("failed to delete report %d: %w", reportID, err)
+	}
+	return report.ID, nil
+}
+
+func (rr *reportRepository) GetProjectStandup(projectID uint, date time.Time, requestUserID uint) ([]MemberReports, error) {
+	if !rr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.ProjectResource(projectID)) {
+		return nil, errors.New("you don't have access to the project")
+	}
+
+	reports, err := rr.reportDetails(rr.DB.Where("tasks.project_id = ? AND reports.report_date = ?", projectID, truncateToDay(date)))
+	if err != nil {
+		return nil, err
+	}
+
+	standup := []MemberReports{}
+	members := make(map[uint]int)
+	for _, report := range reports {
+		i, ok := members[report.UserID]
+		if !ok {
+			i = len(standup)
+			members[report.UserID] = i
+			standup = append(standup, MemberReports{UserID: report.UserID, Username: report.Username})
+		}
+		standup[i].Reports = append(standup[i].Reports, report)
+	}
+	sort.Slice(standup, func(i, j int) bool { return standup[i].Username < standup[j].Username })
+	return standup, nil
+}
+
+func (rr *reportRepository) GetProjectReportSummary(projectID uint, from time.Time, to time.Time, requestUserID uint) ([]MemberReportSummary, error) {
+	if !rr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.ProjectResource(projectID)) {
+		return nil, errors.New("you don't have access to the project")
+	}
+
+	reports, err := rr.reportDetails(rr.DB.Where(
+		"tasks.project_id = ? AND reports.report_date >= ? AND reports.report_date < ?",
+		projectID, truncateToDay(from), to,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := []MemberReportSummary{}
+	members := make(map[uint]int)
+	days := make(map[uint]map[time.Time]bool)
+	for _, report := range reports {
+		i, ok := members[report.UserID]
+		if !ok {
+			i = len(summaries)
+			members[report.UserID] = i
+			days[report.UserID] = make(map[time.Time]bool)
+			summaries = append(summaries, MemberReportSummary{UserID: report.UserID, Username: report.Username, Blockers: []string{}})
+		}
+
+		summary := &summaries[i]
+		summary.ReportCount++
+		summary.Reports = append(summary.Reports, report)
+		if !days[report.UserID][report.ReportDate] {
+			days[report.UserID][report.ReportDate] = true
+			summary.DaysReported++
+		}
+		if blockers := strings.TrimSpace(report.Blockers); blockers != "" {
+			summary.Blockers = append(summary.Blockers, blockers)
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Username < summaries[j].Username })
+	return summaries, nil
+}
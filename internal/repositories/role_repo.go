@@ -0,0 +1,313 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type RoleDetail struct {
+	ID          uint                `json:"id,omitempty"`
+	Name        models.Role         `json:"name"`
+	Builtin     bool                `json:"builtin"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+type RoleRepository interface {
+	GetTeamRoles(teamID uint, requestUserID uint) ([]RoleDetail, error)
+	CreateRole(teamID uint, name models.Role, permissions []models.Permission, requestUserID uint) (uint, error)
+	UpdateRole(teamID uint, roleID uint, permissions []models.Permission, requestUserID uint) (uint, error)
+	DeleteRole(teamID uint, roleID uint, requestUserID uint) (uint, error)
+	AssignTeamRole(teamID uint, userID uint, role models.Role, requestUserID uint) error
+	// AssignProjectRole sets the role of a project member, an empty role falls back to the team role.
+	AssignProjectRole(projectID uint, userID uint, role models.Role, requestUserID uint) error
+	// WithRequest returns a copy of the repository that records its activities for the request
+	WithRequest(request RequestInfo) RoleRepository
+}
+
+type roleRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+	request        RequestInfo
+}
+
+func NewRoleRepository(postgreSql *database.DatabaseHandler) RoleRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &roleRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+func (rr *roleRepository) WithRequest(request RequestInfo) RoleRepository {
+	scoped := *rr
+	scoped.request = request
+	return &scoped
+}
+
+func validatePermissions(permissions []models.Permission) error {
+	for _, permission := range permissions {
+		if !models.IsValidPermission(permission) {
+			return fmt.Errorf("unknown permission %s", permission)
+		}
+	}
+	return nil
+}
+
+func toRolePermissions(roleID uint, permissions []models.Permission) []models.RolePermission {
+	seen := make(map[models.Permission]bool)
+	var rolePermissions []models.RolePermission
+	for _, permission := range permissions {
+		if seen[permission] {
+			continue
+		}
+		seen[permission] = true
+		rolePermissions = append(rolePermissions, models.RolePermission{RoleID: roleID, Permission: permission})
+	}
+	return rolePermissions
+}
+
+func (rr *roleRepository) GetTeamRoles(teamID uint, requestUserID uint) ([]RoleDetail, error) {
+	if !rr.permissionRepo.Authorize(requestUserID, models.TeamView, utils.TeamResource(teamID)) {
+		return nil, errors.New("you don't have access to the team")
+	}
+
+	var roles []RoleDetail
+	builtinRoles := []models.Role{
+		models.Admin, models.Member,
+		models.ProjectOwner, models.ProjectMaintainer, models.ProjectContributor, models.ProjectViewer,
+	}
+	for _, name := range builtinRoles {
+		permissions, _ := models.BuiltinPermissions(name)
+		roles = append(roles, RoleDetail{
+			Name:        name,
+			Builtin:     true,
+			Permissions: permissions,
+		})
+	}
+
+	var customRoles []models.CustomRole
+	if err := rr.DB.Preload("Permissions").Where("team_id = ?", teamID).Order("name").Find(&customRoles).Error; err != nil {
+		return nil, fmt.Errorf("failed to get roles for team %d: %w", teamID, err)
+	}
+	for _, customRole := range customRoles {
+		detail := RoleDetail{ID: customRole.ID, Name: customRole.Name, Permissions: []models.Permission{}}
+		for _, rolePermission := range customRole.Permissions {
+			detail.Permissions = append(detail.Permissions, rolePermission.Permission)
+		}
+		roles = append(roles, detail)
+	}
+
+	return roles, nil
+}
+
+func (rr *roleRepository) CreateRole(teamID uint, name models.Role, permissions []models.Permission, requestUserID uint) (uint, error) {
+	if !rr.permissionRepo.Authorize(requestUserID, models.RoleManage, utils.TeamResource(teamID)) {
+		return 0, errors.New("you don't have permission to manage the team roles")
+	}
+	if rr.permissionRepo.CheckArchived(utils.TeamResource(teamID)) {
+		return 0, ErrArchived
+	}
+	if name == "" {
+		return 0, errors.New("role name is required")
+	}
+	if models.IsProjectRole(name) || rr.permissionRepo.RoleExists(teamID, name) {
+		return 0, fmt.Errorf("role %s already exists", name)
+	}
+	if err := validatePermissions(permissions); err != nil {
+		return 0, err
+	}
+
+	role := models.CustomRole{TeamID: teamID, Name: name}
+
+	tx := rr.DB.Begin()
+	if tx.Error != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", tx.Error)
+	}
+
+	if err := tx.Create(&role).Error; err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create role: %w", err)
+	}
+
+	if rolePermissions := toRolePermissions(role.ID, permissions); len(rolePermissions) > 0 {
+		if err := tx.Create(&rolePermissions).Error; err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to grant permissions to role %s: %w", name, err)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return role.ID, nil
+}
+
+func (rr *roleRepository) UpdateRole(teamID uint, roleID uint, permissions []models.Permission, requestUserID uint) (uint, error) {
+	if !rr.permissionRepo.Authorize(requestUserID, models.RoleManage, utils.TeamResource(teamID)) {
+		return 0, errors.New("you don't have permission to manage the team roles")
+	}
+	if rr.permissionRepo.CheckArchived(utils.TeamResource(teamID)) {
+		return 0, ErrArchived
+	}
+	if err := validatePermissions(permissions); err != nil {
+		return 0, err
+	}
+
+	tx := rr.DB.Begin()
+	if tx.Error != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", tx.Error)
+	}
+
+	var role models.CustomRole
+	if err := tx.Where("id = ? AND team_id = ?", roleID, teamID).First(&role).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("role %d not found", roleID)
+		}
+		return 0, fmt.Errorf("failed to retrieve role %d: %w", roleID, err)
+	}
+
+	if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to update permissions of role %d: %w", roleID, err)
+	}
+
+	if rolePermissions := toRolePermissions(role.ID, permissions); len(rolePermissions) > 0 {
+		if err := tx.Create(&rolePermissions).Error; err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to update permissions of role %d: %w", roleID, err)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return role.ID, nil
+}
+
+func (rr *roleRepository) DeleteRole(teamID uint, roleID uint, requestUserID uint) (uint, error) {
+	if !rr.permissionRepo.Authorize(requestUserID, models.RoleManage, utils.TeamResource(teamID)) {
+		return 0, errors.New("you don't have permission to manage the team roles")
+	}
+	if rr.permissionRepo.CheckArchived(utils.TeamResource(teamID)) {
+		return 0, ErrArchived
+	}
+
+	var role models.CustomRole
+	if err := rr.DB.Where("id = ? AND team_id = ?", roleID, teamID).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("role %d not found", roleID)
+		}
+		return 0, fmt.Errorf("failed to retrieve role %d: %w", roleID, err)
+	}
+
+	// a role can't be removed while someone still holds it
+	var assigned int64
+	if err := rr.DB.Model(&models.TeamMember{}).Where("team_id = ? AND role = ?", teamID, role.Name).Count(&assigned).Error; err != nil {
+		return 0, fmt.Errorf("failed to check role assignments: %w", err)
+	}
+	var assignedInProjects int64
+	if err := rr.DB.Model(&models.ProjectMember{}).
+		Joins("JOIN projects ON projects.id = users_projects.project_id").
+		Where("projects.team_id = ? AND users_projects.role = ?", teamID, role.Name).
+		Count(&assignedInProjects).Error; err != nil {
+		return 0, fmt.Errorf("failed to check role assignments: %w", err)
+	}
+	if assigned+assignedInProjects > 0 {
+		return 0, fmt.Errorf("role %s is still assigned to %d members", role.Name, assigned+assignedInProjects)
+	}
+
+	if err := rr.DB.Select("Permissions").Delete(&role).Error; err != nil {
+		return 0, fmt.Errorf("failed to delete role %d: %w", roleID, err)
+	}
+	return role.ID, nil
+}
+
+func (rr *roleRepository) AssignTeamRole(teamID uint, userID uint, role models.Role, requestUserID uint) error {
+	if !rr.permissionRepo.Authorize(requestUserID, models.MemberAssignRole, utils.TeamResource(teamID)) {
+		return errors.New("you don't have permission to assign roles in the team")
+	}
+	if rr.permissionRepo.CheckArchived(utils.TeamResource(teamID)) {
+		return ErrArchived
+	}
+	if err := rr.permissionRepo.CheckRoleGrant(requestUserID, utils.TeamResource(teamID), role); err != nil {
+		return err
+	}
+
+	var member models.TeamMember
+	if err := rr.DB.Where("user_id = ? AND team_id = ?", userID, teamID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("user %d is not a member of team %d", userID, teamID)
+		}
+		return fmt.Errorf("failed to retrieve membership of user %d: %w", userID, err)
+	}
+
+	// the team must keep at least one admin
+	if member.Role == models.Admin && role != models.Admin {
+		var admins int64
+		if err := rr.DB.Model(&models.TeamMember{}).Where("team_id = ? AND role = ?", teamID, models.Admin).Count(&admins).Error; err != nil {
+			return fmt.Errorf("failed to count team admins: %w", err)
+		}
+		if admins <= 1 {
+			return errors.New("the last admin of the team can't be demoted")
+		}
+	}
+
+	return rr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.TeamMember{}).Where("user_id = ? AND team_id = ?", userID, teamID).Update("role", role).Error; err != nil {
+			return fmt.Errorf("failed to assign role %s to user %d: %w", role, userID, err)
+		}
+		changes := models.ActivityChanges{"UserID": {Before: userID, After: userID}, "Role": {Before: member.Role, After: role}}
+		return recordActivity(tx, rr.request, teamActivity(models.ActivityRoleAssigned, teamID, changes, requestUserID))
+	})
+}
+
+func (rr *roleRepository) AssignProjectRole(projectID uint, userID uint, role models.Role, requestUserID uint) error {
+	if !rr.permissionRepo.Authorize(requestUserID, models.MemberAssignRole, utils.ProjectResource(projectID)) {
+		return errors.New("you don't have permission to assign roles in the project")
+	}
+	if rr.permissionRepo.CheckArchived(utils.ProjectResource(projectID)) {
+		return ErrArchived
+	}
+
+	var project models.Project
+	if err := rr.DB.First(&project, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("project with ID %d does not exist", projectID)
+		}
+		return fmt.Errorf("failed to fetch project: %w", err)
+	}
+	// an empty role falls back to the member's team role
+	if role != "" {
+		if err := rr.permissionRepo.CheckRoleGrant(requestUserID, utils.ProjectResource(projectID), role); err != nil {
+			return err
+		}
+	}
+
+	var member models.ProjectMember
+	if err := rr.DB.Where("project_id = ? AND user_id = ?", projectID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("user %d is not a member of project %d", userID, projectID)
+		}
+		return fmt.Errorf("failed to retrieve membership of user %d: %w", userID, err)
+	}
+
+	return rr.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ProjectMember{}).
+			Where("project_id = ? AND user_id = ?", projectID, userID).
+			Update("role", role)
+		if result.Error != nil {
+			return fmt.Errorf("failed to assign role %s to user %d: %w", role, userID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("user %d is not a member of project %d", userID, projectID)
+		}
+		changes := models.ActivityChanges{"UserID": {Before: userID, After: userID}, "Role": {Before: member.Role, After: role}}
+		return recordActivity(tx, rr.request, projectActivity(models.ActivityRoleAssigned, &project, changes, requestUserID))
+	})
+}
@@ -12,13 +12,18 @@ type SubtaskRepository interface {
 	GetSubtasksByTask(taskID uint, requestUserID uint) ([]models.Subtask, error)
 	CreateSubtask(subtask *models.Subtask, requestUserID uint) (uint, error)
 	GetSubtaskByID(subtaskID uint, requestUserID uint) (*models.Subtask, error)
-	UpdateSubtask(subtask *models.Subtask, requestUserID uint) (uint, error)
+	// UpdateSubtask sets the fields of patch on the subtask, it fails with ErrVersionConflict when
+	// expectedVersion isn't 0 and the subtask was updated since, it returns the new version
+	UpdateSubtask(subtaskID uint, patch *SubtaskPatch, expectedVersion uint, requestUserID uint) (uint, error)
 	DeleteSubtask(subtaskID uint, requestUserID uint) (uint, error)
+	// WithRequest returns a copy of the repository that records its activities for the request
+	WithRequest(request RequestInfo) SubtaskRepository
 }
 
 type subtaskRepository struct {
 	permissionRepo utils.PermissionRepository
 	DB             *gorm.DB
+	request        RequestInfo
 }
 
 func NewSubtaskRepository(postgreSql *database.DatabaseHandler) SubtaskRepository {
@@ -26,10 +31,33 @@ func NewSubtaskRepository(postgreSql *database.DatabaseHandler) SubtaskRepositor
 	return &subtaskRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
 }
 
+func (sr *subtaskRepository) WithRequest(request RequestInfo) SubtaskRepository {
+	scoped := *sr
+	scoped.request = request
+	return &scoped
+}
+
+// recordSubtaskActivity records a change of a subtask in the feed of its task's project
+func (sr *subtaskRepository) recordSubtaskActivity(tx *gorm.DB, action models.ActivityAction, subtaskID uint, taskID uint, changes models.ActivityChanges, requestUserID uint) error {
+	projectID, err := taskProjectID(tx, taskID)
+	if err != nil {
+		return err
+	}
+	activity := models.Activity{
+		ActorID:    requestUserID,
+		Action:     action,
+		TargetType: models.SubtaskTarget,
+		TargetID:   subtaskID,
+		ProjectID:  &projectID,
+		Changes:    changes,
+	}
+	return recordActivity(tx, sr.request, activity)
+}
+
 // GetSubtasksByTask fetches all subtasks for a given task if the user is an admin of the task.
 func (sr *subtaskRepository) GetSubtasksByTask(taskID uint, requestUserID uint) ([]models.Subtask, error) {
-	// Check if the user has admin permission for the task
-	if !sr.permissionRepo.CheckUserHasAccessToTask(taskID, requestUserID) {
+	// Check if the user may view the task
+	if !sr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(taskID)) {
 		return nil, errors.New("you don't have access to the project")
 	}
 
@@ -42,12 +70,29 @@ func (sr *subtaskRepository) GetSubtasksByTask(taskID uint, requestUserID uint)
 
 // CreateSubtask creates a new subtask under a task if the user is an admin of the task.
 func (sr *subtaskRepository) CreateSubtask(subtask *models.Subtask, requestUserID uint) (uint, error) {
-	// Check if the user has admin permission for the task
-	if !sr.permissionRepo.CheckUserIsAdminOfTask(subtask.TaskID, requestUserID) {
+	// Check if the user may create subtasks for the task
+	if !sr.permissionRepo.Authorize(requestUserID, models.SubtaskCreate, utils.TaskResource(subtask.TaskID)) {
 		return 0, errors.New("you don't have access to the project")
 	}
+	if sr.permissionRepo.CheckArchived(utils.TaskResource(subtask.TaskID)) {
+		return 0, ErrArchived
+	}
+
+	if subtask.Weight <= 0 {
+		subtask.Weight = 1
+	}
 
-	if err := sr.DB.Create(subtask).Error; err != nil {
+	// Create the subtask and refresh the progress of its task together
+	err := sr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(subtask).Error; err != nil {
+			return err
+		}
+		if err := sr.recordSubtaskActivity(tx, models.ActivityCreated, subtask.ID, subtask.TaskID, activityChanges(nil, subtask), requestUserID); err != nil {
+			return err
+		}
+		return recomputeTaskProgress(tx, subtask.TaskID)
+	})
+	if err != nil {
 		return 0, err
 	}
 	return subtask.ID, nil
@@ -64,7 +109,7 @@ func (sr *subtaskRepository) GetSubtaskByID(subtaskID uint, requestUserID uint)
 		return nil, err
 	}
 
-	if !sr.permissionRepo.CheckUserHasAccessToTask(subtask.TaskID, requestUserID) {
+	if !sr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(subtask.TaskID)) {
 		return nil, errors.New("you don't have access to the project")
 	}
 
@@ -72,16 +117,43 @@ func (sr *subtaskRepository) GetSubtaskByID(subtaskID uint, requestUserID uint)
 }
 
 // UpdateSubtask updates an existing subtask if the user is an admin of the associated task.
-func (sr *subtaskRepository) UpdateSubtask(subtask *models.Subtask, requestUserID uint) (uint, error) {
-	// Check if the user has admin permission for the task
-	if !sr.permissionRepo.CheckUserHasAccessToTask(subtask.TaskID, requestUserID) {
+func (sr *subtaskRepository) UpdateSubtask(subtaskID uint, patch *SubtaskPatch, expectedVersion uint, requestUserID uint) (uint, error) {
+	// Check if the user may update the stored subtask
+	if !sr.permissionRepo.Authorize(requestUserID, models.SubtaskUpdate, utils.SubtaskResource(subtaskID)) {
 		return 0, errors.New("you don't have access to the project")
 	}
+	if sr.permissionRepo.CheckArchived(utils.SubtaskResource(subtaskID)) {
+		return 0, ErrArchived
+	}
 
-	if err := sr.DB.Save(subtask).Error; err != nil {
+	var existingSubtask models.Subtask
+	if err := sr.DB.First(&existingSubtask, subtaskID).Error; err != nil {
 		return 0, err
 	}
-	return subtask.ID, nil
+	changes := patch.changes()
+	if patch.Weight != nil && *patch.Weight <= 0 {
+		delete(changes, "weight")
+	}
+
+	var version uint
+	err := sr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := applyPatch(tx, &models.Subtask{ID: subtaskID, TaskID: existingSubtask.TaskID}, subtaskID, existingSubtask.Version, expectedVersion, changes); err != nil {
+			return err
+		}
+		var after models.Subtask
+		if err := tx.First(&after, subtaskID).Error; err != nil {
+			return err
+		}
+		version = after.Version
+		if err := sr.recordSubtaskActivity(tx, models.ActivityUpdated, subtaskID, after.TaskID, activityChanges(&existingSubtask, &after), requestUserID); err != nil {
+			return err
+		}
+		return recomputeTaskProgress(tx, after.TaskID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
 }
 
 // DeleteSubtask deletes a subtask if the user is an admin of the associated task.
@@ -95,12 +167,24 @@ func (sr *subtaskRepository) DeleteSubtask(subtaskID uint, requestUserID uint) (
 		return 0, err
 	}
 
-	// Check if the user has admin permission for the task
-	if !sr.permissionRepo.CheckUserIsAdminOfProject(subtask.TaskID, requestUserID) {
+	// Check if the user may delete subtasks of the task
+	if !sr.permissionRepo.Authorize(requestUserID, models.SubtaskDelete, utils.SubtaskResource(subtask.ID)) {
 		return 0, errors.New("you don't have access to the project")
 	}
+	if sr.permissionRepo.CheckArchived(utils.SubtaskResource(subtask.ID)) {
+		return 0, ErrArchived
+	}
 
-	if err := sr.DB.Delete(subtask).Error; err != nil {
+	err := sr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&subtask).Error; err != nil {
+			return err
+		}
+		if err := sr.recordSubtaskActivity(tx, models.ActivityDeleted, subtask.ID, subtask.TaskID, activityChanges(&subtask, nil), requestUserID); err != nil {
+			return err
+		}
+		return recomputeTaskProgress(tx, subtask.TaskID)
+	})
+	if err != nil {
 		return 0, err
 	}
 	return subtask.ID, nil
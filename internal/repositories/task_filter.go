@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// FieldOperator compares a custom field value with a filter value
+type FieldOperator string
+
+const (
+	// FieldEquals matches the value, text fields match when they contain it
+	FieldEquals FieldOperator = "eq"
+	// FieldAtLeast and FieldAtMost filter number and date fields by range
+	FieldAtLeast FieldOperator = "gte"
+	FieldAtMost  FieldOperator = "lte"
+)
+
+type FieldFilter struct {
+	FieldID  uint
+	Operator FieldOperator
+	Value    string
+}
+
+// TaskFilter narrows task listings. Tasks must match every condition that is set, must carry
+// every label and may be in any of the statuses and assigned to any of the assignees.
+type TaskFilter struct {
+	// ProjectIDs narrows listings that span projects
+	ProjectIDs  []uint
+	StatusIDs   []uint
+	AssigneeIDs []uint
+	PriorityMin *int
+	PriorityMax *int
+	DueBefore   *time.Time
+	DueAfter    *time.Time
+	// Text is searched in the title and description
+	Text     string
+	LabelIDs []uint
+	Fields   []FieldFilter
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// applyTaskFilter adds the filter conditions to a query on the tasks of a project, listings
+// spanning projects pass 0 and can only filter by custom fields once narrowed to one project
+func applyTaskFilter(db *gorm.DB, query *gorm.DB, projectID uint, filter TaskFilter) (*gorm.DB, error) {
+	if len(filter.ProjectIDs) > 0 {
+		query = query.Where("tasks.project_id IN ?", filter.ProjectIDs)
+		if projectID == 0 && len(filter.ProjectIDs) == 1 {
+			projectID = filter.ProjectIDs[0]
+		}
+	}
+	if len(filter.StatusIDs) > 0 {
+		query = query.Where("tasks.status_id IN ?", filter.StatusIDs)
+	}
+	if len(filter.AssigneeIDs) > 0 {
+		query = query.Where("tasks.id IN (SELECT task_id FROM task_members WHERE user_id IN ?)", filter.AssigneeIDs)
+	}
+	if filter.PriorityMin != nil {
+		query = query.Where("tasks.task_priority >= ?", *filter.PriorityMin)
+	}
+	if filter.PriorityMax != nil {
+		query = query.Where("tasks.task_priority <= ?", *filter.PriorityMax)
+	}
+	if filter.DueBefore != nil {
+		query = query.Where("tasks.due_date < ?", *filter.DueBefore)
+	}
+	if filter.DueAfter != nil {
+		query = query.Where("tasks.due_date >= ?", *filter.DueAfter)
+	}
+	if text := strings.TrimSpace(filter.Text); text != "" {
+		pattern := "%" + escapeLike(text) + "%"
+		query = query.Where("(tasks.title ILIKE ? OR tasks.description ILIKE ?)", pattern, pattern)
+	}
+	for _, labelID := range filter.LabelIDs {
+		query = query.Where("tasks.id IN (SELECT task_id FROM task_labels WHERE label_id = ?)", labelID)
+	}
+	if len(filter.Fields) == 0 {
+		return query, nil
+	}
+	if projectID == 0 {
+		return nil, errors.New("custom field filters need a single project")
+	}
+
+	fields, err := projectFields(db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	fieldByID := make(map[uint]*models.CustomField)
+	for i := range fields {
+		fieldByID[fields[i].ID] = &fields[i]
+	}
+
+	for _, fieldFilter := range filter.Fields {
+		field, ok := fieldByID[fieldFilter.FieldID]
+		if !ok {
+			return nil, fmt.Errorf("field %d doesn't belong to project %d", fieldFilter.FieldID, projectID)
+		}
+
+		const subquery = "tasks.id IN (SELECT task_id FROM task_field_values WHERE field_id = ? AND %s)"
+		if fieldFilter.Operator == FieldEquals && field.Type == models.FieldText {
+			query = query.Where(fmt.Sprintf(subquery, "value ILIKE ?"), field.ID, "%"+escapeLike(fieldFilter.Value)+"%")
+			continue
+		}
+
+		value, err := parseFieldValue(field, fieldFilter.Value)
+		if err != nil {
+			return nil, err
+		}
+		switch fieldFilter.Operator {
+		case FieldEquals:
+			query = query.Where(fmt.Sprintf(subquery, "value = ?"), field.ID, value.Value)
+		case FieldAtLeast, FieldAtMost:
+			comparison := ">="
+			if fieldFilter.Operator == FieldAtMost {
+				comparison = "<="
+			}
+			switch field.Type {
+			case models.FieldNumber:
+				query = query.Where(fmt.Sprintf(subquery, "number_value "+comparison+" ?"), field.ID, *value.NumberValue)
+			case models.FieldDate:
+				query = query.Where(fmt.Sprintf(subquery, "date_value "+comparison+" ?"), field.ID, *value.DateValue)
+			default:
+				return nil, fmt.Errorf("%s can't be filtered by range", field.Name)
+			}
+		default:
+			return nil, errors.New("invalid field filter operator")
+		}
+	}
+	return query, nil
+}
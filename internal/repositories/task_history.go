@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+func recordTaskEvents(tx *gorm.DB, events []models.TaskEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range events {
+		if events[i].OccurredAt.IsZero() {
+			events[i].OccurredAt = now
+		}
+	}
+	if err := tx.Create(&events).Error; err != nil {
+		return fmt.Errorf("failed to record task history: %w", err)
+	}
+	return nil
+}
+
+func statusIsDone(tx *gorm.DB, statusID *uint) (bool, error) {
+	if statusID == nil {
+		return false, nil
+	}
+	var status models.WorkflowStatus
+	if err := tx.Select("id", "is_done").First(&status, *statusID).Error; err != nil {
+		return false, fmt.Errorf("failed to get status %d: %w", *statusID, err)
+	}
+	return status.IsDone, nil
+}
+
+// recordTaskCreated records the creation of a task and the assignment of its members
+func recordTaskCreated(tx *gorm.DB, task *models.Task, actorID uint) error {
+	done, err := statusIsDone(tx, task.StatusID)
+	if err != nil {
+		return err
+	}
+
+	events := []models.TaskEvent{{
+		TaskID:    task.ID,
+		ProjectID: task.ProjectID,
+		Type:      models.TaskCreated,
+		StatusID:  task.StatusID,
+		Done:      done,
+		ActorID:   actorID,
+	}}
+	for _, member := range task.Members {
+		userID := member.ID
+		events = append(events, models.TaskEvent{
+			TaskID:    task.ID,
+			ProjectID: task.ProjectID,
+			Type:      models.TaskAssigned,
+			UserID:    &userID,
+			ActorID:   actorID,
+		})
+	}
+	return recordTaskEvents(tx, events)
+}
@@ -0,0 +1,224 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultTaskPageSize = 100
+	MaxTaskPageSize     = 500
+)
+
+// TaskListField is a field that can be picked with sparse field selection, Key is the field's
+// name in the task JSON. Scalar fields map to a column and relations to a preload.
+type TaskListField struct {
+	Key      string
+	Column   string
+	Relation string
+}
+
+// TaskListFields are the fields a task listing can be narrowed to, by their query name
+var TaskListFields = map[string]TaskListField{
+	"id":               {Key: "ID", Column: "id"},
+	"project_id":       {Key: "ProjectID", Column: "project_id"},
+	"title":            {Key: "Title", Column: "title"},
+	"description":      {Key: "Description", Column: "description"},
+	"priority":         {Key: "TaskPriority", Column: "task_priority"},
+	"due_date":         {Key: "DueDate", Column: "due_date"},
+	"progress":         {Key: "ProgressPercentage", Column: "progress_percentage"},
+	"estimate_minutes": {Key: "EstimateMinutes", Column: "estimate_minutes"},
+	"status_id":        {Key: "StatusID", Column: "status_id"},
+	"rank":             {Key: "Rank", Column: "rank"},
+	"recurrence_rule":  {Key: "RecurrenceRule", Column: "recurrence_rule"},
+	"subtasks":         {Key: "Subtasks", Relation: "Subtasks"},
+	"members":          {Key: "Members", Relation: "Members"},
+	"reports":          {Key: "Reports", Relation: "Reports"},
+	"labels":           {Key: "Labels", Relation: "Labels"},
+	"field_values":     {Key: "FieldValues", Relation: "FieldValues"},
+}
+
+// taskSortColumns are the columns a task listing can be sorted by
+var taskSortColumns = map[string]string{
+	"id":       "id",
+	"title":    "title",
+	"priority": "task_priority",
+	"due_date": "due_date",
+	"progress": "progress_percentage",
+	"rank":     "rank",
+}
+
+// TaskQuery is a filtered, sorted page of a task listing. Sort is a sortable field, descending
+// when prefixed with '-', Cursor is the NextCursor of the previous page and Fields picks the
+// fields of TaskListFields to load, the scalar ones when empty. Include names relations loaded
+// on top of Fields, relations are only loaded when one of them asks for it.
+type TaskQuery struct {
+	Filter  TaskFilter
+	Sort    string
+	Cursor  string
+	Limit   int
+	Fields  []string
+	Include []string
+}
+
+// SelectedFields returns the fields a page of the query is sent with, empty for every scalar
+// field and the included relations
+func (q *TaskQuery) SelectedFields() []string {
+	if len(q.Fields) == 0 {
+		return nil
+	}
+	return append(append([]string{}, q.Fields...), q.Include...)
+}
+
+type TaskPage struct {
+	Tasks []models.Task
+	// NextCursor is empty on the last page
+	NextCursor string
+}
+
+// taskCursor is the position after the last task of a page, Value is the sort column of that task
+type taskCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+func parseTaskSort(sort string) (string, string, bool, error) {
+	if sort == "" {
+		sort = "id"
+	}
+	name := strings.TrimPrefix(sort, "-")
+	column, ok := taskSortColumns[name]
+	if !ok {
+		return "", "", false, fmt.Errorf("tasks can't be sorted by %s", name)
+	}
+	return sort, column, strings.HasPrefix(sort, "-"), nil
+}
+
+// cursorValue decodes the sort value of a cursor into the type of its column
+func cursorValue(column string, raw json.RawMessage) (interface{}, error) {
+	switch column {
+	case "id", "task_priority", "progress_percentage":
+		var value int64
+		err := json.Unmarshal(raw, &value)
+		return value, err
+	case "due_date":
+		var value time.Time
+		err := json.Unmarshal(raw, &value)
+		return value, err
+	default:
+		var value string
+		err := json.Unmarshal(raw, &value)
+		return value, err
+	}
+}
+
+// applyTaskQuery adds the sort order, cursor position, limit and field selection of a query,
+// one task more than the limit is loaded to tell whether a next page exists
+func applyTaskQuery(query *gorm.DB, taskQuery *TaskQuery) (*gorm.DB, string, error) {
+	sort, column, descending, err := parseTaskSort(taskQuery.Sort)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if taskQuery.Limit <= 0 {
+		taskQuery.Limit = DefaultTaskPageSize
+	}
+	if taskQuery.Limit > MaxTaskPageSize {
+		taskQuery.Limit = MaxTaskPageSize
+	}
+
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if taskQuery.Cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(taskQuery.Cursor)
+		if err != nil {
+			return nil, "", errors.New("invalid cursor")
+		}
+		var cursor taskCursor
+		if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort {
+			return nil, "", errors.New("invalid cursor, cursors only work with the sort they were made for")
+		}
+		value, err := cursorValue(column, cursor.Value)
+		if err != nil {
+			return nil, "", errors.New("invalid cursor")
+		}
+		query = query.Where(
+			fmt.Sprintf("(tasks.%s %s ? OR (tasks.%s = ? AND tasks.id %s ?))", column, comparison, column, comparison),
+			value, value, cursor.ID,
+		)
+	}
+	query = query.Order(fmt.Sprintf("tasks.%s %s, tasks.id %s", column, direction, direction)).Limit(taskQuery.Limit + 1)
+
+	preloaded := map[string]bool{}
+	for _, name := range append(append([]string{}, taskQuery.Fields...), taskQuery.Include...) {
+		field, ok := TaskListFields[name]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown task field %s", name)
+		}
+		if field.Relation != "" && !preloaded[field.Relation] {
+			preloaded[field.Relation] = true
+			query = query.Preload(field.Relation)
+		}
+	}
+	if len(taskQuery.Fields) == 0 {
+		return query, column, nil
+	}
+
+	// the id and sort column are always loaded for preloads and cursors
+	columns := []string{"tasks.id", "tasks." + column}
+	for _, name := range taskQuery.Fields {
+		field := TaskListFields[name]
+		if field.Column != "" && field.Column != column && field.Column != "id" {
+			columns = append(columns, "tasks."+field.Column)
+		}
+	}
+	return query.Select(columns), column, nil
+}
+
+func taskSortValue(task *models.Task, column string) interface{} {
+	switch column {
+	case "title":
+		return task.Title
+	case "task_priority":
+		return task.TaskPriority
+	case "due_date":
+		return task.DueDate
+	case "progress_percentage":
+		return task.ProgressPercentage
+	case "rank":
+		return task.Rank
+	}
+	return task.ID
+}
+
+// taskPage trims the extra task loaded by applyTaskQuery and sets the cursor after the page
+func taskPage(tasks []models.Task, taskQuery *TaskQuery, column string) (*TaskPage, error) {
+	if len(tasks) <= taskQuery.Limit {
+		return &TaskPage{Tasks: tasks}, nil
+	}
+	tasks = tasks[:taskQuery.Limit]
+
+	last := &tasks[len(tasks)-1]
+	rawValue, err := json.Marshal(taskSortValue(last, column))
+	if err != nil {
+		return nil, err
+	}
+	sort, _, _, _ := parseTaskSort(taskQuery.Sort)
+	data, err := json.Marshal(taskCursor{Sort: sort, Value: rawValue, ID: last.ID})
+	if err != nil {
+		return nil, err
+	}
+	return &TaskPage{Tasks: tasks, NextCursor: base64.RawURLEncoding.EncodeToString(data)}, nil
+}
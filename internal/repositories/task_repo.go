@@ -10,17 +10,28 @@ import (
 )
 
 type TaskRepository interface {
-	GetTasksByProject(projectID uint, requestUserID uint) ([]models.Task, error)
+	// GetTasksByProject returns a page of the filtered and sorted tasks of a project
+	GetTasksByProject(projectID uint, taskQuery TaskQuery, requestUserID uint) (*TaskPage, error)
+	// GetMyTasks returns a page of the tasks assigned to the user in every project they belong to,
+	// except the archived ones
+	GetMyTasks(taskQuery TaskQuery, requestUserID uint) (*TaskPage, error)
 	CreateTask(task *models.Task, requestUserID uint) (uint, error)
 	GetTaskByID(taskID uint, requestUserID uint) (*models.Task, error)
-	UpdateTask(task *models.Task, requestUserID uint) (uint, error)
+	// UpdateTask sets the fields of patch on the task, it fails with ErrVersionConflict when
+	// expectedVersion isn't 0 and the task was updated since, it returns the new version
+	UpdateTask(taskID uint, patch *TaskPatch, expectedVersion uint, requestUserID uint) (uint, error)
 	DeleteTask(taskID uint, requestUserID uint) (uint, error)
 	AssignTask(UserID uint, TaskTitle uint, requestUserID uint) error
+	// RestoreTask brings a task back from the trash, its project must not be in the trash
+	RestoreTask(taskID uint, requestUserID uint) (uint, error)
+	// WithRequest returns a copy of the repository that records its activities for the request
+	WithRequest(request RequestInfo) TaskRepository
 }
 
 type taskRepository struct {
 	permissionRepo utils.PermissionRepository
 	DB             *gorm.DB
+	request        RequestInfo
 }
 
 func NewTaskRepository(postgreSql *database.DatabaseHandler) TaskRepository {
@@ -28,32 +39,86 @@ func NewTaskRepository(postgreSql *database.DatabaseHandler) TaskRepository {
 	return &taskRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
 }
 
-func (tr *taskRepository) GetTasksByProject(projectID uint, requestUserID uint) ([]models.Task, error) {
+func (tr *taskRepository) WithRequest(request RequestInfo) TaskRepository {
+	scoped := *tr
+	scoped.request = request
+	return &scoped
+}
+
+func (tr *taskRepository) GetTasksByProject(projectID uint, taskQuery TaskQuery, requestUserID uint) (*TaskPage, error) {
 	// Check if the user has permission to view tasks for the project
-	if !tr.permissionRepo.CheckUserHasAccessToProject(projectID, requestUserID) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.ProjectResource(projectID)) {
 		return nil, errors.New("you don't have access to the project")
 	}
 
+	query, err := applyTaskFilter(tr.DB, tr.DB.Model(&models.Task{}).Where("tasks.project_id = ?", projectID), projectID, taskQuery.Filter)
+	if err != nil {
+		return nil, err
+	}
+	query, column, err := applyTaskQuery(query, &taskQuery)
+	if err != nil {
+		return nil, err
+	}
+
 	// Fetch tasks from the database
 	var tasks []models.Task
-	err := tr.DB.Preload("Subtasks").
-		Preload("Members").
-		Preload("Reports").
-		Where("project_id = ?", projectID).
-		Find(&tasks).Error
+	if err := query.Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	return taskPage(tasks, &taskQuery, column)
+}
+
+func (tr *taskRepository) GetMyTasks(taskQuery TaskQuery, requestUserID uint) (*TaskPage, error) {
+	// the projects of the user, archived teams and projects are left out
+	userProjects := tr.DB.Table("users_projects").
+		Select("users_projects.project_id").
+		Joins("JOIN projects ON projects.id = users_projects.project_id AND projects.archived_at IS NULL").
+		Joins("JOIN teams ON teams.id = projects.team_id AND teams.archived_at IS NULL").
+		Where("users_projects.user_id = ?", requestUserID)
+	query := tr.DB.Model(&models.Task{}).
+		Where("tasks.id IN (SELECT task_id FROM task_members WHERE user_id = ?)", requestUserID).
+		Where("tasks.project_id IN (?)", userProjects)
+	query, err := applyTaskFilter(tr.DB, query, 0, taskQuery.Filter)
+	if err != nil {
+		return nil, err
+	}
+	query, column, err := applyTaskQuery(query, &taskQuery)
 	if err != nil {
 		return nil, err
 	}
 
-	return tasks, nil
+	var tasks []models.Task
+	if err := query.Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to get tasks of user %d: %w", requestUserID, err)
+	}
+
+	return taskPage(tasks, &taskQuery, column)
 }
 
 func (tr *taskRepository) CreateTask(task *models.Task, requestUserID uint) (uint, error) {
-	// Check if the user is an admin of the project associated with the task
-	isAdmin := tr.permissionRepo.CheckUserIsAdminOfProject(task.ProjectID, requestUserID)
-	if !isAdmin {
+	// Check if the user may create tasks in the project associated with the task
+	if !tr.permissionRepo.Authorize(requestUserID, models.TaskCreate, utils.ProjectResource(task.ProjectID)) {
 		return 0, errors.New("user does not have permission to create tasks for this project")
 	}
+	if tr.permissionRepo.CheckArchived(utils.ProjectResource(task.ProjectID)) {
+		return 0, ErrArchived
+	}
+
+	if err := validateRecurrence(task); err != nil {
+		return 0, err
+	}
+	task.RecurrenceParentID = nil
+	task.RecurrenceIndex = 1
+	task.RecurrenceSpawned = false
+
+	// Members, subtasks, reports, labels and field values are set through their own endpoints
+	// once the task exists, so their permission checks and history apply
+	task.Members = nil
+	task.Subtasks = nil
+	task.Reports = nil
+	task.Labels = nil
+	task.FieldValues = nil
 
 	// Start a transaction to ensure atomicity
 	tx := tr.DB.Begin()
@@ -64,23 +129,43 @@ func (tr *taskRepository) CreateTask(task *models.Task, requestUserID uint) (uin
 		}
 	}()
 
+	// New tasks go to the end of the first column unless a status is given
+	statuses, err := projectWorkflow(tx, task.ProjectID)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if task.StatusID == nil {
+		task.StatusID = &statuses[0].ID
+	} else if !containsStatus(statuses, *task.StatusID) {
+		tx.Rollback()
+		return 0, fmt.Errorf("status %d doesn't belong to project %d", *task.StatusID, task.ProjectID)
+	}
+	lastRank, err := lastRankInStatus(tx, *task.StatusID, 0)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	task.Rank = utils.RankBetween(lastRank, "")
+
 	// Add the task to the database
 	if err := tx.Create(task).Error; err != nil {
 		tx.Rollback()
 		return 0, err
 	}
-
-	// Ensure the task is added to the project's tasks
-	project := &models.Project{}
-	if err := tx.Where("id = ?", task.ProjectID).First(project).Error; err != nil {
+	if err := recordTaskCreated(tx, task, requestUserID); err != nil {
 		tx.Rollback()
 		return 0, err
 	}
-
-	project.ProjectTasks = append(project.ProjectTasks, *task)
-
-	// Save the updated project
-	if err := tx.Save(project).Error; err != nil {
+	created := models.Activity{
+		ActorID:    requestUserID,
+		Action:     models.ActivityCreated,
+		TargetType: models.TaskTarget,
+		TargetID:   task.ID,
+		ProjectID:  &task.ProjectID,
+		Changes:    activityChanges(nil, task),
+	}
+	if err := recordActivity(tx, tr.request, created); err != nil {
 		tx.Rollback()
 		return 0, err
 	}
@@ -93,14 +178,28 @@ func (tr *taskRepository) CreateTask(task *models.Task, requestUserID uint) (uin
 	return task.ID, nil
 }
 
+// validateRecurrence checks the recurrence rule of a task, recurring tasks need a due date to schedule from
+func validateRecurrence(task *models.Task) error {
+	if task.RecurrenceRule == "" {
+		return nil
+	}
+	if _, err := utils.ParseRRule(task.RecurrenceRule); err != nil {
+		return fmt.Errorf("invalid recurrence rule: %w", err)
+	}
+	if task.DueDate.IsZero() {
+		return errors.New("recurring tasks require a due date")
+	}
+	return nil
+}
+
 func (tr *taskRepository) GetTaskByID(taskID uint, requestUserID uint) (*models.Task, error) {
 	// Check if the user has permission to view tasks for the project
-	if !tr.permissionRepo.CheckUserHasAccessToTask(taskID, requestUserID) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(taskID)) {
 		return nil, errors.New("you don't have access to the project")
 	}
 
 	var task models.Task
-	err := tr.DB.First(&task, taskID).Error
+	err := tr.DB.Preload("Labels").Preload("FieldValues").First(&task, taskID).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("task not found")
@@ -111,16 +210,34 @@ func (tr *taskRepository) GetTaskByID(taskID uint, requestUserID uint) (*models.
 	return &task, nil
 }
 
-func (tr *taskRepository) UpdateTask(task *models.Task, requestUserID uint) (uint, error) {
+func (tr *taskRepository) UpdateTask(taskID uint, patch *TaskPatch, expectedVersion uint, requestUserID uint) (uint, error) {
 	// Check if the user has permission to update the task
-	isAdmin := tr.permissionRepo.CheckUserIsAdminOfTask(task.ID, requestUserID)
-	if !isAdmin {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TaskUpdate, utils.TaskResource(taskID)) {
 		return 0, errors.New("user does not have permission to change this task")
 	}
+	if tr.permissionRepo.CheckArchived(utils.TaskResource(taskID)) {
+		return 0, ErrArchived
+	}
+
+	var version uint
+	err := tr.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		version, err = updateTask(tx, tr.request, taskID, patch, expectedVersion, requestUserID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
 
+// updateTask applies the set fields of patch to the stored task, records what changed and returns
+// the task's new version. expectedVersion is checked against the task's version unless it's 0
+func updateTask(tx *gorm.DB, request RequestInfo, taskID uint, patch *TaskPatch, expectedVersion uint, requestUserID uint) (uint, error) {
 	// Verify that the task exists in the database
 	var existingTask models.Task
-	err := tr.DB.First(&existingTask, task.ID).Error
+	err := tx.First(&existingTask, taskID).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return 0, errors.New("task not found")
@@ -128,43 +245,118 @@ func (tr *taskRepository) UpdateTask(task *models.Task, requestUserID uint) (uin
 		return 0, err
 	}
 
-	// Update the task in the database
-	err = tr.DB.Model(&existingTask).Updates(task).Error
+	// A changed rule or due date has to leave a valid series
+	if patch.RecurrenceRule != nil || patch.DueDate != nil {
+		series := models.Task{RecurrenceRule: existingTask.RecurrenceRule, DueDate: existingTask.DueDate}
+		if patch.RecurrenceRule != nil {
+			series.RecurrenceRule = *patch.RecurrenceRule
+		}
+		if patch.DueDate != nil {
+			series.DueDate = *patch.DueDate
+		}
+		if err := validateRecurrence(&series); err != nil {
+			return 0, err
+		}
+	}
+
+	// Derived progress can't be overwritten by hand
+	changes := patch.changes()
+	mode, err := projectProgressMode(tx, existingTask.ProjectID)
 	if err != nil {
 		return 0, err
 	}
+	if mode != models.ProgressManual {
+		delete(changes, "progress_percentage")
+	}
 
-	return task.ID, nil
+	// Update the task in the database and record what changed
+	if err := applyPatch(tx, &models.Task{ID: taskID, ProjectID: existingTask.ProjectID}, taskID, existingTask.Version, expectedVersion, changes); err != nil {
+		return 0, err
+	}
+	var after models.Task
+	if err := tx.First(&after, taskID).Error; err != nil {
+		return 0, err
+	}
+	updated := models.Activity{
+		ActorID:    requestUserID,
+		Action:     models.ActivityUpdated,
+		TargetType: models.TaskTarget,
+		TargetID:   after.ID,
+		ProjectID:  &after.ProjectID,
+		Changes:    activityChanges(&existingTask, &after),
+	}
+	if err := recordActivity(tx, request, updated); err != nil {
+		return 0, err
+	}
+	return after.Version, nil
 }
 
 func (tr *taskRepository) DeleteTask(taskID uint, requestUserID uint) (uint, error) {
 	// Check if the user has permission to delete the task
-	isAdmin := tr.permissionRepo.CheckUserIsAdminOfTask(taskID, requestUserID)
-	if !isAdmin {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TaskDelete, utils.TaskResource(taskID)) {
 		return 0, errors.New("user does not have permission to delete this task")
 	}
+	if tr.permissionRepo.CheckArchived(utils.TaskResource(taskID)) {
+		return 0, ErrArchived
+	}
 
+	err := tr.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteTask(tx, tr.request, taskID, requestUserID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return taskID, nil
+}
+
+// deleteTask moves a task to the trash, its dependencies, time entries, reports, labels and
+// field values are kept for a restore until the trash is purged
+func deleteTask(tx *gorm.DB, request RequestInfo, taskID uint, requestUserID uint) error {
 	// Fetch the task
 	var task models.Task
-	err := tr.DB.First(&task, taskID).Error
+	err := tx.First(&task, taskID).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return 0, errors.New("task not found")
+			return errors.New("task not found")
 		}
-		return 0, err
+		return err
+	}
+
+	deleted := models.TaskEvent{TaskID: task.ID, ProjectID: task.ProjectID, Type: models.TaskDeleted, ActorID: requestUserID}
+	if err := recordTaskEvents(tx, []models.TaskEvent{deleted}); err != nil {
+		return err
+	}
+	activity := models.Activity{
+		ActorID:    requestUserID,
+		Action:     models.ActivityDeleted,
+		TargetType: models.TaskTarget,
+		TargetID:   task.ID,
+		ProjectID:  &task.ProjectID,
+		Changes:    activityChanges(&task, nil),
 	}
-	// Delete the task from the database
-	err = tr.DB.Delete(&task).Error
-	return task.ID, err
+	if err := recordActivity(tx, request, activity); err != nil {
+		return err
+	}
+	return tx.Delete(&task).Error
 }
 
 func (tr *taskRepository) AssignTask(userID uint, taskID uint, requestUserID uint) error {
-	if !tr.permissionRepo.CheckUserIsAdminOfTask(taskID, requestUserID) {
-		return errors.New("only admins can update the project")
+	if !tr.permissionRepo.Authorize(requestUserID, models.TaskAssign, utils.TaskResource(taskID)) {
+		return errors.New("user does not have permission to assign this task")
+	}
+	if tr.permissionRepo.CheckArchived(utils.TaskResource(taskID)) {
+		return ErrArchived
 	}
 
+	return tr.DB.Transaction(func(tx *gorm.DB) error {
+		return assignTask(tx, tr.request, userID, taskID, requestUserID)
+	})
+}
+
+// assignTask adds a member of the task's project to the task's members
+func assignTask(tx *gorm.DB, request RequestInfo, userID uint, taskID uint, requestUserID uint) error {
 	var task models.Task
-	if err := tr.DB.First(&task, taskID).Error; err != nil {
+	if err := tx.Preload("Members").First(&task, taskID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return fmt.Errorf("task with ID %d does not exist", taskID)
 		}
@@ -172,7 +364,7 @@ func (tr *taskRepository) AssignTask(userID uint, taskID uint, requestUserID uin
 	}
 
 	var project models.Project
-	if err := tr.DB.Preload("ProjectMembers").First(&project, task.ProjectID).Error; err != nil {
+	if err := tx.Preload("ProjectMembers").First(&project, task.ProjectID).Error; err != nil {
 		return fmt.Errorf("failed to fetch project: %w", err)
 	}
 
@@ -190,10 +382,83 @@ func (tr *taskRepository) AssignTask(userID uint, taskID uint, requestUserID uin
 		return fmt.Errorf("user with ID %d is not a member of the project associated with task %d", userID, taskID)
 	}
 
+	for _, member := range task.Members {
+		if member.ID == userID {
+			return fmt.Errorf("user with ID %d is already assigned to task %d", userID, taskID)
+		}
+	}
+
 	task.Members = append(task.Members, user)
-	if err := tr.DB.Save(&task).Error; err != nil {
+	if err := tx.Save(&task).Error; err != nil {
+		return fmt.Errorf("failed to assign task to user: %w", err)
+	}
+	assigned := models.TaskEvent{TaskID: task.ID, ProjectID: task.ProjectID, Type: models.TaskAssigned, UserID: &userID, ActorID: requestUserID}
+	if err := recordTaskEvents(tx, []models.TaskEvent{assigned}); err != nil {
+		return fmt.Errorf("failed to assign task to user: %w", err)
+	}
+	activity := models.Activity{
+		ActorID:    requestUserID,
+		Action:     models.ActivityAssigned,
+		TargetType: models.TaskTarget,
+		TargetID:   task.ID,
+		ProjectID:  &task.ProjectID,
+		Changes:    models.ActivityChanges{"UserID": {After: userID}},
+	}
+	if err := recordActivity(tx, request, activity); err != nil {
 		return fmt.Errorf("failed to assign task to user: %w", err)
 	}
-
 	return nil
 }
+
+func (tr *taskRepository) RestoreTask(taskID uint, requestUserID uint) (uint, error) {
+	var task models.Task
+	if err := tr.DB.Unscoped().First(&task, taskID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errors.New("task not found")
+		}
+		return 0, err
+	}
+	if !task.DeletedAt.Valid {
+		return 0, fmt.Errorf("task %d is not in the trash", taskID)
+	}
+
+	var project models.Project
+	if err := tr.DB.Select("id").First(&project, task.ProjectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("project %d of the task is in the trash, restore it first", task.ProjectID)
+		}
+		return 0, err
+	}
+	if !tr.permissionRepo.Authorize(requestUserID, models.TaskDelete, utils.ProjectResource(task.ProjectID)) {
+		return 0, errors.New("user does not have permission to restore this task")
+	}
+	if tr.permissionRepo.CheckArchived(utils.ProjectResource(task.ProjectID)) {
+		return 0, ErrArchived
+	}
+
+	err := tr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&task).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		done, err := statusIsDone(tx, task.StatusID)
+		if err != nil {
+			return err
+		}
+		restored := models.TaskEvent{TaskID: task.ID, ProjectID: task.ProjectID, Type: models.TaskRestored, StatusID: task.StatusID, Done: done, ActorID: requestUserID}
+		if err := recordTaskEvents(tx, []models.TaskEvent{restored}); err != nil {
+			return err
+		}
+		activity := models.Activity{
+			ActorID:    requestUserID,
+			Action:     models.ActivityRestored,
+			TargetType: models.TaskTarget,
+			TargetID:   task.ID,
+			ProjectID:  &task.ProjectID,
+		}
+		return recordActivity(tx, tr.request, activity)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore task %d: %w", taskID, err)
+	}
+	return task.ID, nil
+}
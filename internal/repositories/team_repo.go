@@ -3,50 +3,95 @@ package repositories
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
 	"mizito/pkg/models"
 
 	"gorm.io/gorm"
 )
 
 type TeamRepository interface {
-	GetTeams(userID uint) ([]models.Team, error)
-	GetTeamByID(teamID uint) (*models.Team, error)
-	GetProjectsByTeam(teamID uint) ([]*models.Project, error)
-	AddUsersToTeam(usernames []string, teamID uint, role models.Role) (uint, error)
-	DeleteUsersFromTeam(userIDs []uint, teamID uint) (uint, error)
+	// GetTeams lists the teams of the user with their projects, archived ones only when includeArchived is set
+	GetTeams(userID uint, includeArchived bool) ([]models.Team, error)
+	GetTeamByID(teamID uint, requestUserID uint) (*models.Team, error)
+	// GetProjectsByTeam lists the projects of the team, archived ones only when includeArchived is set
+	GetProjectsByTeam(teamID uint, includeArchived bool, requestUserID uint) ([]*models.Project, error)
+	DeleteUsersFromTeam(userIDs []uint, teamID uint, requestUserID uint) (uint, error)
 	CreateTeam(team *models.Team, requestUserID uint) (uint, error)
-	UpdateTeam(team *models.Team) (uint, error)
-	DeleteTeam(teamID uint) (uint, error)
-	DeleteTasks(teamID uint) (uint, error)
+	// UpdateTeam sets the fields of patch on the team, it fails with ErrVersionConflict when
+	// expectedVersion isn't 0 and the team was updated since, it returns the new version
+	UpdateTeam(teamID uint, patch *TeamPatch, expectedVersion uint, requestUserID uint) (uint, error)
+	// DeleteTeam moves the team with its projects and tasks to the trash, the memberships are kept for a restore
+	DeleteTeam(teamID uint, requestUserID uint) (uint, error)
+	// RestoreTeam brings a team back from the trash with the projects and tasks deleted together with it
+	RestoreTeam(teamID uint, requestUserID uint) (uint, error)
+	// ArchiveTeam makes the team and its projects read-only and hides them from the listings
+	ArchiveTeam(teamID uint, requestUserID uint) (uint, error)
+	// UnarchiveTeam makes an archived team writable again, only its admins may do it
+	UnarchiveTeam(teamID uint, requestUserID uint) (uint, error)
+	// WithRequest returns a copy of the repository that records its activities for the request
+	WithRequest(request RequestInfo) TeamRepository
 }
 
 type teamRepository struct {
-	db *database.DatabaseHandler
+	permissionRepo utils.PermissionRepository
+	db             *database.DatabaseHandler
+	request        RequestInfo
 }
 
 func NewTeamRepository(db *database.DatabaseHandler) TeamRepository {
 	return &teamRepository{
-		db: db,
+		permissionRepo: utils.NewPermissionRepository(db),
+		db:             db,
 	}
 }
 
-func (tr *teamRepository) GetTeams(userID uint) ([]models.Team, error) {
-	var teams []models.Team
-	err := tr.db.DB.
+func (tr *teamRepository) WithRequest(request RequestInfo) TeamRepository {
+	scoped := *tr
+	scoped.request = request
+	return &scoped
+}
+
+// teamActivity is an activity on a team placed in the team's feed
+func teamActivity(action models.ActivityAction, teamID uint, changes models.ActivityChanges, requestUserID uint) models.Activity {
+	return models.Activity{
+		ActorID:    requestUserID,
+		Action:     action,
+		TargetType: models.TeamTarget,
+		TargetID:   teamID,
+		TeamID:     &teamID,
+		Changes:    changes,
+	}
+}
+
+func (tr *teamRepository) GetTeams(userID uint, includeArchived bool) ([]models.Team, error) {
+	query := tr.db.DB.
 		Joins("JOIN team_members ON team_members.team_id = teams.id").
 		Where("team_members.user_id = ?", userID).
-		Preload("Projects").
-		Preload("Members").
-		Find(&teams).Error
+		Preload("Members")
+	if includeArchived {
+		query = query.Preload("Projects")
+	} else {
+		query = query.
+			Where("teams.archived_at IS NULL").
+			Preload("Projects", "archived_at IS NULL")
+	}
+
+	var teams []models.Team
+	err := query.Find(&teams).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get teams for user %d: %w", userID, err)
 	}
 	return teams, nil
 }
 
-func (tr *teamRepository) GetTeamByID(teamID uint) (*models.Team, error) {
+func (tr *teamRepository) GetTeamByID(teamID uint, requestUserID uint) (*models.Team, error) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TeamView, utils.TeamResource(teamID)) {
+		return nil, errors.New("you don't have access to the team")
+	}
+
 	var team models.Team
 	err := tr.db.DB.
 		Preload("Projects").
@@ -60,90 +105,33 @@ func (tr *teamRepository) GetTeamByID(teamID uint) (*models.Team, error) {
 	return &team, nil
 }
 
-func (tr *teamRepository) GetProjectsByTeam(teamID uint) ([]*models.Project, error) {
+func (tr *teamRepository) GetProjectsByTeam(teamID uint, includeArchived bool, requestUserID uint) ([]*models.Project, error) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TeamView, utils.TeamResource(teamID)) {
+		return nil, errors.New("you don't have access to the team")
+	}
+
+	query := tr.db.DB.Where("team_id = ?", teamID)
+	if !includeArchived {
+		query = query.Where("archived_at IS NULL")
+	}
+
 	var projects []*models.Project
-	err := tr.db.DB.
-		Where("team_id = ?", teamID).
-		Find(&projects).Error
+	err := query.Find(&projects).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get projects for team %d: %w", teamID, err)
 	}
 	return projects, nil
 }
 
-func (tr *teamRepository) AddUsersToTeam(usernames []string, teamID uint, role models.Role) (uint, error) {
-	if len(usernames) == 0 {
+func (tr *teamRepository) DeleteUsersFromTeam(userIDs []uint, teamID uint, requestUserID uint) (uint, error) {
+	if len(userIDs) == 0 {
 		return 0, errors.New("no user IDs provided")
 	}
-
-	tx := tr.db.DB.Begin()
-	if tx.Error != nil {
-		return 0, fmt.Errorf("failed to begin transaction: %w", tx.Error)
-	}
-
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	var team models.Team
-	if err := tx.First(&team, teamID).Error; err != nil {
-		tx.Rollback()
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return 0, fmt.Errorf("team %d not found", teamID)
-		}
-		return 0, fmt.Errorf("failed to retrieve team %d: %w", teamID, err)
-	}
-
-	addedCount := uint(0)
-	for _, username := range usernames {
-		var user models.User
-		if err := tx.Where("username = ?", username).First(&user).Error; err != nil {
-			tx.Rollback()
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return 0, fmt.Errorf("user %s not found", username)
-			}
-			return 0, fmt.Errorf("failed to retrieve user %s: %w", username, err)
-		}
-
-		var existingMember models.TeamMember
-		err := tx.Where("user_id = ? AND team_id = ?", user.ID, teamID).First(&existingMember).Error
-		if err == nil {
-			existingMember.Role = role
-			if err := tx.Save(&existingMember).Error; err != nil {
-				tx.Rollback()
-				return 0, fmt.Errorf("failed to update role for user %d in team %d: %w", username, teamID, err)
-			}
-			addedCount++
-			continue
-		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-			tx.Rollback()
-			return 0, fmt.Errorf("failed to check existing membership for user %d: %w", username, err)
-		}
-
-		tm := models.TeamMember{
-			UserID: user.ID,
-			TeamID: teamID,
-			Role:   role,
-		}
-		if err := tx.Create(&tm).Error; err != nil {
-			tx.Rollback()
-			return 0, fmt.Errorf("failed to add user %d to team %d: %w", username, teamID, err)
-		}
-		addedCount++
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	if !tr.permissionRepo.Authorize(requestUserID, models.MemberRemove, utils.TeamResource(teamID)) {
+		return 0, errors.New("you don't have permission to remove members from the team")
 	}
-
-	return addedCount, nil
-}
-
-func (tr *teamRepository) DeleteUsersFromTeam(userIDs []uint, teamID uint) (uint, error) {
-	if len(userIDs) == 0 {
-		return 0, errors.New("no user IDs provided")
+	if tr.permissionRepo.CheckArchived(utils.TeamResource(teamID)) {
+		return 0, ErrArchived
 	}
 
 	tx := tr.db.DB.Begin()
@@ -178,6 +166,14 @@ func (tr *teamRepository) DeleteUsersFromTeam(userIDs []uint, teamID uint) (uint
 		return 0, fmt.Errorf("no users were deleted from team %d", teamID)
 	}
 
+	for _, userID := range userIDs {
+		changes := models.ActivityChanges{"UserID": {Before: userID}}
+		if err := recordActivity(tx, tr.request, teamActivity(models.ActivityMemberRemoved, teamID, changes, requestUserID)); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -187,8 +183,10 @@ func (tr *teamRepository) DeleteUsersFromTeam(userIDs []uint, teamID uint) (uint
 
 func (tr *teamRepository) CreateTeam(team *models.Team, requestUserID uint) (uint, error) {
 	if team == nil {
-		return 0, errors.New("team cannot be nil")
+		return 0, errors.New("team update cannot be nil")
 	}
+	// teams are archived through ArchiveTeam
+	team.ArchivedAt = nil
 
 	tx := tr.db.DB.Begin()
 	if tx.Error != nil {
@@ -207,6 +205,11 @@ func (tr *teamRepository) CreateTeam(team *models.Team, requestUserID uint) (uin
 		return 0, fmt.Errorf("failed to create team: %w", err)
 	}
 
+	if err := recordActivity(tx, tr.request, teamActivity(models.ActivityCreated, team.ID, activityChanges(nil, team), requestUserID)); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -214,9 +217,15 @@ func (tr *teamRepository) CreateTeam(team *models.Team, requestUserID uint) (uin
 	return team.ID, nil
 }
 
-func (tr *teamRepository) UpdateTeam(team *models.Team) (uint, error) {
-	if team == nil {
-		return 0, errors.New("team cannot be nil")
+func (tr *teamRepository) UpdateTeam(teamID uint, patch *TeamPatch, expectedVersion uint, requestUserID uint) (uint, error) {
+	if patch == nil {
+		return 0, errors.New("team update cannot be nil")
+	}
+	if !tr.permissionRepo.Authorize(requestUserID, models.TeamUpdate, utils.TeamResource(teamID)) {
+		return 0, errors.New("you don't have permission to update the team")
+	}
+	if tr.permissionRepo.CheckArchived(utils.TeamResource(teamID)) {
+		return 0, ErrArchived
 	}
 
 	tx := tr.db.DB.Begin()
@@ -231,27 +240,41 @@ func (tr *teamRepository) UpdateTeam(team *models.Team) (uint, error) {
 	}()
 
 	var existingTeam models.Team
-	if err := tx.First(&existingTeam, team.ID).Error; err != nil {
+	if err := tx.First(&existingTeam, teamID).Error; err != nil {
 		tx.Rollback()
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return 0, fmt.Errorf("team %d not found", team.ID)
+			return 0, fmt.Errorf("team %d not found", teamID)
 		}
-		return 0, fmt.Errorf("failed to retrieve team %d: %w", team.ID, err)
+		return 0, fmt.Errorf("failed to retrieve team %d: %w", teamID, err)
 	}
 
-	if err := tx.Model(&existingTeam).Updates(team).Error; err != nil {
+	if err := applyPatch(tx, &models.Team{ID: teamID}, teamID, existingTeam.Version, expectedVersion, patch.changes()); err != nil {
 		tx.Rollback()
-		return 0, fmt.Errorf("failed to update team %d: %w", team.ID, err)
+		return 0, fmt.Errorf("failed to update team %d: %w", teamID, err)
+	}
+
+	var after models.Team
+	if err := tx.First(&after, teamID).Error; err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve team %d: %w", teamID, err)
+	}
+	if err := recordActivity(tx, tr.request, teamActivity(models.ActivityUpdated, teamID, activityChanges(&existingTeam, &after), requestUserID)); err != nil {
+		tx.Rollback()
+		return 0, err
 	}
 
 	if err := tx.Commit().Error; err != nil {
 		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return team.ID, nil
+	return after.Version, nil
 }
 
-func (tr *teamRepository) DeleteTeam(teamID uint) (uint, error) {
+func (tr *teamRepository) DeleteTeam(teamID uint, requestUserID uint) (uint, error) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TeamDelete, utils.TeamResource(teamID)) {
+		return 0, errors.New("you don't have permission to delete the team")
+	}
+
 	tx := tr.db.DB.Begin()
 	if tx.Error != nil {
 		return 0, fmt.Errorf("failed to begin transaction: %w", tx.Error)
@@ -272,21 +295,29 @@ func (tr *teamRepository) DeleteTeam(teamID uint) (uint, error) {
 		return 0, fmt.Errorf("failed to retrieve team %d: %w", teamID, err)
 	}
 
-	if err := tx.Where("team_id = ?", teamID).Delete(&models.Task{}).Error; err != nil {
+	// the projects and tasks share the deletion time of the team to be restored with it
+	deletedAt := time.Now()
+	teamProjects := tx.Model(&models.Project{}).Select("id").Where("team_id = ?", teamID)
+	if err := tx.Model(&models.Task{}).Where("project_id IN (?)", teamProjects).Update("deleted_at", deletedAt).Error; err != nil {
 		tx.Rollback()
 		return 0, fmt.Errorf("failed to delete tasks for team %d: %w", teamID, err)
 	}
 
-	if err := tx.Where("team_id = ?", teamID).Delete(&models.TeamMember{}).Error; err != nil {
+	if err := tx.Model(&models.Project{}).Where("team_id = ?", teamID).Update("deleted_at", deletedAt).Error; err != nil {
 		tx.Rollback()
-		return 0, fmt.Errorf("failed to delete team members for team %d: %w", teamID, err)
+		return 0, fmt.Errorf("failed to delete projects for team %d: %w", teamID, err)
 	}
 
-	if err := tx.Delete(&team).Error; err != nil {
+	if err := tx.Model(&team).Update("deleted_at", deletedAt).Error; err != nil {
 		tx.Rollback()
 		return 0, fmt.Errorf("failed to delete team %d: %w", teamID, err)
 	}
 
+	if err := recordActivity(tx, tr.request, teamActivity(models.ActivityDeleted, teamID, activityChanges(&team, nil), requestUserID)); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -294,10 +325,106 @@ func (tr *teamRepository) DeleteTeam(teamID uint) (uint, error) {
 	return teamID, nil
 }
 
-func (tr *teamRepository) DeleteTasks(teamID uint) (uint, error) {
-	result := tr.db.DB.Where("team_id = ?", teamID).Delete(&models.Task{})
-	if result.Error != nil {
-		return 0, fmt.Errorf("failed to delete tasks for team %d: %w", teamID, result.Error)
+func (tr *teamRepository) RestoreTeam(teamID uint, requestUserID uint) (uint, error) {
+	// the team can't be resolved for permissions while it is in the trash
+	if !tr.permissionRepo.CheckUserIsSystemAdmin(requestUserID) && !tr.permissionRepo.CheckUserIsAdminOfTeam(requestUserID, teamID) {
+		return 0, errors.New("you don't have permission to restore the team")
+	}
+
+	var team models.Team
+	if err := tr.db.DB.Unscoped().First(&team, teamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("team %d not found", teamID)
+		}
+		return 0, fmt.Errorf("failed to retrieve team %d: %w", teamID, err)
+	}
+	if !team.DeletedAt.Valid {
+		return 0, fmt.Errorf("team %d is not in the trash", teamID)
+	}
+
+	err := tr.db.DB.Transaction(func(tx *gorm.DB) error {
+		var projectIDs []uint
+		err := tx.Unscoped().Model(&models.Project{}).
+			Where("team_id = ? AND deleted_at = ?", teamID, team.DeletedAt.Time).
+			Pluck("id", &projectIDs).Error
+		if err != nil {
+			return err
+		}
+		if len(projectIDs) > 0 {
+			if err := restoreProjectTasks(tx, projectIDs, team.DeletedAt.Time); err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Project{}).Where("id IN ?", projectIDs).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Model(&team).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, tr.request, teamActivity(models.ActivityRestored, teamID, nil, requestUserID))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore team %d: %w", teamID, err)
 	}
-	return uint(result.RowsAffected), nil
+	return teamID, nil
+}
+
+func (tr *teamRepository) ArchiveTeam(teamID uint, requestUserID uint) (uint, error) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TeamDelete, utils.TeamResource(teamID)) {
+		return 0, errors.New("you don't have permission to archive the team")
+	}
+
+	var team models.Team
+	if err := tr.db.DB.First(&team, teamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("team %d not found", teamID)
+		}
+		return 0, fmt.Errorf("failed to retrieve team %d: %w", teamID, err)
+	}
+	if team.ArchivedAt != nil {
+		return 0, fmt.Errorf("team %d is already archived", teamID)
+	}
+
+	// the projects keep their own archived state, they are read-only through the team
+	archivedAt := time.Now()
+	err := tr.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&team).Update("archived_at", archivedAt).Error; err != nil {
+			return err
+		}
+		changes := models.ActivityChanges{"ArchivedAt": {After: archivedAt}}
+		return recordActivity(tx, tr.request, teamActivity(models.ActivityArchived, teamID, changes, requestUserID))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive team %d: %w", teamID, err)
+	}
+	return teamID, nil
+}
+
+func (tr *teamRepository) UnarchiveTeam(teamID uint, requestUserID uint) (uint, error) {
+	if !tr.permissionRepo.CheckUserIsSystemAdmin(requestUserID) && !tr.permissionRepo.CheckUserIsAdminOfTeam(requestUserID, teamID) {
+		return 0, errors.New("you don't have permission to unarchive the team")
+	}
+
+	var team models.Team
+	if err := tr.db.DB.First(&team, teamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("team %d not found", teamID)
+		}
+		return 0, fmt.Errorf("failed to retrieve team %d: %w", teamID, err)
+	}
+	if team.ArchivedAt == nil {
+		return 0, fmt.Errorf("team %d is not archived", teamID)
+	}
+
+	err := tr.db.DB.Transaction(func(tx *gorm.DB) error {
+		changes := models.ActivityChanges{"ArchivedAt": {Before: *team.ArchivedAt}}
+		if err := tx.Model(&team).Update("archived_at", nil).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, tr.request, teamActivity(models.ActivityUnarchived, teamID, changes, requestUserID))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to unarchive team %d: %w", teamID, err)
+	}
+	return teamID, nil
 }
@@ -0,0 +1,416 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type TemplateRepository interface {
+	GetTeamTemplates(teamID uint, requestUserID uint) ([]models.ProjectTemplate, error)
+	GetTemplateByID(templateID uint, requestUserID uint) (*models.ProjectTemplate, error)
+	CreateTemplate(template *models.ProjectTemplate, requestUserID uint) (uint, error)
+	// UpdateTemplate replaces the name, description and tasks of a template
+	UpdateTemplate(template *models.ProjectTemplate, requestUserID uint) (uint, error)
+	DeleteTemplate(templateID uint, requestUserID uint) (uint, error)
+	// CreateProjectFromTemplate creates the project, its members and every task of the template in one transaction
+	CreateProjectFromTemplate(templateID uint, project *models.Project, members []models.ProjectMember, startDate time.Time, requestUserID uint) (uint, error)
+	// ApplyTemplate adds the tasks of a template to an existing project
+	ApplyTemplate(templateID uint, projectID uint, startDate time.Time, requestUserID uint) ([]uint, error)
+	// WithRequest returns a copy of the repository that records its activities for the request
+	WithRequest(request RequestInfo) TemplateRepository
+}
+
+type templateRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+	request        RequestInfo
+}
+
+func NewTemplateRepository(postgreSql *database.DatabaseHandler) TemplateRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &templateRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+func (tr *templateRepository) WithRequest(request RequestInfo) TemplateRepository {
+	scoped := *tr
+	scoped.request = request
+	return &scoped
+}
+
+func (tr *templateRepository) loadTemplate(db *gorm.DB, templateID uint) (*models.ProjectTemplate, error) {
+	var template models.ProjectTemplate
+	err := db.
+		Preload("Tasks", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("Tasks.Subtasks").
+		First(&template, templateID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("template %d not found", templateID)
+		}
+		return nil, fmt.Errorf("failed to retrieve template %d: %w", templateID, err)
+	}
+	return &template, nil
+}
+
+func (tr *templateRepository) validateTemplateTasks(teamID uint, tasks []models.TemplateTask) error {
+	for i := range tasks {
+		if tasks[i].Title == "" {
+			return errors.New("template tasks require a title")
+		}
+		role := tasks[i].AssigneeRole
+		if role != "" && !tr.permissionRepo.ProjectRoleExists(teamID, role) {
+			return fmt.Errorf("role %s doesn't exist in team %d", role, teamID)
+		}
+	}
+	return nil
+}
+
+// resetTemplateTasks clears the ids of client supplied tasks so they are always created
+func resetTemplateTasks(tasks []models.TemplateTask) {
+	for i := range tasks {
+		tasks[i].ID = 0
+		tasks[i].TemplateID = 0
+		for j := range tasks[i].Subtasks {
+			tasks[i].Subtasks[j].ID = 0
+			tasks[i].Subtasks[j].TemplateTaskID = 0
+		}
+	}
+}
+
+func (tr *templateRepository) GetTeamTemplates(teamID uint, requestUserID uint) ([]models.ProjectTemplate, error) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TeamView, utils.TeamResource(teamID)) {
+		return nil, errors.New("you don't have access to the team")
+	}
+
+	var templates []models.ProjectTemplate
+	if err := tr.DB.Where("team_id = ?", teamID).Order("name").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to get templates for team %d: %w", teamID, err)
+	}
+	return templates, nil
+}
+
+func (tr *templateRepository) GetTemplateByID(templateID uint, requestUserID uint) (*models.ProjectTemplate, error) {
+	template, err := tr.loadTemplate(tr.DB, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if !tr.permissionRepo.Authorize(requestUserID, models.TeamView, utils.TeamResource(template.TeamID)) {
+		return nil, errors.New("you don't have access to the team")
+	}
+	return template, nil
+}
+
+func (tr *templateRepository) CreateTemplate(template *models.ProjectTemplate, requestUserID uint) (uint, error) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.ProjectCreate, utils.TeamResource(template.TeamID)) {
+		return 0, errors.New("you don't have permission to manage templates of the team")
+	}
+	if tr.permissionRepo.CheckArchived(utils.TeamResource(template.TeamID)) {
+		return 0, ErrArchived
+	}
+	if template.Name == "" {
+		return 0, errors.New("template name is required")
+	}
+	if err := tr.validateTemplateTasks(template.TeamID, template.Tasks); err != nil {
+		return 0, err
+	}
+
+	template.ID = 0
+	template.CreatedBy = requestUserID
+	resetTemplateTasks(template.Tasks)
+	if err := tr.DB.Create(template).Error; err != nil {
+		return 0, fmt.Errorf("failed to create template: %w", err)
+	}
+	return template.ID, nil
+}
+
+func (tr *templateRepository) UpdateTemplate(template *models.ProjectTemplate, requestUserID uint) (uint, error) {
+	var existingTemplate models.ProjectTemplate
+	if err := tr.DB.First(&existingTemplate, template.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("template %d not found", template.ID)
+		}
+		return 0, err
+	}
+	if !tr.permissionRepo.Authorize(requestUserID, models.ProjectCreate, utils.TeamResource(existingTemplate.TeamID)) {
+		return 0, errors.New("you don't have permission to manage templates of the team")
+	}
+	if tr.permissionRepo.CheckArchived(utils.TeamResource(existingTemplate.TeamID)) {
+		return 0, ErrArchived
+	}
+	if err := tr.validateTemplateTasks(existingTemplate.TeamID, template.Tasks); err != nil {
+		return 0, err
+	}
+
+	tx := tr.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	updates := map[string]interface{}{"description": template.Description}
+	if template.Name != "" {
+		updates["name"] = template.Name
+	}
+	if err := tx.Model(&existingTemplate).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to update template %d: %w", template.ID, err)
+	}
+
+	if err := deleteTemplateTasks(tx, existingTemplate.ID); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	resetTemplateTasks(template.Tasks)
+	for i := range template.Tasks {
+		template.Tasks[i].TemplateID = existingTemplate.ID
+	}
+	if len(template.Tasks) > 0 {
+		if err := tx.Create(&template.Tasks).Error; err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to update tasks of template %d: %w", template.ID, err)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return existingTemplate.ID, nil
+}
+
+func deleteTemplateTasks(tx *gorm.DB, templateID uint) error {
+	templateTasks := tx.Model(&models.TemplateTask{}).Select("id").Where("template_id = ?", templateID)
+	if err := tx.Where("template_task_id IN (?)", templateTasks).Delete(&models.TemplateSubtask{}).Error; err != nil {
+		return fmt.Errorf("failed to delete subtasks of template %d: %w", templateID, err)
+	}
+	if err := tx.Where("template_id = ?", templateID).Delete(&models.TemplateTask{}).Error; err != nil {
+		return fmt.Errorf("failed to delete tasks of template %d: %w", templateID, err)
+	}
+	return nil
+}
+
+func (tr *templateRepository) DeleteTemplate(templateID uint, requestUserID uint) (uint, error) {
+	var template models.ProjectTemplate
+	if err := tr.DB.First(&template, templateID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("template %d not found", templateID)
+		}
+		return 0, err
+	}
+	if !tr.permissionRepo.Authorize(requestUserID, models.ProjectCreate, utils.TeamResource(template.TeamID)) {
+		return 0, errors.New("you don't have permission to manage templates of the team")
+	}
+	if tr.permissionRepo.CheckArchived(utils.TeamResource(template.TeamID)) {
+		return 0, ErrArchived
+	}
+
+	err := tr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteTemplateTasks(tx, template.ID); err != nil {
+			return err
+		}
+		return tx.Delete(&template).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete template %d: %w", templateID, err)
+	}
+	return template.ID, nil
+}
+
+func (tr *templateRepository) CreateProjectFromTemplate(templateID uint, project *models.Project, members []models.ProjectMember, startDate time.Time, requestUserID uint) (uint, error) {
+	template, err := tr.loadTemplate(tr.DB, templateID)
+	if err != nil {
+		return 0, err
+	}
+	if !tr.permissionRepo.Authorize(requestUserID, models.ProjectCreate, utils.TeamResource(template.TeamID)) {
+		return 0, errors.New("you don't have permission to create projects in the team")
+	}
+	if tr.permissionRepo.CheckArchived(utils.TeamResource(template.TeamID)) {
+		return 0, ErrArchived
+	}
+
+	for i := range members {
+		if members[i].Role == "" {
+			members[i].Role = models.ProjectContributor
+		}
+		// the members are added to a project the user is about to own
+		if err := tr.permissionRepo.CheckOwnerRoleGrant(requestUserID, template.TeamID, members[i].Role); err != nil {
+			return 0, err
+		}
+		if !tr.permissionRepo.CheckUserHasAccessToTeam(members[i].UserID, template.TeamID) {
+			return 0, fmt.Errorf("user with ID %d is not a member of the project's team", members[i].UserID)
+		}
+	}
+
+	if project.Name == "" {
+		project.Name = template.Name
+	}
+	project.ID = 0
+	project.TeamID = template.TeamID
+
+	tx := tr.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(project).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := createWorkflow(tx, project.ID); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	created := projectActivity(models.ActivityCreated, project, activityChanges(nil, project), requestUserID)
+	if err := recordActivity(tx, tr.request, created); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	owner := models.ProjectMember{ProjectID: project.ID, UserID: requestUserID, Role: models.ProjectOwner}
+	if err := tx.Create(&owner).Error; err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to add user to project: %w", err)
+	}
+	for _, member := range members {
+		if member.UserID == requestUserID {
+			continue
+		}
+		member.ProjectID = project.ID
+		if err := tx.Save(&member).Error; err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to add user %d to project: %w", member.UserID, err)
+		}
+		changes := models.ActivityChanges{"UserID": {After: member.UserID}, "Role": {After: member.Role}}
+		if err := recordActivity(tx, tr.request, projectActivity(models.ActivityMemberAdded, project, changes, requestUserID)); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if _, err := instantiateTemplate(tx, tr.request, template, project.ID, startDate, requestUserID); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return project.ID, nil
+}
+
+func (tr *templateRepository) ApplyTemplate(templateID uint, projectID uint, startDate time.Time, requestUserID uint) ([]uint, error) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TaskCreate, utils.ProjectResource(projectID)) {
+		return nil, errors.New("user does not have permission to create tasks for this project")
+	}
+	if tr.permissionRepo.CheckArchived(utils.ProjectResource(projectID)) {
+		return nil, ErrArchived
+	}
+
+	template, err := tr.loadTemplate(tr.DB, templateID)
+	if err != nil {
+		return nil, err
+	}
+	var project models.Project
+	if err := tr.DB.Select("id", "team_id").First(&project, projectID).Error; err != nil {
+		return nil, err
+	}
+	if project.TeamID != template.TeamID {
+		return nil, fmt.Errorf("template %d doesn't belong to the project's team", templateID)
+	}
+
+	var taskIDs []uint
+	err = tr.DB.Transaction(func(tx *gorm.DB) error {
+		taskIDs, err = instantiateTemplate(tx, tr.request, template, projectID, startDate, requestUserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return taskIDs, nil
+}
+
+// instantiateTemplate creates the tasks of a template at the end of the project's first column
+func instantiateTemplate(tx *gorm.DB, request RequestInfo, template *models.ProjectTemplate, projectID uint, startDate time.Time, actorID uint) ([]uint, error) {
+	statuses, err := projectWorkflow(tx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	rank, err := lastRankInStatus(tx, statuses[0].ID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// project members by their effective role, the project role or else the team role
+	var members []struct {
+		UserID      uint
+		ProjectRole models.Role
+		TeamRole    models.Role
+	}
+	err = tx.Table("users_projects").
+		Select("users_projects.user_id, users_projects.role AS project_role, team_members.role AS team_role").
+		Joins("JOIN projects ON projects.id = users_projects.project_id").
+		Joins("LEFT JOIN team_members ON team_members.user_id = users_projects.user_id AND team_members.team_id = projects.team_id").
+		Where("users_projects.project_id = ?", projectID).
+		Scan(&members).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project members: %w", err)
+	}
+	usersByRole := make(map[models.Role][]models.User)
+	for _, member := range members {
+		role := member.ProjectRole
+		if role == "" {
+			role = member.TeamRole
+		}
+		usersByRole[role] = append(usersByRole[role], models.User{ID: member.UserID})
+	}
+
+	var taskIDs []uint
+	for _, templateTask := range template.Tasks {
+		rank = utils.RankBetween(rank, "")
+		task := models.Task{
+			ProjectID:    projectID,
+			Title:        templateTask.Title,
+			Description:  templateTask.Description,
+			TaskPriority: templateTask.TaskPriority,
+			DueDate:      startDate.AddDate(0, 0, templateTask.DueOffsetDays),
+			StatusID:     &statuses[0].ID,
+			Rank:         rank,
+		}
+		if templateTask.AssigneeRole != "" {
+			task.Members = usersByRole[templateTask.AssigneeRole]
+		}
+		for _, templateSubtask := range templateTask.Subtasks {
+			task.Subtasks = append(task.Subtasks, models.Subtask{Title: templateSubtask.Title})
+		}
+
+		if err := tx.Omit("Members.*").Create(&task).Error; err != nil {
+			return nil, fmt.Errorf("failed to create task %s from template: %w", templateTask.Title, err)
+		}
+		if err := recordTaskCreated(tx, &task, actorID); err != nil {
+			return nil, err
+		}
+		created := models.Activity{
+			ActorID:    actorID,
+			Action:     models.ActivityCreated,
+			TargetType: models.TaskTarget,
+			TargetID:   task.ID,
+			ProjectID:  &task.ProjectID,
+			Changes:    activityChanges(nil, &task),
+		}
+		if err := recordActivity(tx, request, created); err != nil {
+			return nil, err
+		}
+		taskIDs = append(taskIDs, task.ID)
+	}
+	return taskIDs, nil
+}
@@ -0,0 +1,363 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type TaskTime struct {
+	Entries         []models.TimeEntry `json:"entries"`
+	TotalSeconds    int64              `json:"total_seconds"`
+	EstimateMinutes int                `json:"estimate_minutes"`
+}
+
+type TimeReportRow struct {
+	UserID          uint   `json:"user_id"`
+	Username        string `json:"username"`
+	ProjectID       uint   `json:"project_id"`
+	TaskID          uint   `json:"task_id"`
+	TaskTitle       string `json:"task_title"`
+	EstimateMinutes int    `json:"estimate_minutes"`
+	Seconds         int64  `json:"seconds"`
+}
+
+type TimeReport struct {
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	TotalSeconds int64           `json:"total_seconds"`
+	Rows         []TimeReportRow `json:"rows"`
+}
+
+// TimesheetEntry is a time entry clipped to the requested range, with the names needed for an export
+type TimesheetEntry struct {
+	EntryID         uint
+	UserID          uint
+	Username        string
+	ProjectID       uint
+	ProjectName     string
+	TaskID          uint
+	TaskTitle       string
+	EstimateMinutes int
+	StartedAt       time.Time
+	EndedAt         *time.Time
+	Seconds         int64
+	Note            string
+	Manual          bool
+}
+
+type TimeRepository interface {
+	// StartTimer starts tracking the task, a timer already running for the user is stopped first
+	StartTimer(taskID uint, note string, requestUserID uint) (*models.TimeEntry, error)
+	StopTimer(requestUserID uint) (*models.TimeEntry, error)
+	GetRunningTimer(requestUserID uint) (*models.TimeEntry, error)
+	CreateEntry(entry *models.TimeEntry, requestUserID uint) (uint, error)
+	UpdateEntry(entry *models.TimeEntry, requestUserID uint) (uint, error)
+	DeleteEntry(entryID uint, requestUserID uint) (uint, error)
+	GetTaskTime(taskID uint, requestUserID uint) (*TaskTime, error)
+	GetProjectTimesheet(projectID uint, from time.Time, to time.Time, requestUserID uint) ([]TimesheetEntry, error)
+	// GetUserTimesheet returns the entries of a user, anyone but the user needs teamID and permission to manage its members
+	GetUserTimesheet(userID uint, teamID uint, from time.Time, to time.Time, requestUserID uint) ([]TimesheetEntry, error)
+}
+
+type timeRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+}
+
+func NewTimeRepository(postgreSql *database.DatabaseHandler) TimeRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &timeRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+func stopRunningTimer(tx *gorm.DB, userID uint, now time.Time) (*models.TimeEntry, error) {
+	var entry models.TimeEntry
+	err := tx.Where("user_id = ? AND ended_at IS NULL", userID).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get running timer: %w", err)
+	}
+
+	entry.EndedAt = &now
+	if err := tx.Model(&entry).Update("ended_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to stop timer: %w", err)
+	}
+	return &entry, nil
+}
+
+func (tr *timeRepository) StartTimer(taskID uint, note string, requestUserID uint) (*models.TimeEntry, error) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TimeTrack, utils.TaskResource(taskID)) {
+		return nil, errors.New("you don't have permission to track time on this task")
+	}
+	if tr.permissionRepo.CheckArchived(utils.TaskResource(taskID)) {
+		return nil, ErrArchived
+	}
+
+	now := time.Now()
+	entry := models.TimeEntry{TaskID: taskID, UserID: requestUserID, StartedAt: now, Note: note}
+	err := tr.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := stopRunningTimer(tx, requestUserID, now); err != nil {
+			return err
+		}
+		return tx.Create(&entry).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start timer: %w", err)
+	}
+	return &entry, nil
+}
+
+func (tr *timeRepository) StopTimer(requestUserID uint) (*models.TimeEntry, error) {
+	entry, err := stopRunningTimer(tr.DB, requestUserID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, errors.New("no timer is running")
+	}
+	return entry, nil
+}
+
+func (tr *timeRepository) GetRunningTimer(requestUserID uint) (*models.TimeEntry, error) {
+	var entry models.TimeEntry
+	if err := tr.DB.Where("user_id = ? AND ended_at IS NULL", requestUserID).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("no timer is running")
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func validateTimeEntry(entry *models.TimeEntry) error {
+	if entry.StartedAt.IsZero() || entry.EndedAt == nil {
+		return errors.New("start and end time are required")
+	}
+	if !entry.EndedAt.After(entry.StartedAt) {
+		return errors.New("end time must be after start time")
+	}
+	if entry.EndedAt.After(time.Now()) {
+		return errors.New("time entries can't end in the future")
+	}
+	return nil
+}
+
+func (tr *timeRepository) CreateEntry(entry *models.TimeEntry, requestUserID uint) (uint, error) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TimeTrack, utils.TaskResource(entry.TaskID)) {
+		return 0, errors.New("you don't have permission to track time on this task")
+	}
+	if tr.permissionRepo.CheckArchived(utils.TaskResource(entry.TaskID)) {
+		return 0, ErrArchived
+	}
+	if err := validateTimeEntry(entry); err != nil {
+		return 0, err
+	}
+
+	entry.ID = 0
+	entry.UserID = requestUserID
+	entry.Manual = true
+	if err := tr.DB.Create(entry).Error; err != nil {
+		return 0, fmt.Errorf("failed to create time entry: %w", err)
+	}
+	return entry.ID, nil
+}
+
+// ownEntry loads a changeable entry of the user, roles with time.manage may change everyone's entries
+func (tr *timeRepository) ownEntry(entryID uint, requestUserID uint) (*models.TimeEntry, error) {
+	var entry models.TimeEntry
+	if err := tr.DB.First(&entry, entryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("time entry %d not found", entryID)
+		}
+		return nil, err
+	}
+	if entry.UserID != requestUserID && !tr.permissionRepo.Authorize(requestUserID, models.TimeManage, utils.TaskResource(entry.TaskID)) {
+		return nil, errors.New("you can only change your own time entries")
+	}
+	if tr.permissionRepo.CheckArchived(utils.TaskResource(entry.TaskID)) {
+		return nil, ErrArchived
+	}
+	return &entry, nil
+}
+
+func (tr *timeRepository) UpdateEntry(entry *models.TimeEntry, requestUserID uint) (uint, error) {
+	existingEntry, err := tr.ownEntry(entry.ID, requestUserID)
+	if err != nil {
+		return 0, err
+	}
+	if existingEntry.EndedAt == nil {
+		return 0, errors.New("stop the timer before editing the entry")
+	}
+	if err := validateTimeEntry(entry); err != nil {
+		return 0, err
+	}
+
+	err = tr.DB.Model(existingEntry).Updates(map[string]interface{}{
+		"started_at": entry.StartedAt,
+		"ended_at":   entry.EndedAt,
+		"note":       entry.Note,
+		"manual":     true,
+	}).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to update time entry %d: %w", entry.ID, err)
+	}
+	return existingEntry.ID, nil
+}
+
+func (tr *timeRepository) DeleteEntry(entryID uint, requestUserID uint) (uint, error) {
+	entry, err := tr.ownEntry(entryID, requestUserID)
+	if err != nil {
+		return 0, err
+	}
+	if err := tr.DB.Delete(entry).Error; err != nil {
+		return 0, fmt.Errorf("failed to delete time entry %d: %w", entryID, err)
+	}
+	return entry.ID, nil
+}
+
+func (tr *timeRepository) GetTaskTime(taskID uint, requestUserID uint) (*TaskTime, error) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(taskID)) {
+		return nil, errors.New("you don't have access to the task")
+	}
+
+	var task models.Task
+	if err := tr.DB.Select("id", "estimate_minutes").First(&task, taskID).Error; err != nil {
+		return nil, err
+	}
+
+	taskTime := TaskTime{Entries: []models.TimeEntry{}, EstimateMinutes: task.EstimateMinutes}
+	if err := tr.DB.Where("task_id = ?", taskID).Order("started_at").Find(&taskTime.Entries).Error; err != nil {
+		return nil, err
+	}
+	for i := range taskTime.Entries {
+		taskTime.TotalSeconds += int64(taskTime.Entries[i].Duration().Seconds())
+	}
+	return &taskTime, nil
+}
+
+func (tr *timeRepository) GetProjectTimesheet(projectID uint, from time.Time, to time.Time, requestUserID uint) ([]TimesheetEntry, error) {
+	if !tr.permissionRepo.Authorize(requestUserID, models.ProjectView, utils.ProjectResource(projectID)) {
+		return nil, errors.New("you don't have access to the project")
+	}
+	return tr.timesheet(tr.DB.Where("tasks.project_id = ?", projectID), from, to)
+}
+
+func (tr *timeRepository) GetUserTimesheet(userID uint, teamID uint, from time.Time, to time.Time, requestUserID uint) ([]TimesheetEntry, error) {
+	query := tr.DB.Where("time_entries.user_id = ?", userID)
+	if teamID != 0 {
+		query = query.Where("projects.team_id = ?", teamID)
+	}
+
+	if userID != requestUserID && !tr.permissionRepo.CheckUserIsSystemAdmin(requestUserID) {
+		if teamID == 0 {
+			return nil, errors.New("team is required to view the time of other users")
+		}
+		if !tr.permissionRepo.Authorize(requestUserID, models.TimeViewAll, utils.TeamResource(teamID)) {
+			return nil, errors.New("you don't have permission to view the time of team members")
+		}
+	}
+	return tr.timesheet(query, from, to)
+}
+
+// timesheet returns the entries overlapping [from, to), their durations clipped to the range
+func (tr *timeRepository) timesheet(query *gorm.DB, from time.Time, to time.Time) ([]TimesheetEntry, error) {
+	var rows []struct {
+		models.TimeEntry
+		Username        string
+		ProjectID       uint
+		ProjectName     string
+		TaskTitle       string
+		EstimateMinutes int
+	}
+	err := query.Model(&models.TimeEntry{}).
+		Select("time_entries.*, users.username, projects.id AS project_id, projects.name AS project_name, tasks.title AS task_title, tasks.estimate_minutes").
+		Joins("JOIN tasks ON tasks.id = time_entries.task_id AND tasks.deleted_at IS NULL").
+		Joins("JOIN projects ON projects.id = tasks.project_id AND projects.deleted_at IS NULL").
+		Joins("JOIN users ON users.id = time_entries.user_id").
+		Where("time_entries.started_at < ? AND (time_entries.ended_at IS NULL OR time_entries.ended_at > ?)", to, from).
+		Order("time_entries.started_at").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time entries: %w", err)
+	}
+
+	now := time.Now()
+	entries := make([]TimesheetEntry, 0, len(rows))
+	for _, row := range rows {
+		start, end := row.StartedAt, now
+		if row.EndedAt != nil {
+			end = *row.EndedAt
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		entries = append(entries, TimesheetEntry{
+			EntryID:         row.ID,
+			UserID:          row.UserID,
+			Username:        row.Username,
+			ProjectID:       row.ProjectID,
+			ProjectName:     row.ProjectName,
+			TaskID:          row.TaskID,
+			TaskTitle:       row.TaskTitle,
+			EstimateMinutes: row.EstimateMinutes,
+			StartedAt:       row.StartedAt,
+			EndedAt:         row.EndedAt,
+			Seconds:         int64(end.Sub(start).Seconds()),
+			Note:            row.Note,
+			Manual:          row.Manual,
+		})
+	}
+	return entries, nil
+}
+
+// SummarizeTimesheet totals a timesheet per user and task
+func SummarizeTimesheet(entries []TimesheetEntry, from time.Time, to time.Time) *TimeReport {
+	report := TimeReport{From: from, To: to, Rows: []TimeReportRow{}}
+
+	type rowKey struct{ userID, taskID uint }
+	rows := make(map[rowKey]*TimeReportRow)
+	for _, entry := range entries {
+		key := rowKey{entry.UserID, entry.TaskID}
+		row, ok := rows[key]
+		if !ok {
+			row = &TimeReportRow{
+				UserID:          entry.UserID,
+				Username:        entry.Username,
+				ProjectID:       entry.ProjectID,
+				TaskID:          entry.TaskID,
+				TaskTitle:       entry.TaskTitle,
+				EstimateMinutes: entry.EstimateMinutes,
+			}
+			rows[key] = row
+		}
+		row.Seconds += entry.Seconds
+		report.TotalSeconds += entry.Seconds
+	}
+
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Username != report.Rows[j].Username {
+			return report.Rows[i].Username < report.Rows[j].Username
+		}
+		return report.Rows[i].TaskID < report.Rows[j].TaskID
+	})
+	return &report
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Trash is what was deleted in a team, Team is only set when the team itself is in the trash
+type Trash struct {
+	Team     *models.Team
+	Projects []models.Project
+	Tasks    []models.Task
+}
+
+type TrashRepository interface {
+	// GetTeamTrash lists the deleted projects and tasks of a team, newest first
+	GetTeamTrash(teamID uint, requestUserID uint) (*Trash, error)
+}
+
+type trashRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+}
+
+func NewTrashRepository(postgreSql *database.DatabaseHandler) TrashRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &trashRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+func (tr *trashRepository) GetTeamTrash(teamID uint, requestUserID uint) (*Trash, error) {
+	var team models.Team
+	if err := tr.DB.Unscoped().First(&team, teamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("team %d not found", teamID)
+		}
+		return nil, fmt.Errorf("failed to retrieve team %d: %w", teamID, err)
+	}
+
+	trash := &Trash{Projects: []models.Project{}, Tasks: []models.Task{}}
+	if team.DeletedAt.Valid {
+		// a deleted team can't be resolved for permissions anymore, only its admins see its trash
+		if !tr.permissionRepo.CheckUserIsSystemAdmin(requestUserID) && !tr.permissionRepo.CheckUserIsAdminOfTeam(requestUserID, teamID) {
+			return nil, errors.New("you don't have access to the team")
+		}
+		trash.Team = &team
+	} else if !tr.permissionRepo.Authorize(requestUserID, models.TeamView, utils.TeamResource(teamID)) {
+		return nil, errors.New("you don't have access to the team")
+	}
+
+	// members only see what was deleted in the projects they are in, the memberships stay with the
+	// projects in the trash
+	projects := tr.DB.Unscoped().Where("team_id = ? AND deleted_at IS NOT NULL", teamID)
+	teamProjects := tr.DB.Unscoped().Model(&models.Project{}).Select("id").Where("team_id = ?", teamID)
+	if !tr.permissionRepo.CheckUserIsSystemAdmin(requestUserID) && !tr.permissionRepo.CheckUserIsAdminOfTeam(requestUserID, teamID) {
+		memberProjects := tr.DB.Model(&models.ProjectMember{}).Select("project_id").Where("user_id = ?", requestUserID)
+		projects = projects.Where("id IN (?)", memberProjects)
+		teamProjects = teamProjects.Where("id IN (?)", memberProjects)
+	}
+
+	err := projects.Order("deleted_at DESC, id").Find(&trash.Projects).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deleted projects of team %d: %w", teamID, err)
+	}
+
+	err = tr.DB.Unscoped().
+		Where("project_id IN (?) AND deleted_at IS NOT NULL", teamProjects).
+		Order("deleted_at DESC, id").
+		Find(&trash.Tasks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deleted tasks of team %d: %w", teamID, err)
+	}
+	return trash, nil
+}
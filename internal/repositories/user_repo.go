@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"errors"
+	"fmt"
+	"unicode"
 
 	"mizito/internal/database"
 	"mizito/pkg/models"
@@ -16,10 +18,30 @@ type UserRepository interface {
 	GetAllUsers() ([]*models.User, error)
 	UpdateUser(user *models.User) (uint, error)
 	DeleteUser(userID uint) (uint, error)
+	GetUserByEmail(email string) (*models.User, error)
+	UpdatePassword(userID uint, password string) error
+	// ChangePassword replaces the password of a signed in user, it fails with ErrWrongPassword
+	// unless currentPassword matches the stored one
+	ChangePassword(userID uint, currentPassword string, password string) error
+	// MarkEmailVerified confirms email for the user, it fails with ErrEmailChanged when the user's
+	// address is no longer email
+	MarkEmailVerified(userID uint, email string) error
+	// WithRequest returns a copy of the repository that records its activities for the request,
+	// the request's ActorID is the actor of them
+	WithRequest(request RequestInfo) UserRepository
 }
 
+var ErrWeakPassword = errors.New("password is too weak")
+
+var ErrWrongPassword = errors.New("current password is incorrect")
+
+var ErrEmailChanged = errors.New("the email address has changed since the token was issued")
+
+const minPasswordLength = 8
+
 type userRepository struct {
-	db *database.DatabaseHandler
+	db      *database.DatabaseHandler
+	request RequestInfo
 }
 
 func NewUserRepository(db *database.DatabaseHandler) UserRepository {
@@ -28,14 +50,49 @@ func NewUserRepository(db *database.DatabaseHandler) UserRepository {
 	}
 }
 
+func (ur *userRepository) WithRequest(request RequestInfo) UserRepository {
+	scoped := *ur
+	scoped.request = request
+	return &scoped
+}
+
+// recordUserActivity records a change of an account, anonymous requests like sign ups and
+// password resets are attributed to the account itself
+func (ur *userRepository) recordUserActivity(tx *gorm.DB, action models.ActivityAction, userID uint, changes models.ActivityChanges) error {
+	actorID := ur.request.ActorID
+	if actorID == 0 {
+		actorID = userID
+	}
+	activity := models.Activity{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: models.UserTarget,
+		TargetID:   userID,
+		Changes:    changes,
+	}
+	return recordActivity(tx, ur.request, activity)
+}
+
 func (ur *userRepository) CreateUser(user *models.User) (uint, error) {
+	if err := ValidatePassword(user.Password); err != nil {
+		return 0, err
+	}
 	hashedPassword, err := hashPassword(user.Password)
 	if err != nil {
 		return 0, err
 	}
 	user.Password = hashedPassword
+	// admin rights can't be granted through sign up
+	user.IsAdmin = false
+	user.EmailVerified = false
 
-	if err := ur.db.DB.Create(user).Error; err != nil {
+	err = ur.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return ur.recordUserActivity(tx, models.ActivityCreated, user.ID, activityChanges(nil, user))
+	})
+	if err != nil {
 		return 0, err
 	}
 	return user.ID, nil
@@ -61,30 +118,141 @@ func (ur *userRepository) GetAllUsers() ([]*models.User, error) {
 }
 
 func (ur *userRepository) UpdateUser(user *models.User) (uint, error) {
+	var existingUser models.User
+	if err := ur.db.DB.First(&existingUser, user.ID).Error; err != nil {
+		return 0, err
+	}
+
 	allowedUpdates := map[string]interface{}{
 		"Username": user.Username,
 		"Email":    user.Email,
 	}
 
-	if err := ur.db.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(allowedUpdates).Error; err != nil {
+	// a changed address has to be verified again
+	if user.Email != existingUser.Email {
+		allowedUpdates["EmailVerified"] = false
+	}
+
+	err := ur.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{ID: user.ID}).Updates(allowedUpdates).Error; err != nil {
+			return err
+		}
+		var updatedUser models.User
+		if err := tx.First(&updatedUser, user.ID).Error; err != nil {
+			return err
+		}
+		return ur.recordUserActivity(tx, models.ActivityUpdated, user.ID, activityChanges(&existingUser, &updatedUser))
+	})
+	if err != nil {
 		return 0, err
 	}
 	return user.ID, nil
 }
 
 func (ur *userRepository) DeleteUser(userID uint) (uint, error) {
-	result := ur.db.DB.Delete(&models.User{}, userID)
-	if result.Error != nil {
-		return 0, result.Error
-	}
-
-	if result.RowsAffected == 0 {
+	var user models.User
+	err := ur.db.DB.First(&user, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return 0, nil
+	} else if err != nil {
+		return 0, err
 	}
 
+	err = ur.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		return ur.recordUserActivity(tx, models.ActivityDeleted, userID, activityChanges(&user, nil))
+	})
+	if err != nil {
+		return 0, err
+	}
 	return userID, nil
 }
 
+func (ur *userRepository) GetUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	err := ur.db.DB.Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (ur *userRepository) ChangePassword(userID uint, currentPassword string, password string) error {
+	var user models.User
+	if err := ur.db.DB.Select("id", "password").First(&user, userID).Error; err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(currentPassword)) != nil {
+		return ErrWrongPassword
+	}
+	return ur.UpdatePassword(userID, password)
+}
+
+func (ur *userRepository) UpdatePassword(userID uint, password string) error {
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return ur.db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{ID: userID}).Update("password", hashedPassword)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("user %d not found", userID)
+		}
+		changes := models.ActivityChanges{"Password": {Before: maskedValue, After: maskedValue}}
+		return ur.recordUserActivity(tx, models.ActivityPasswordChanged, userID, changes)
+	})
+}
+
+func (ur *userRepository) MarkEmailVerified(userID uint, email string) error {
+	return ur.db.DB.Transaction(func(tx *gorm.DB) error {
+		// matching the address in the update keeps a concurrent email change from being verified
+		result := tx.Model(&models.User{ID: userID}).Where("email = ?", email).Update("email_verified", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEmailChanged
+		}
+		changes := models.ActivityChanges{"EmailVerified": {Before: false, After: true}}
+		return ur.recordUserActivity(tx, models.ActivityEmailVerified, userID, changes)
+	})
+}
+
+// ValidatePassword enforces the password policy: a minimum length and a mix of
+// upper case letters, lower case letters and digits
+func ValidatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("%w: it must be at least %d characters long", ErrWeakPassword, minPasswordLength)
+	}
+
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasUpper || !hasLower || !hasDigit {
+		return fmt.Errorf("%w: it must contain upper case letters, lower case letters and digits", ErrWeakPassword)
+	}
+	return nil
+}
+
 func hashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
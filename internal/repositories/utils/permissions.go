@@ -1,30 +1,82 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"mizito/internal/database"
 	"mizito/pkg/models"
 	"sync"
 )
 
+type ResourceType string
+
+const (
+	ResourceTeam    ResourceType = "team"
+	ResourceProject ResourceType = "project"
+	ResourceTask    ResourceType = "task"
+	ResourceSubtask ResourceType = "subtask"
+)
+
+// Resource identifies the entity an action is performed on.
+type Resource struct {
+	Type ResourceType
+	ID   uint
+}
+
+func TeamResource(teamID uint) Resource {
+	return Resource{Type: ResourceTeam, ID: teamID}
+}
+
+func ProjectResource(projectID uint) Resource {
+	return Resource{Type: ResourceProject, ID: projectID}
+}
+
+func TaskResource(taskID uint) Resource {
+	return Resource{Type: ResourceTask, ID: taskID}
+}
+
+func SubtaskResource(subtaskID uint) Resource {
+	return Resource{Type: ResourceSubtask, ID: subtaskID}
+}
+
+type Authorizer interface {
+	// Authorize reports whether the user may perform the action on the resource.
+	// Team admins and system admins may do anything, otherwise the permissions of the user's
+	// role decide, where a project role overrides the team role for everything inside the project.
+	Authorize(userId uint, action models.Permission, resource Resource) bool
+	// RoleExists reports whether the role is a built-in team role or a custom role of the team
+	RoleExists(teamId uint, role models.Role) bool
+	// ProjectRoleExists reports whether the role can be held on a project of the team, a built-in
+	// project role or a custom role of the team
+	ProjectRoleExists(teamId uint, role models.Role) bool
+	// CheckRoleGrant returns an error unless the user may give the role to a member of the resource,
+	// a team or a project. projects only take project roles and custom roles, only team admins give
+	// the admin role and a role can't carry permissions the user doesn't hold on the resource.
+	CheckRoleGrant(userId uint, resource Resource, role models.Role) error
+	// CheckOwnerRoleGrant is CheckRoleGrant for a project of the team the user creates and owns
+	CheckOwnerRoleGrant(userId uint, teamId uint, role models.Role) error
+}
+
+type SystemPermissionHandler interface {
+	CheckUserIsSystemAdmin(userId uint) bool
+}
+
 type TeamPermissionHandler interface {
 	CheckUserHasAccessToTeam(userId uint, teamId uint) bool
 	CheckUserIsAdminOfTeam(userId uint, teamId uint) bool
 }
 
-type TaskPermissionHandler interface {
-	CheckUserHasAccessToTask(taskId uint, userId uint) bool
-	CheckUserIsAdminOfTask(taskId uint, userId uint) bool
-}
-
-type ProjectPermissionHandler interface {
-	CheckUserHasAccessToProject(projectId uint, userId uint) bool
-	CheckUserIsAdminOfProject(projectId uint, userId uint) bool
+type ArchiveHandler interface {
+	// CheckArchived reports whether the resource is an archived team or project or lies inside one,
+	// archived resources are read-only
+	CheckArchived(resource Resource) bool
 }
 
 type PermissionRepository interface {
+	Authorizer
+	SystemPermissionHandler
 	TeamPermissionHandler
-	TaskPermissionHandler
-	ProjectPermissionHandler
+	ArchiveHandler
 }
 
 type permissionRepository struct {
@@ -43,6 +95,17 @@ func NewPermissionRepository(postgres *database.DatabaseHandler) PermissionRepos
 	return permissionRepoInstance
 }
 
+func (ph *permissionRepository) CheckUserIsSystemAdmin(userId uint) bool {
+	var count int64
+	err := ph.db.DB.Model(&models.User{}).
+		Where("id = ? AND is_admin = ?", userId, true).
+		Count(&count).Error
+	if err != nil {
+		return false
+	}
+	return count > 0
+}
+
 func (ph *permissionRepository) CheckUserHasAccessToTeam(userId uint, teamId uint) bool {
 	var count int64
 	err := ph.db.DB.Model(&models.TeamMember{}).
@@ -67,79 +130,231 @@ func (ph *permissionRepository) CheckUserIsAdminOfTeam(userId uint, teamId uint)
 	return count > 0
 }
 
-func (ph *permissionRepository) CheckUserHasAccessToTask(taskId uint, userId uint) bool {
-	var task models.Task
-	err := ph.db.DB.First(&task, taskId).Error
+func (ph *permissionRepository) Authorize(userId uint, action models.Permission, resource Resource) bool {
+	if ph.CheckUserIsSystemAdmin(userId) {
+		return true
+	}
+
+	teamId, projectId, err := ph.resolveScope(resource)
 	if err != nil {
-		// Task not found or other error
+		// Resource not found or other error
 		return false
 	}
 
-	// Otherwise, check if the user is a member of the task
-	var count int64
-	err = ph.db.DB.Model(&models.Task{}).
-		Where("id = ?", taskId).
-		Joins("JOIN task_members ON task_members.task_id = tasks.id").
-		Where("task_members.user_id = ?", userId).
-		Count(&count).Error
-	if err != nil {
-		// Handle error appropriately, possibly logging it
+	role, isAdmin, ok := ph.actingRole(userId, teamId, projectId)
+	if !ok {
 		return false
 	}
+	if isAdmin {
+		return true
+	}
+	return ph.roleHasPermission(teamId, role, action)
+}
 
-	return count > 0
+// actingRole returns the role the user acts with in the team, or in the project when projectId isn't 0.
+// isAdmin is set for team admins and ok is false when the user isn't a member.
+func (ph *permissionRepository) actingRole(userId uint, teamId uint, projectId uint) (models.Role, bool, bool) {
+	var teamMember models.TeamMember
+	if err := ph.db.DB.Where("user_id = ? AND team_id = ?", userId, teamId).First(&teamMember).Error; err != nil {
+		return "", false, false
+	}
+	if teamMember.Role == models.Admin {
+		return models.Admin, true, true
+	}
+
+	role := teamMember.Role
+	if projectId != 0 {
+		// everything inside a project requires being a member of it
+		var projectMember models.ProjectMember
+		if err := ph.db.DB.Where("project_id = ? AND user_id = ?", projectId, userId).First(&projectMember).Error; err != nil {
+			return "", false, false
+		}
+		if projectMember.Role != "" {
+			role = projectMember.Role
+		}
+	}
+	return role, false, true
 }
 
-func (ph *permissionRepository) CheckUserIsAdminOfTask(taskId uint, userId uint) bool {
-	var task models.Task
-	err := ph.db.DB.First(&task, taskId).Error
+func (ph *permissionRepository) CheckRoleGrant(userId uint, resource Resource, role models.Role) error {
+	teamId, projectId, err := ph.resolveScope(resource)
 	if err != nil {
-		// Task not found or other error
-		return false
+		return fmt.Errorf("failed to resolve %s %d: %w", resource.Type, resource.ID, err)
 	}
 
-	// Check if user is an admin of the project
-	var projectAdmin bool = ph.CheckUserIsAdminOfProject(task.ProjectID, userId)
-	if projectAdmin {
-		return true
+	if ph.CheckUserIsSystemAdmin(userId) {
+		return ph.checkGrant(teamId, projectId != 0, models.Admin, true, role)
+	}
+	granterRole, isAdmin, ok := ph.actingRole(userId, teamId, projectId)
+	if !ok {
+		return fmt.Errorf("you aren't a member of the %s", resource.Type)
+	}
+	return ph.checkGrant(teamId, projectId != 0, granterRole, isAdmin, role)
+}
+
+func (ph *permissionRepository) CheckOwnerRoleGrant(userId uint, teamId uint, role models.Role) error {
+	isAdmin := ph.CheckUserIsSystemAdmin(userId) || ph.CheckUserIsAdminOfTeam(userId, teamId)
+	return ph.checkGrant(teamId, true, models.ProjectOwner, isAdmin, role)
+}
+
+// checkGrant checks a role given in the team, or in one of its projects when projectScope is set,
+// by a granter acting with granterRole
+func (ph *permissionRepository) checkGrant(teamId uint, projectScope bool, granterRole models.Role, granterIsAdmin bool, role models.Role) error {
+	if projectScope && (role == models.Admin || role == models.Member) {
+		return fmt.Errorf("role %s can't be given on a project", role)
+	}
+	if !projectScope && models.IsProjectRole(role) {
+		return fmt.Errorf("role %s can only be given on a project", role)
+	}
+	if role == models.Admin {
+		if !granterIsAdmin {
+			return errors.New("only team admins may give the admin role")
+		}
+		return nil
+	}
+
+	permissions, ok := ph.rolePermissions(teamId, role)
+	if !ok {
+		return fmt.Errorf("role %s doesn't exist in team %d", role, teamId)
+	}
+	if granterIsAdmin {
+		return nil
+	}
+
+	held, _ := ph.rolePermissions(teamId, granterRole)
+	for _, permission := range permissions {
+		if !hasPermission(held, permission) {
+			return fmt.Errorf("role %s has the %s permission you don't hold", role, permission)
+		}
 	}
+	return nil
+}
 
+func hasPermission(permissions []models.Permission, action models.Permission) bool {
+	for _, permission := range permissions {
+		if permission == action {
+			return true
+		}
+	}
 	return false
 }
 
-func (ph *permissionRepository) CheckUserHasAccessToProject(projectId uint, userId uint) bool {
-	var project models.Project
-	err := ph.db.DB.First(&project, projectId).Error
+// rolePermissions returns the permissions of a built-in role or a custom role of the team,
+// ok is false when there's no such role
+func (ph *permissionRepository) rolePermissions(teamId uint, role models.Role) ([]models.Permission, bool) {
+	if permissions, ok := models.BuiltinPermissions(role); ok {
+		return permissions, true
+	}
+
+	var customRole models.CustomRole
+	err := ph.db.DB.Preload("Permissions").
+		Where("team_id = ? AND name = ?", teamId, role).
+		First(&customRole).Error
+	if err != nil {
+		return nil, false
+	}
+	permissions := make([]models.Permission, 0, len(customRole.Permissions))
+	for _, permission := range customRole.Permissions {
+		permissions = append(permissions, permission.Permission)
+	}
+	return permissions, true
+}
+
+func (ph *permissionRepository) CheckArchived(resource Resource) bool {
+	teamId, projectId, err := ph.resolveScope(resource)
 	if err != nil {
+		// missing resources are rejected by the permission checks
 		return false
 	}
+
+	// a failing lookup counts as archived so writes fail closed
 	var count int64
+	err = ph.db.DB.Model(&models.Team{}).
+		Where("id = ? AND archived_at IS NOT NULL", teamId).
+		Count(&count).Error
+	if err != nil || count > 0 {
+		return true
+	}
+	if projectId == 0 {
+		return false
+	}
+
 	err = ph.db.DB.Model(&models.Project{}).
-		Where("id = ?", projectId).
-		Joins("JOIN users_projects ON users_projects.project_id = projects.id").
-		Where("users_projects.user_id = ?", userId).
+		Where("id = ? AND archived_at IS NOT NULL", projectId).
+		Count(&count).Error
+	return err != nil || count > 0
+}
+
+// resolveScope finds the team and, for resources living inside a project, the project of the resource.
+func (ph *permissionRepository) resolveScope(resource Resource) (uint, uint, error) {
+	switch resource.Type {
+	case ResourceTeam:
+		// teams in the trash can only be restored by their admins
+		var team models.Team
+		if err := ph.db.DB.Select("id").First(&team, resource.ID).Error; err != nil {
+			return 0, 0, err
+		}
+		return team.ID, 0, nil
+	case ResourceProject:
+		var project models.Project
+		if err := ph.db.DB.Select("id", "team_id").First(&project, resource.ID).Error; err != nil {
+			return 0, 0, err
+		}
+		return project.TeamID, project.ID, nil
+	case ResourceTask:
+		var task models.Task
+		if err := ph.db.DB.Select("id", "project_id").First(&task, resource.ID).Error; err != nil {
+			return 0, 0, err
+		}
+		return ph.resolveScope(ProjectResource(task.ProjectID))
+	case ResourceSubtask:
+		var subtask models.Subtask
+		if err := ph.db.DB.Select("id", "task_id").First(&subtask, resource.ID).Error; err != nil {
+			return 0, 0, err
+		}
+		return ph.resolveScope(TaskResource(subtask.TaskID))
+	}
+	return 0, 0, errors.New("unknown resource type")
+}
+
+func (ph *permissionRepository) roleHasPermission(teamId uint, role models.Role, action models.Permission) bool {
+	if permissions, ok := models.BuiltinPermissions(role); ok {
+		return hasPermission(permissions, action)
+	}
+
+	var count int64
+	err := ph.db.DB.Model(&models.RolePermission{}).
+		Joins("JOIN custom_roles ON custom_roles.id = role_permissions.role_id").
+		Where("custom_roles.team_id = ? AND custom_roles.name = ? AND role_permissions.permission = ?", teamId, role, action).
 		Count(&count).Error
 	if err != nil {
 		return false
 	}
-
 	return count > 0
 }
 
-func (ph *permissionRepository) CheckUserIsAdminOfProject(projectId uint, userId uint) bool {
-	var project models.Project
-	err := ph.db.DB.First(&project, projectId).Error
+func (ph *permissionRepository) RoleExists(teamId uint, role models.Role) bool {
+	if _, ok := models.BuiltinRolePermissions[role]; ok {
+		return true
+	}
+
+	var count int64
+	err := ph.db.DB.Model(&models.CustomRole{}).
+		Where("team_id = ? AND name = ?", teamId, role).
+		Count(&count).Error
 	if err != nil {
 		return false
 	}
+	return count > 0
+}
 
-	// Check if user is an admin of the team
-	var teamMember models.TeamMember
-	err = ph.db.DB.Where("team_id = ? AND user_id = ? AND role = ?", project.TeamID, userId, models.Admin).First(&teamMember).Error
-	if err == nil {
-		// User is a team admin
+func (ph *permissionRepository) ProjectRoleExists(teamId uint, role models.Role) bool {
+	if models.IsProjectRole(role) {
 		return true
 	}
-
-	return false
+	if _, ok := models.BuiltinRolePermissions[role]; ok {
+		return false
+	}
+	_, ok := ph.rolePermissions(teamId, role)
+	return ok
 }
@@ -0,0 +1,79 @@
+package utils
+
+import "strings"
+
+// ranks are base36 fractions (the digits after "0."), compared lexicographically.
+// they never end with a zero digit so there is always room between two of them,
+// which lets a single item be reordered without touching its neighbours.
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+const rankBase = len(rankDigits)
+
+func rankDigit(rank string, i int) int {
+	if i >= len(rank) {
+		return 0
+	}
+	return strings.IndexByte(rankDigits, rank[i])
+}
+
+// RankBetween returns a rank sorting after prev and before next, an empty prev
+// means the start of the list and an empty next means the end of it.
+func RankBetween(prev string, next string) string {
+	if next != "" {
+		// keep the common prefix and find a rank between the remainders
+		n := 0
+		for n < len(next) && rankDigit(prev, n) == rankDigit(next, n) {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(prev) {
+				rest = prev[n:]
+			}
+			return next[:n] + RankBetween(rest, next[n:])
+		}
+	}
+
+	prevDigit := rankDigit(prev, 0)
+	nextDigit := rankBase
+	if next != "" {
+		nextDigit = rankDigit(next, 0)
+	}
+
+	if nextDigit-prevDigit > 1 {
+		return string(rankDigits[(prevDigit+nextDigit)/2])
+	}
+
+	// the first digits are adjacent
+	if len(next) > 1 {
+		return next[:1]
+	}
+	rest := ""
+	if len(prev) > 1 {
+		rest = prev[1:]
+	}
+	return string(rankDigits[prevDigit]) + RankBetween(rest, "")
+}
+
+// EvenRanks returns count increasing ranks spread evenly, used to rebalance a list
+// when concurrent reorders left two items with the same rank.
+func EvenRanks(count int) []string {
+	width, capacity := 1, rankBase
+	for capacity <= count {
+		width++
+		capacity *= rankBase
+	}
+	step := capacity / (count + 1)
+
+	ranks := make([]string, count)
+	for i := range ranks {
+		value := (i + 1) * step
+		digits := make([]byte, width)
+		for d := width - 1; d >= 0; d-- {
+			digits[d] = rankDigits[value%rankBase]
+			value /= rankBase
+		}
+		ranks[i] = strings.TrimRight(string(digits), "0")
+	}
+	return ranks
+}
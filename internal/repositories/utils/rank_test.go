@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func checkRankBetween(t *testing.T, prev string, next string) string {
+	t.Helper()
+	rank := RankBetween(prev, next)
+	if rank <= prev || (next != "" && rank >= next) {
+		t.Fatalf("RankBetween(%q, %q) = %q, want a rank between them", prev, next, rank)
+	}
+	if strings.HasSuffix(rank, "0") {
+		t.Fatalf("RankBetween(%q, %q) = %q, ranks can't end with a zero", prev, next, rank)
+	}
+	return rank
+}
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		prev string
+		next string
+		want string
+	}{
+		{"", "", "i"},
+		{"i", "", "r"},
+		{"", "i", "9"},
+		{"a", "c", "b"},
+		{"a", "b", "ai"},
+		{"", "1", "0i"},
+		{"az", "b", "azi"},
+		{"ai", "aj", "aii"},
+		{"a1", "a3", "a2"},
+	}
+	for _, test := range tests {
+		if got := checkRankBetween(t, test.prev, test.next); got != test.want {
+			t.Errorf("RankBetween(%q, %q) = %q, want %q", test.prev, test.next, got, test.want)
+		}
+	}
+}
+
+func TestRankBetweenRepeatedInserts(t *testing.T) {
+	// inserting at the same spot again and again keeps finding room
+	first, last := "", ""
+	for i := 0; i < 200; i++ {
+		first = checkRankBetween(t, "", first)
+	}
+	for i := 0; i < 200; i++ {
+		last = checkRankBetween(t, last, "")
+	}
+
+	prev, next := "a", "b"
+	for i := 0; i < 200; i++ {
+		middle := checkRankBetween(t, prev, next)
+		if i%2 == 0 {
+			prev = middle
+		} else {
+			next = middle
+		}
+	}
+}
+
+func TestEvenRanks(t *testing.T) {
+	for _, count := range []int{0, 1, 2, 35, 36, 100, 2000} {
+		ranks := EvenRanks(count)
+		if len(ranks) != count {
+			t.Fatalf("EvenRanks(%d) returned %d ranks", count, len(ranks))
+		}
+		for i, rank := range ranks {
+			if rank == "" || strings.HasSuffix(rank, "0") {
+				t.Fatalf("EvenRanks(%d)[%d] = %q, want a rank without a trailing zero", count, i, rank)
+			}
+			if i > 0 && ranks[i-1] >= rank {
+				t.Fatalf("EvenRanks(%d) isn't increasing at %d: %q >= %q", count, i, ranks[i-1], rank)
+			}
+		}
+		// a rebalanced column still has room before, between and after its tasks
+		if count > 1 {
+			checkRankBetween(t, "", ranks[0])
+			checkRankBetween(t, ranks[0], ranks[1])
+			checkRankBetween(t, ranks[count-1], "")
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RRule is the supported subset of RFC 5545 recurrence rules:
+// FREQ, INTERVAL, COUNT, UNTIL, BYDAY (plain weekdays, with WEEKLY) and BYMONTHDAY (with MONTHLY).
+// weeks start on monday.
+type RRule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []time.Weekday
+	ByMonthDay []int
+}
+
+// ParseRRule parses a rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", an optional "RRULE:" prefix is allowed.
+func ParseRRule(rule string) (*RRule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return nil, errors.New("empty recurrence rule")
+	}
+
+	r := &RRule{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			switch r.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				return nil, fmt.Errorf("unsupported frequency %s", value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid interval %s", value)
+			}
+			r.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid count %s", value)
+			}
+			r.Count = count
+		case "UNTIL":
+			until, err := parseRRuleTime(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(value), ",") {
+				weekday, ok := rruleWeekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("unsupported weekday %s", day)
+				}
+				r.ByDay = append(r.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				monthDay, err := strconv.Atoi(day)
+				if err != nil || monthDay == 0 || monthDay < -31 || monthDay > 31 {
+					return nil, fmt.Errorf("invalid month day %s", day)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, monthDay)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part %s", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("recurrence rule requires FREQ")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, errors.New("COUNT and UNTIL can't be used together")
+	}
+	if len(r.ByDay) > 0 && r.Freq != Weekly {
+		return nil, errors.New("BYDAY is only supported with FREQ=WEEKLY")
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq != Monthly {
+		return nil, errors.New("BYMONTHDAY is only supported with FREQ=MONTHLY")
+	}
+	return r, nil
+}
+
+func parseRRuleTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %s", value)
+}
+
+// Next returns the occurrence following prev, index being the 1-based position of prev in the series.
+// the second value is false once the series is over.
+func (r *RRule) Next(prev time.Time, index int) (time.Time, bool) {
+	if r.Count > 0 && index >= r.Count {
+		return time.Time{}, false
+	}
+
+	var next time.Time
+	switch r.Freq {
+	case Daily:
+		next = prev.AddDate(0, 0, r.Interval)
+	case Weekly:
+		next = r.nextWeekly(prev)
+	case Monthly:
+		next = r.nextMonthly(prev)
+	case Yearly:
+		next = r.nextYearly(prev)
+	}
+
+	if next.IsZero() || (!r.Until.IsZero() && next.After(r.Until)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+func (r *RRule) nextWeekly(prev time.Time) time.Time {
+	if len(r.ByDay) == 0 {
+		return prev.AddDate(0, 0, 7*r.Interval)
+	}
+
+	// days since monday of each requested weekday
+	offsets := make([]int, 0, len(r.ByDay))
+	for _, day := range r.ByDay {
+		offsets = append(offsets, (int(day)+6)%7)
+	}
+	sort.Ints(offsets)
+
+	current := (int(prev.Weekday()) + 6) % 7
+	for _, offset := range offsets {
+		if offset > current {
+			return prev.AddDate(0, 0, offset-current)
+		}
+	}
+	weekStart := prev.AddDate(0, 0, -current)
+	return weekStart.AddDate(0, 0, 7*r.Interval+offsets[0])
+}
+
+func (r *RRule) nextMonthly(prev time.Time) time.Time {
+	monthDays := r.ByMonthDay
+	if len(monthDays) == 0 {
+		monthDays = []int{prev.Day()}
+	}
+
+	// months without a matching day are skipped, as RFC 5545 does for invalid dates
+	for step := 0; step <= 12*r.Interval*4; step += r.Interval {
+		year, month := prev.Year(), prev.Month()+time.Month(step)
+		daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, prev.Location()).Day()
+
+		var days []int
+		for _, day := range monthDays {
+			if day < 0 {
+				day = daysInMonth + day + 1
+			}
+			if day >= 1 && day <= daysInMonth {
+				days = append(days, day)
+			}
+		}
+		sort.Ints(days)
+
+		for _, day := range days {
+			candidate := time.Date(year, month, day, prev.Hour(), prev.Minute(), prev.Second(), prev.Nanosecond(), prev.Location())
+			if candidate.After(prev) {
+				return candidate
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (r *RRule) nextYearly(prev time.Time) time.Time {
+	// february 29th only exists in leap years
+	for step := r.Interval; step <= r.Interval*8; step += r.Interval {
+		candidate := time.Date(prev.Year()+step, prev.Month(), prev.Day(), prev.Hour(), prev.Minute(), prev.Second(), prev.Nanosecond(), prev.Location())
+		if candidate.Day() == prev.Day() {
+			return candidate
+		}
+	}
+	return time.Time{}
+}
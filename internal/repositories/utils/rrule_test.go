@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRRule(t *testing.T) {
+	rule, err := ParseRRule("RRULE:FREQ=weekly;INTERVAL=2;BYDAY=MO,th")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &RRule{Freq: Weekly, Interval: 2, ByDay: []time.Weekday{time.Monday, time.Thursday}}
+	if !reflect.DeepEqual(rule, want) {
+		t.Fatalf("ParseRRule = %+v, want %+v", rule, want)
+	}
+
+	rule, err = ParseRRule("FREQ=MONTHLY;BYMONTHDAY=1,-1;UNTIL=20241231")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &RRule{Freq: Monthly, Interval: 1, ByMonthDay: []int{1, -1}, Until: time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)}
+	if !reflect.DeepEqual(rule, want) {
+		t.Fatalf("ParseRRule = %+v, want %+v", rule, want)
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	rules := []string{
+		"",
+		"RRULE:",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;COUNT=3;UNTIL=20240101",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=DAILY;COUNT",
+	}
+	for _, rule := range rules {
+		if _, err := ParseRRule(rule); err == nil {
+			t.Errorf("ParseRRule(%q) succeeded, want an error", rule)
+		}
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+}
+
+func TestRRuleNext(t *testing.T) {
+	tests := []struct {
+		rule  string
+		prev  time.Time
+		index int
+		want  time.Time
+	}{
+		{"FREQ=DAILY;INTERVAL=3", date(2024, 2, 27), 1, date(2024, 3, 1)},
+		{"FREQ=WEEKLY", date(2024, 1, 3), 1, date(2024, 1, 10)},
+		// thursday 2024-01-04 to the monday two weeks later
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", date(2024, 1, 4), 1, date(2024, 1, 15)},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", date(2024, 1, 15), 2, date(2024, 1, 18)},
+		{"FREQ=WEEKLY;BYDAY=SU", date(2024, 1, 7), 1, date(2024, 1, 14)},
+		// months without a 31st are skipped
+		{"FREQ=MONTHLY;BYMONTHDAY=31", date(2024, 1, 31), 1, date(2024, 3, 31)},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", date(2024, 1, 31), 1, date(2024, 2, 29)},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,15", date(2024, 1, 1), 1, date(2024, 1, 15)},
+		{"FREQ=MONTHLY;INTERVAL=2", date(2024, 11, 20), 1, date(2025, 1, 20)},
+		{"FREQ=YEARLY", date(2024, 2, 29), 1, date(2028, 2, 29)},
+		{"FREQ=DAILY;COUNT=3", date(2024, 1, 1), 2, date(2024, 1, 2)},
+		{"FREQ=DAILY;UNTIL=20240102T093000Z", date(2024, 1, 1), 1, date(2024, 1, 2)},
+	}
+	for _, test := range tests {
+		rule, err := ParseRRule(test.rule)
+		if err != nil {
+			t.Fatalf("ParseRRule(%q): %v", test.rule, err)
+		}
+		got, ok := rule.Next(test.prev, test.index)
+		if !ok || !got.Equal(test.want) {
+			t.Errorf("%s: Next(%s, %d) = %s, %v, want %s", test.rule, test.prev, test.index, got, ok, test.want)
+		}
+	}
+}
+
+func TestRRuleNextEndsSeries(t *testing.T) {
+	tests := []struct {
+		rule  string
+		prev  time.Time
+		index int
+	}{
+		{"FREQ=DAILY;COUNT=3", date(2024, 1, 3), 3},
+		{"FREQ=DAILY;UNTIL=20240102", date(2024, 1, 1), 1},
+		{"FREQ=WEEKLY;UNTIL=20240105", date(2024, 1, 1), 1},
+	}
+	for _, test := range tests {
+		rule, err := ParseRRule(test.rule)
+		if err != nil {
+			t.Fatalf("ParseRRule(%q): %v", test.rule, err)
+		}
+		if got, ok := rule.Next(test.prev, test.index); ok {
+			t.Errorf("%s: Next(%s, %d) = %s, want the series to be over", test.rule, test.prev, test.index, got)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type ViewRepository interface {
+	// GetViews returns the user's own views and the views shared with their teams
+	GetViews(requestUserID uint) ([]models.SavedView, error)
+	GetView(viewID uint, requestUserID uint) (*models.SavedView, error)
+	CreateView(view *models.SavedView, requestUserID uint) (uint, error)
+	UpdateView(view *models.SavedView, requestUserID uint) (uint, error)
+	DeleteView(viewID uint, requestUserID uint) (uint, error)
+}
+
+type viewRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+}
+
+func NewViewRepository(postgreSql *database.DatabaseHandler) ViewRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &viewRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+// validateView checks the scope of a view, a shared project view must belong to a project of the team
+func (vr *viewRepository) validateView(view *models.SavedView, requestUserID uint) error {
+	view.Name = strings.TrimSpace(view.Name)
+	if view.Name == "" {
+		return errors.New("view name is required")
+	}
+	view.Query = strings.TrimPrefix(strings.TrimSpace(view.Query), "?")
+
+	if view.ProjectID != nil && !vr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.ProjectResource(*view.ProjectID)) {
+		return errors.New("you don't have access to the project")
+	}
+	if view.TeamID == nil {
+		return nil
+	}
+	if !vr.permissionRepo.Authorize(requestUserID, models.TeamView, utils.TeamResource(*view.TeamID)) {
+		return errors.New("you can only share views with your own teams")
+	}
+	if view.ProjectID != nil {
+		var project models.Project
+		if err := vr.DB.Select("id", "team_id").First(&project, *view.ProjectID).Error; err != nil {
+			return fmt.Errorf("project %d not found", *view.ProjectID)
+		}
+		if project.TeamID != *view.TeamID {
+			return fmt.Errorf("project %d doesn't belong to team %d", project.ID, *view.TeamID)
+		}
+	}
+	return nil
+}
+
+func (vr *viewRepository) GetViews(requestUserID uint) ([]models.SavedView, error) {
+	views := []models.SavedView{}
+	err := vr.DB.
+		Where("owner_id = ? OR team_id IN (SELECT team_id FROM team_members WHERE user_id = ?)", requestUserID, requestUserID).
+		Order("name, id").
+		Find(&views).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get views: %w", err)
+	}
+	return views, nil
+}
+
+func (vr *viewRepository) loadView(viewID uint) (*models.SavedView, error) {
+	var view models.SavedView
+	if err := vr.DB.First(&view, viewID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("view %d not found", viewID)
+		}
+		return nil, err
+	}
+	return &view, nil
+}
+
+func (vr *viewRepository) GetView(viewID uint, requestUserID uint) (*models.SavedView, error) {
+	view, err := vr.loadView(viewID)
+	if err != nil {
+		return nil, err
+	}
+	if view.OwnerID != requestUserID &&
+		(view.TeamID == nil || !vr.permissionRepo.Authorize(requestUserID, models.TeamView, utils.TeamResource(*view.TeamID))) {
+		return nil, errors.New("you don't have access to this view")
+	}
+	return view, nil
+}
+
+func (vr *viewRepository) CreateView(view *models.SavedView, requestUserID uint) (uint, error) {
+	if err := vr.validateView(view, requestUserID); err != nil {
+		return 0, err
+	}
+
+	view.ID = 0
+	view.OwnerID = requestUserID
+	if err := vr.DB.Create(view).Error; err != nil {
+		return 0, fmt.Errorf("failed to create view: %w", err)
+	}
+	return view.ID, nil
+}
+
+// ownView loads a view of the user, shared views are only changed by their owner
+func (vr *viewRepository) ownView(viewID uint, requestUserID uint) (*models.SavedView, error) {
+	view, err := vr.loadView(viewID)
+	if err != nil {
+		return nil, err
+	}
+	if view.OwnerID != requestUserID {
+		return nil, errors.New("you can only change your own views")
+	}
+	return view, nil
+}
+
+func (vr *viewRepository) UpdateView(view *models.SavedView, requestUserID uint) (uint, error) {
+	existingView, err := vr.ownView(view.ID, requestUserID)
+	if err != nil {
+		return 0, err
+	}
+	if err := vr.validateView(view, requestUserID); err != nil {
+		return 0, err
+	}
+
+	err = vr.DB.Model(existingView).Select("name", "query", "team_id", "project_id").Updates(&models.SavedView{
+		Name:      view.Name,
+		Query:     view.Query,
+		TeamID:    view.TeamID,
+		ProjectID: view.ProjectID,
+	}).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to update view %d: %w", view.ID, err)
+	}
+	return existingView.ID, nil
+}
+
+func (vr *viewRepository) DeleteView(viewID uint, requestUserID uint) (uint, error) {
+	view, err := vr.ownView(viewID, requestUserID)
+	if err != nil {
+		return 0, err
+	}
+	if err := vr.DB.Delete(view).Error; err != nil {
+		return 0, fmt.Errorf("failed to delete view %d: %w", viewID, err)
+	}
+	return view.ID, nil
+}
@@ -0,0 +1,584 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WorkflowDetail struct {
+	Statuses    []models.WorkflowStatus     `json:"statuses"`
+	Transitions []models.WorkflowTransition `json:"transitions"`
+}
+
+type BoardColumn struct {
+	Status models.WorkflowStatus `json:"status"`
+	Tasks  []models.Task         `json:"tasks"`
+}
+
+type WorkflowRepository interface {
+	GetWorkflow(projectID uint, requestUserID uint) (*WorkflowDetail, error)
+	CreateStatus(projectID uint, status *models.WorkflowStatus, requestUserID uint) (uint, error)
+	UpdateStatus(projectID uint, statusID uint, patch *StatusPatch, requestUserID uint) (uint, error)
+	DeleteStatus(projectID uint, statusID uint, requestUserID uint) (uint, error)
+	// SetTransitions replaces the allowed transitions of the project, an empty list allows every move
+	SetTransitions(projectID uint, transitions []models.WorkflowTransition, requestUserID uint) error
+	GetBoard(projectID uint, filter TaskFilter, requestUserID uint) ([]BoardColumn, error)
+	// MoveTask moves a task into a status, between the tasks afterTaskID and beforeTaskID of that column
+	// when given, at the end of the column otherwise
+	MoveTask(taskID uint, statusID uint, afterTaskID uint, beforeTaskID uint, requestUserID uint) (*models.Task, error)
+	GetTaskMoves(taskID uint, requestUserID uint) ([]models.TaskMove, error)
+	// WithRequest returns a copy of the repository that records its activities for the request
+	WithRequest(request RequestInfo) WorkflowRepository
+}
+
+type workflowRepository struct {
+	permissionRepo utils.PermissionRepository
+	DB             *gorm.DB
+	request        RequestInfo
+}
+
+func NewWorkflowRepository(postgreSql *database.DatabaseHandler) WorkflowRepository {
+	permissionRepo := utils.NewPermissionRepository(postgreSql)
+	return &workflowRepository{DB: postgreSql.DB, permissionRepo: permissionRepo}
+}
+
+func (wr *workflowRepository) WithRequest(request RequestInfo) WorkflowRepository {
+	scoped := *wr
+	scoped.request = request
+	return &scoped
+}
+
+// createWorkflow gives a new project the default board columns, older projects got them from the migration
+func createWorkflow(tx *gorm.DB, projectID uint) error {
+	var statuses []models.WorkflowStatus
+	for _, status := range models.DefaultWorkflow {
+		status.ProjectID = projectID
+		statuses = append(statuses, status)
+	}
+	if err := tx.Create(&statuses).Error; err != nil {
+		return fmt.Errorf("failed to create workflow of project %d: %w", projectID, err)
+	}
+	return nil
+}
+
+// projectWorkflow returns the ordered statuses of a project, every project keeps at least one
+func projectWorkflow(tx *gorm.DB, projectID uint) ([]models.WorkflowStatus, error) {
+	var statuses []models.WorkflowStatus
+	if err := tx.Where("project_id = ?", projectID).Order("position, id").Find(&statuses).Error; err != nil {
+		return nil, fmt.Errorf("failed to get workflow of project %d: %w", projectID, err)
+	}
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("project %d has no workflow", projectID)
+	}
+	return statuses, nil
+}
+
+func containsStatus(statuses []models.WorkflowStatus, statusID uint) bool {
+	for _, status := range statuses {
+		if status.ID == statusID {
+			return true
+		}
+	}
+	return false
+}
+
+// lastRankInStatus returns the rank of the last task of a column, excluding the given task
+func lastRankInStatus(tx *gorm.DB, statusID uint, excludeTaskID uint) (string, error) {
+	var ranks []string
+	err := tx.Model(&models.Task{}).
+		Where("status_id = ? AND id <> ?", statusID, excludeTaskID).
+		Order("rank DESC").
+		Limit(1).
+		Pluck("rank", &ranks).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to get column ranks: %w", err)
+	}
+	if len(ranks) == 0 {
+		return "", nil
+	}
+	return ranks[0], nil
+}
+
+func (wr *workflowRepository) GetWorkflow(projectID uint, requestUserID uint) (*WorkflowDetail, error) {
+	if !wr.permissionRepo.Authorize(requestUserID, models.ProjectView, utils.ProjectResource(projectID)) {
+		return nil, errors.New("you don't have access to the project")
+	}
+
+	statuses, err := projectWorkflow(wr.DB, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var transitions []models.WorkflowTransition
+	if err := wr.DB.Where("project_id = ?", projectID).Find(&transitions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get transitions of project %d: %w", projectID, err)
+	}
+
+	return &WorkflowDetail{Statuses: statuses, Transitions: transitions}, nil
+}
+
+func (wr *workflowRepository) CreateStatus(projectID uint, status *models.WorkflowStatus, requestUserID uint) (uint, error) {
+	if !wr.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(projectID)) {
+		return 0, errors.New("you don't have permission to change the project workflow")
+	}
+	if wr.permissionRepo.CheckArchived(utils.ProjectResource(projectID)) {
+		return 0, ErrArchived
+	}
+	if status.Name == "" {
+		return 0, errors.New("status name is required")
+	}
+
+	status.ID = 0
+	status.ProjectID = projectID
+	if err := wr.DB.Create(status).Error; err != nil {
+		return 0, fmt.Errorf("failed to create status: %w", err)
+	}
+	return status.ID, nil
+}
+
+func (wr *workflowRepository) UpdateStatus(projectID uint, statusID uint, patch *StatusPatch, requestUserID uint) (uint, error) {
+	if !wr.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(projectID)) {
+		return 0, errors.New("you don't have permission to change the project workflow")
+	}
+	if wr.permissionRepo.CheckArchived(utils.ProjectResource(projectID)) {
+		return 0, ErrArchived
+	}
+
+	if patch.Name != nil && *patch.Name == "" {
+		return 0, errors.New("status name can't be empty")
+	}
+
+	var existingStatus models.WorkflowStatus
+	if err := wr.DB.Where("id = ? AND project_id = ?", statusID, projectID).First(&existingStatus).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("status %d not found", statusID)
+		}
+		return 0, err
+	}
+
+	changes := patch.changes()
+	if len(changes) == 0 {
+		return existingStatus.ID, nil
+	}
+	doneChanged := patch.IsDone != nil && *patch.IsDone != existingStatus.IsDone
+	err := wr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existingStatus).Updates(changes).Error; err != nil {
+			return err
+		}
+		if !doneChanged {
+			return nil
+		}
+
+		// the tasks of the status are finished or reopened with it
+		var tasks []models.Task
+		if err := tx.Select("id", "project_id").Where("status_id = ?", existingStatus.ID).Find(&tasks).Error; err != nil {
+			return err
+		}
+		events := make([]models.TaskEvent, 0, len(tasks))
+		for _, task := range tasks {
+			if err := bumpVersion(tx, &models.Task{ID: task.ID, ProjectID: task.ProjectID}); err != nil {
+				return err
+			}
+			events = append(events, models.TaskEvent{
+				TaskID:    task.ID,
+				ProjectID: task.ProjectID,
+				Type:      models.TaskMoved,
+				StatusID:  &existingStatus.ID,
+				Done:      *patch.IsDone,
+				ActorID:   requestUserID,
+			})
+		}
+		return recordTaskEvents(tx, events)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to update status %d: %w", statusID, err)
+	}
+	return existingStatus.ID, nil
+}
+
+func (wr *workflowRepository) DeleteStatus(projectID uint, statusID uint, requestUserID uint) (uint, error) {
+	if !wr.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(projectID)) {
+		return 0, errors.New("you don't have permission to change the project workflow")
+	}
+	if wr.permissionRepo.CheckArchived(utils.ProjectResource(projectID)) {
+		return 0, ErrArchived
+	}
+
+	var status models.WorkflowStatus
+	if err := wr.DB.Where("id = ? AND project_id = ?", statusID, projectID).First(&status).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("status %d not found", statusID)
+		}
+		return 0, err
+	}
+
+	var taskCount int64
+	if err := wr.DB.Model(&models.Task{}).Where("status_id = ?", statusID).Count(&taskCount).Error; err != nil {
+		return 0, err
+	}
+	if taskCount > 0 {
+		return 0, fmt.Errorf("status %s still has %d tasks", status.Name, taskCount)
+	}
+
+	tx := wr.DB.Begin()
+	// the project row serializes deletes so the last status can't go
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Project{}, projectID).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	var statusCount int64
+	if err := tx.Model(&models.WorkflowStatus{}).Where("project_id = ?", projectID).Count(&statusCount).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if statusCount <= 1 {
+		tx.Rollback()
+		return 0, errors.New("a project needs at least one status")
+	}
+	if err := tx.Where("from_status_id = ? OR to_status_id = ?", statusID, statusID).Delete(&models.WorkflowTransition{}).Error; err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to delete transitions of status %d: %w", statusID, err)
+	}
+	if err := tx.Delete(&status).Error; err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to delete status %d: %w", statusID, err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return status.ID, nil
+}
+
+func (wr *workflowRepository) SetTransitions(projectID uint, transitions []models.WorkflowTransition, requestUserID uint) error {
+	if !wr.permissionRepo.Authorize(requestUserID, models.ProjectUpdate, utils.ProjectResource(projectID)) {
+		return errors.New("you don't have permission to change the project workflow")
+	}
+	if wr.permissionRepo.CheckArchived(utils.ProjectResource(projectID)) {
+		return ErrArchived
+	}
+
+	statuses, err := projectWorkflow(wr.DB, projectID)
+	if err != nil {
+		return err
+	}
+	projectStatuses := make(map[uint]bool)
+	for _, status := range statuses {
+		projectStatuses[status.ID] = true
+	}
+	for i := range transitions {
+		if !projectStatuses[transitions[i].FromStatusID] || !projectStatuses[transitions[i].ToStatusID] {
+			return fmt.Errorf("transition %d -> %d uses a status outside of the project", transitions[i].FromStatusID, transitions[i].ToStatusID)
+		}
+		transitions[i].ProjectID = projectID
+	}
+
+	tx := wr.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Where("project_id = ?", projectID).Delete(&models.WorkflowTransition{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to replace transitions: %w", err)
+	}
+	if len(transitions) > 0 {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&transitions).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to replace transitions: %w", err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+func (wr *workflowRepository) GetBoard(projectID uint, filter TaskFilter, requestUserID uint) ([]BoardColumn, error) {
+	if !wr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.ProjectResource(projectID)) {
+		return nil, errors.New("you don't have access to the project")
+	}
+
+	statuses, err := projectWorkflow(wr.DB, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := applyTaskFilter(wr.DB, wr.DB.Where("tasks.project_id = ?", projectID), projectID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []models.Task
+	if err := query.Preload("Members").Preload("Labels").Order("rank, id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	columns := make([]BoardColumn, len(statuses))
+	columnIndex := make(map[uint]int)
+	for i, status := range statuses {
+		columns[i] = BoardColumn{Status: status, Tasks: []models.Task{}}
+		columnIndex[status.ID] = i
+	}
+	for _, task := range tasks {
+		// tasks created before the project had a workflow show up in the first column
+		i := 0
+		if task.StatusID != nil {
+			if index, ok := columnIndex[*task.StatusID]; ok {
+				i = index
+			}
+		}
+		columns[i].Tasks = append(columns[i].Tasks, task)
+	}
+
+	return columns, nil
+}
+
+func (wr *workflowRepository) MoveTask(taskID uint, statusID uint, afterTaskID uint, beforeTaskID uint, requestUserID uint) (*models.Task, error) {
+	if !wr.permissionRepo.Authorize(requestUserID, models.TaskUpdate, utils.TaskResource(taskID)) {
+		return nil, errors.New("user does not have permission to move this task")
+	}
+	if wr.permissionRepo.CheckArchived(utils.TaskResource(taskID)) {
+		return nil, ErrArchived
+	}
+
+	var task *models.Task
+	err := wr.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		task, err = moveTask(tx, wr.request, taskID, statusID, afterTaskID, beforeTaskID, requestUserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// moveTask places a task in a column of its project between two neighbours, at the end of the
+// column when both are 0, and records the move when the status changed
+func moveTask(tx *gorm.DB, request RequestInfo, taskID uint, statusID uint, afterTaskID uint, beforeTaskID uint, requestUserID uint) (*models.Task, error) {
+	// the row lock serializes concurrent moves of the same task
+	var task models.Task
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&task, taskID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("task not found")
+		}
+		return nil, err
+	}
+
+	statuses, err := projectWorkflow(tx, task.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	var target *models.WorkflowStatus
+	for i := range statuses {
+		if statuses[i].ID == statusID {
+			target = &statuses[i]
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("status %d doesn't belong to the task's project", statusID)
+	}
+
+	// a task can't be finished while one of its blockers isn't
+	if target.IsDone {
+		blockerIDs, err := unfinishedBlockers(tx, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(blockerIDs) > 0 {
+			return nil, fmt.Errorf("task %d is blocked by unfinished tasks %v", taskID, blockerIDs)
+		}
+	}
+
+	statusChanged := task.StatusID == nil || *task.StatusID != statusID
+	if statusChanged && task.StatusID != nil {
+		if err := checkTransition(tx, task.ProjectID, *task.StatusID, statusID); err != nil {
+			return nil, err
+		}
+	}
+
+	rank, err := rankForMove(tx, task.ID, statusID, afterTaskID, beforeTaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"status_id": statusID, "rank": rank, "version": gorm.Expr("version + 1")}
+	if err := tx.Model(&task).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to move task %d: %w", taskID, err)
+	}
+
+	if statusChanged {
+		move := models.TaskMove{
+			TaskID:       task.ID,
+			FromStatusID: task.StatusID,
+			ToStatusID:   statusID,
+			MovedBy:      requestUserID,
+			MovedAt:      time.Now(),
+		}
+		if err := tx.Create(&move).Error; err != nil {
+			return nil, fmt.Errorf("failed to record move of task %d: %w", taskID, err)
+		}
+		moved := models.TaskEvent{TaskID: task.ID, ProjectID: task.ProjectID, Type: models.TaskMoved, StatusID: &statusID, Done: target.IsDone, ActorID: requestUserID}
+		if err := recordTaskEvents(tx, []models.TaskEvent{moved}); err != nil {
+			return nil, err
+		}
+	}
+
+	before := task
+	task.StatusID = &statusID
+	task.Rank = rank
+	task.Version++
+	activity := models.Activity{
+		ActorID:    requestUserID,
+		Action:     models.ActivityUpdated,
+		TargetType: models.TaskTarget,
+		TargetID:   task.ID,
+		ProjectID:  &task.ProjectID,
+		Changes:    activityChanges(&before, &task),
+	}
+	if err := recordActivity(tx, request, activity); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func checkTransition(tx *gorm.DB, projectID uint, fromStatusID uint, toStatusID uint) error {
+	var transitionCount int64
+	if err := tx.Model(&models.WorkflowTransition{}).Where("project_id = ?", projectID).Count(&transitionCount).Error; err != nil {
+		return err
+	}
+	if transitionCount == 0 {
+		return nil
+	}
+
+	var allowed int64
+	err := tx.Model(&models.WorkflowTransition{}).
+		Where("from_status_id = ? AND to_status_id = ?", fromStatusID, toStatusID).
+		Count(&allowed).Error
+	if err != nil {
+		return err
+	}
+	if allowed == 0 {
+		return fmt.Errorf("moving tasks from status %d to status %d is not allowed", fromStatusID, toStatusID)
+	}
+	return nil
+}
+
+// rankForMove computes the rank placing a task between two neighbours of a column.
+// only the moved task gets a new rank, so concurrent reorders of other tasks don't conflict,
+// when two tasks ended up with the same rank the column is rebalanced first.
+func rankForMove(tx *gorm.DB, taskID uint, statusID uint, afterTaskID uint, beforeTaskID uint) (string, error) {
+	prev, next, err := neighbourRanks(tx, taskID, statusID, afterTaskID, beforeTaskID)
+	if err != nil {
+		return "", err
+	}
+	if next == "" || prev < next {
+		return utils.RankBetween(prev, next), nil
+	}
+
+	if err := rebalanceColumn(tx, statusID, taskID); err != nil {
+		return "", err
+	}
+	prev, next, err = neighbourRanks(tx, taskID, statusID, afterTaskID, beforeTaskID)
+	if err != nil {
+		return "", err
+	}
+	return utils.RankBetween(prev, next), nil
+}
+
+func neighbourRanks(tx *gorm.DB, taskID uint, statusID uint, afterTaskID uint, beforeTaskID uint) (string, string, error) {
+	neighbourRank := func(neighbourID uint) (string, error) {
+		var neighbour models.Task
+		if err := tx.Select("id", "status_id", "rank").First(&neighbour, neighbourID).Error; err != nil {
+			return "", fmt.Errorf("task %d not found", neighbourID)
+		}
+		if neighbour.ID == taskID || neighbour.StatusID == nil || *neighbour.StatusID != statusID {
+			return "", fmt.Errorf("task %d is not in the target column", neighbourID)
+		}
+		return neighbour.Rank, nil
+	}
+
+	var prev, next string
+	var err error
+	switch {
+	case afterTaskID != 0 && beforeTaskID != 0:
+		if prev, err = neighbourRank(afterTaskID); err != nil {
+			return "", "", err
+		}
+		if next, err = neighbourRank(beforeTaskID); err != nil {
+			return "", "", err
+		}
+	case afterTaskID != 0:
+		if prev, err = neighbourRank(afterTaskID); err != nil {
+			return "", "", err
+		}
+		var ranks []string
+		if err := tx.Model(&models.Task{}).
+			Where("status_id = ? AND id <> ? AND rank > ?", statusID, taskID, prev).
+			Order("rank").Limit(1).Pluck("rank", &ranks).Error; err != nil {
+			return "", "", err
+		}
+		if len(ranks) > 0 {
+			next = ranks[0]
+		}
+	case beforeTaskID != 0:
+		if next, err = neighbourRank(beforeTaskID); err != nil {
+			return "", "", err
+		}
+		var ranks []string
+		if err := tx.Model(&models.Task{}).
+			Where("status_id = ? AND id <> ? AND rank < ?", statusID, taskID, next).
+			Order("rank DESC").Limit(1).Pluck("rank", &ranks).Error; err != nil {
+			return "", "", err
+		}
+		if len(ranks) > 0 {
+			prev = ranks[0]
+		}
+	default:
+		if prev, err = lastRankInStatus(tx, statusID, taskID); err != nil {
+			return "", "", err
+		}
+	}
+	return prev, next, nil
+}
+
+func rebalanceColumn(tx *gorm.DB, statusID uint, excludeTaskID uint) error {
+	var tasks []models.Task
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "project_id", "rank").
+		Where("status_id = ? AND id <> ?", statusID, excludeTaskID).
+		Order("rank, id").
+		Find(&tasks).Error
+	if err != nil {
+		return fmt.Errorf("failed to rebalance column: %w", err)
+	}
+
+	ranks := utils.EvenRanks(len(tasks))
+	for i, task := range tasks {
+		updates := map[string]interface{}{"rank": ranks[i], "version": gorm.Expr("version + 1")}
+		if err := tx.Model(&models.Task{ID: task.ID, ProjectID: task.ProjectID}).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to rebalance column: %w", err)
+		}
+	}
+	return nil
+}
+
+func (wr *workflowRepository) GetTaskMoves(taskID uint, requestUserID uint) ([]models.TaskMove, error) {
+	if !wr.permissionRepo.Authorize(requestUserID, models.TaskView, utils.TaskResource(taskID)) {
+		return nil, errors.New("you don't have access to the task")
+	}
+
+	var moves []models.TaskMove
+	if err := wr.DB.Where("task_id = ?", taskID).Order("moved_at").Find(&moves).Error; err != nil {
+		return nil, err
+	}
+	return moves, nil
+}
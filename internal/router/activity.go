@@ -0,0 +1,16 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitActivity(r *Router, postgreSql *database.DatabaseHandler) {
+	aHandler := handlers.NewActivityHandler(postgreSql)
+
+	r.App.Get("/projects/:project_id/activity", aHandler.GetProjectActivity)
+	r.App.Get("/teams/:id/activity", aHandler.GetTeamActivity)
+
+	adminApp := r.App.Group("/api/admin")
+	adminApp.Get("/audit", aHandler.GetAuditLog)
+}
@@ -0,0 +1,16 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitAnalytics(r *Router, postgreSql *database.DatabaseHandler) {
+	aHandler := handlers.NewAnalyticsHandler(postgreSql)
+
+	analyticsApp := r.App.Group("/projects/:project_id/analytics")
+	analyticsApp.Get("/burndown", aHandler.GetBurndown)
+	analyticsApp.Get("/throughput", aHandler.GetThroughput)
+	analyticsApp.Get("/cycle-time", aHandler.GetCycleTimes)
+	analyticsApp.Get("/workload", aHandler.GetWorkload)
+}
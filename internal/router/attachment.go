@@ -0,0 +1,26 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/env"
+	"mizito/internal/handlers"
+	"mizito/internal/storage"
+)
+
+func InitAttachment(r *Router, postgreSql *database.DatabaseHandler, store storage.BlobStore, env *env.Config) {
+	aHandler := handlers.NewAttachmentHandler(postgreSql, store, env)
+
+	r.App.Post("/attachments", aHandler.UploadAttachment)
+	r.App.Get("/attachments", aHandler.GetOwnerAttachments)
+
+	attachmentApp := r.App.Group("/attachments/:attachment_id")
+	attachmentApp.Get("", aHandler.GetAttachment)
+	attachmentApp.Get("/content", aHandler.GetAttachmentContent)
+	attachmentApp.Get("/url", aHandler.GetSignedURL)
+	attachmentApp.Delete("", aHandler.DeleteAttachment)
+
+	r.App.Get("/tasks/:task_id/attachments", aHandler.GetTaskAttachments)
+
+	// signed links carry their own authorization, the auth middleware skips /files
+	r.App.Get("/files/:attachment_id", aHandler.GetSignedContent)
+}
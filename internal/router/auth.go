@@ -3,17 +3,35 @@ package router
 import (
 	"mizito/internal/database"
 	"mizito/internal/handlers"
+	"mizito/internal/mailer"
+	"mizito/internal/repositories"
 	basichandler "mizito/internal/repositories/auth/basic"
 	bearerhandler "mizito/internal/repositories/auth/bearer"
+	lockouthandler "mizito/internal/repositories/auth/lockout"
+	verificationhandler "mizito/internal/repositories/auth/verification"
+	"mizito/internal/repositories/utils"
 )
 
 func InitAuth(r *Router, secret string, redis *database.RedisHandler, db *database.DatabaseHandler) {
+	mail := mailer.NewMailer(r.Cfg)
 	jwtRepo := bearerhandler.NewJwtRepository(secret, redis)
 	basicRepo := basichandler.NewBasicHandler(db)
+	lockoutRepo := lockouthandler.NewLockoutRepository(redis, db, mail, r.Cfg)
+	verificationRepo := verificationhandler.NewVerificationRepository(secret, redis)
 
-	authHandler := handlers.NewAuthHandler(jwtRepo, basicRepo)
+	authHandler := handlers.NewAuthHandler(jwtRepo, basicRepo, lockoutRepo, utils.NewPermissionRepository(db))
+	accountHandler := handlers.NewAccountHandler(repositories.NewUserRepository(db), verificationRepo, jwtRepo, mail, r.Cfg)
 
 	authGroup := r.App.Group("/api/auth")
 	authGroup.Post("/login", authHandler.Login)
 	authGroup.Post("/refresh", authHandler.Refresh)
+	authGroup.Get("/unlock", authHandler.Unlock)
+	authGroup.Post("/password/forgot", accountHandler.ForgotPassword)
+	authGroup.Get("/password/reset", accountHandler.ResetPasswordForm)
+	authGroup.Post("/password/reset", accountHandler.ResetPassword)
+	authGroup.Post("/verify-email/request", accountHandler.RequestEmailVerification)
+	authGroup.Get("/verify-email", accountHandler.VerifyEmail)
+
+	adminGroup := r.App.Group("/api/admin")
+	adminGroup.Delete("/lockouts/:username", authHandler.AdminUnlock)
 }
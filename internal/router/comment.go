@@ -0,0 +1,18 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitComment(r *Router, redis *database.RedisHandler, postgreSql *database.DatabaseHandler) {
+	cHandler := handlers.NewCommentHandler(postgreSql, redis)
+
+	r.App.Get("/tasks/:task_id/comments", cHandler.GetTaskComments)
+	r.App.Post("/tasks/:task_id/comments", cHandler.CreateComment)
+
+	commentApp := r.App.Group("/comments/:comment_id")
+	commentApp.Put("", cHandler.UpdateComment)
+	commentApp.Delete("", cHandler.DeleteComment)
+	commentApp.Get("/history", cHandler.GetCommentHistory)
+}
@@ -3,11 +3,12 @@ package router
 import (
 	"mizito/internal/database"
 	"mizito/internal/handlers"
+	"time"
 )
 
-func InitDashboard(r *Router, postgreSql *database.DatabaseHandler) {
+func InitDashboard(r *Router, redis *database.RedisHandler, postgreSql *database.DatabaseHandler, cacheTTL time.Duration) {
 
-	pHandler := handlers.NewDashboardHandler(postgreSql)
+	pHandler := handlers.NewDashboardHandler(postgreSql, redis, cacheTTL)
 
 	projectsApp := r.App.Group("/dashboard")
 	projectsApp.Get("", pHandler.GetDashboardDetails)
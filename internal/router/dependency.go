@@ -0,0 +1,17 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitDependency(r *Router, postgreSql *database.DatabaseHandler) {
+	dHandler := handlers.NewDependencyHandler(postgreSql)
+
+	taskApp := r.App.Group("/tasks/:task_id")
+	taskApp.Get("/dependencies", dHandler.GetTaskDependencies)
+	taskApp.Post("/dependencies", dHandler.AddDependency)
+	taskApp.Delete("/dependencies/:blocker_id", dHandler.RemoveDependency)
+
+	r.App.Get("/projects/:project_id/dependencies", dHandler.GetDependencyGraph)
+}
@@ -0,0 +1,26 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitLabel(r *Router, postgreSql *database.DatabaseHandler) {
+	lHandler := handlers.NewLabelHandler(postgreSql)
+	fHandler := handlers.NewCustomFieldHandler(postgreSql)
+
+	projectApp := r.App.Group("/projects/:project_id")
+	projectApp.Get("/labels", lHandler.GetProjectLabels)
+	projectApp.Post("/labels", lHandler.CreateLabel)
+	projectApp.Put("/labels/:label_id", lHandler.UpdateLabel)
+	projectApp.Delete("/labels/:label_id", lHandler.DeleteLabel)
+	projectApp.Get("/fields", fHandler.GetProjectFields)
+	projectApp.Post("/fields", fHandler.CreateField)
+	projectApp.Put("/fields/:field_id", fHandler.UpdateField)
+	projectApp.Delete("/fields/:field_id", fHandler.DeleteField)
+
+	taskApp := r.App.Group("/tasks/:task_id")
+	taskApp.Put("/labels", lHandler.SetTaskLabels)
+	taskApp.Get("/fields", fHandler.GetTaskFieldValues)
+	taskApp.Put("/fields", fHandler.SetTaskFieldValues)
+}
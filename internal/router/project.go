@@ -13,7 +13,11 @@ func InitProject(r *Router, postgreSql *database.DatabaseHandler) {
 	projectsApp.Get("/all", pHandler.GetProjectsByUser)
 	projectsApp.Get("/:project_id", pHandler.GetProjectByID)
 	projectsApp.Put("/:project_id", pHandler.UpdateProject)
+	projectsApp.Patch("/:project_id", pHandler.UpdateProject)
 	projectsApp.Delete("/:project_id", pHandler.DeleteProject)
+	projectsApp.Post("/:project_id/restore", pHandler.RestoreProject)
+	projectsApp.Post("/:project_id/archive", pHandler.ArchiveProject)
+	projectsApp.Post("/:project_id/unarchive", pHandler.UnarchiveProject)
 	projectsApp.Get("/:project_id/users", pHandler.GetUsersByProjectID)
 	projectsApp.Put("/:project_id/users", pHandler.AddUserToProject)
 
@@ -0,0 +1,20 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitReport(r *Router, postgreSql *database.DatabaseHandler) {
+	rHandler := handlers.NewReportHandler(postgreSql)
+
+	r.App.Get("/tasks/:task_id/reports", rHandler.GetTaskReports)
+	r.App.Post("/tasks/:task_id/reports", rHandler.CreateReport)
+
+	reportApp := r.App.Group("/reports")
+	reportApp.Put("/:report_id", rHandler.UpdateReport)
+	reportApp.Delete("/:report_id", rHandler.DeleteReport)
+
+	r.App.Get("/projects/:project_id/standup", rHandler.GetProjectStandup)
+	r.App.Get("/projects/:project_id/reports/summary", rHandler.GetProjectReportSummary)
+}
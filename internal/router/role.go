@@ -0,0 +1,22 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitRole(r *Router, postgreSql *database.DatabaseHandler) {
+	rHandler := handlers.NewRoleHandler(postgreSql)
+
+	r.App.Get("/permissions", rHandler.GetPermissions)
+
+	teamRoles := r.App.Group("/teams/:id")
+	teamRoles.Get("/roles", rHandler.GetTeamRoles)
+	teamRoles.Post("/roles", rHandler.CreateRole)
+	teamRoles.Put("/roles/:role_id", rHandler.UpdateRole)
+	teamRoles.Delete("/roles/:role_id", rHandler.DeleteRole)
+	teamRoles.Put("/members/:user_id/role", rHandler.AssignTeamRole)
+
+	projectRoles := r.App.Group("/projects/:project_id")
+	projectRoles.Put("/users/:user_id/role", rHandler.AssignProjectRole)
+}
@@ -1,11 +1,17 @@
 package router
 
 import (
+	"fmt"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"mizito/internal/database"
 	"mizito/internal/env"
 	"mizito/internal/middleware"
+	"mizito/internal/repositories"
+	"mizito/internal/scheduler"
+	"mizito/internal/storage"
 )
 
 type Router struct {
@@ -14,12 +20,15 @@ type Router struct {
 }
 
 func InitApp(cfg *env.Config) *Router {
-	app := fiber.New()
+	// uploads may be as large as an attachment plus the rest of the multipart form
+	app := fiber.New(fiber.Config{BodyLimit: int(cfg.AttachmentMaxSize) + 1<<20})
 
 	app.Use(recover.New())
 
+	// the request ID correlates the activities recorded by a request, it's echoed in X-Request-ID
+	app.Use(requestid.New())
+
 	app.Use("/ws/:id", middleware.UpgradeMiddleware)
-	app.Use(middleware.AuthMiddleware)
 
 	return &Router{
 		App: app,
@@ -33,14 +42,38 @@ func (r *Router) Init(env *env.Config) {
 	mongo := database.NewMongoHandler(env)
 	postgreSql := database.NewDatabaseHandler(env)
 
+	store, err := storage.NewBlobStore(env)
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize attachment storage: %s", err))
+	}
+	deletionRepo := repositories.NewDeletionRepository(postgreSql, mongo, store, env)
+
+	r.App.Use(middleware.NewAuthMiddleware(env.AuthorizationSecret, redis))
+
 	InitAuth(r, env.AuthorizationSecret, redis, postgreSql)
 	InitProject(r, postgreSql)
 	InitSubtask(r, postgreSql)
-	InitTask(r, postgreSql)
-	InitUser(r, postgreSql)
-	InitDashboard(r, postgreSql)
-	InitTeam(r, postgreSql)
+	InitTask(r, redis, postgreSql)
+	InitUser(r, env.AuthorizationSecret, redis, postgreSql)
+	InitDashboard(r, redis, postgreSql, env.DashboardCacheTTL)
+	InitTeam(r, postgreSql, deletionRepo)
+	InitRole(r, postgreSql)
+	InitWorkflow(r, postgreSql)
+	InitDependency(r, postgreSql)
+	InitTemplate(r, postgreSql)
+	InitTime(r, postgreSql)
+	InitReport(r, postgreSql)
+	InitComment(r, redis, postgreSql)
+	InitAttachment(r, postgreSql, store, env)
+	InitLabel(r, postgreSql)
+	InitView(r, postgreSql)
+	InitAnalytics(r, postgreSql)
+	InitActivity(r, postgreSql)
+	InitTrash(r, postgreSql)
 	InitSocket(r, redis, mongo, postgreSql, env)
+
+	scheduler.StartRecurrence(postgreSql, env.RecurrenceInterval)
+	scheduler.StartTrashPurge(deletionRepo, env.TrashPurgeInterval, env.TrashRetention)
 }
 
 func (r *Router) Run() {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"mizito/internal/database"
 	"mizito/internal/env"
+	"mizito/internal/middleware"
 	"mizito/internal/websocket/websocket"
 )
 import websocketfiber "github.com/gofiber/contrib/websocket"
@@ -14,5 +15,5 @@ func InitSocket(r *Router, redis *database.RedisHandler, mongo *database.MongoHa
 
 	socketManager := websocket.NewChannelHandler(redis, mongo, postgreSql, env)
 
-	r.App.Get("/ws/:id", websocketfiber.New(socketManager.Register))
+	r.App.Get("/ws/:id", middleware.SocketOwnerMiddleware, websocketfiber.New(socketManager.Register))
 }
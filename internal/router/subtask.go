@@ -12,6 +12,7 @@ func InitSubtask(r *Router, postgreSql *database.DatabaseHandler) {
 	SubtasksApp.Get("/all", sHandler.GetSubtasksByTask)
 	SubtasksApp.Get("/:subtask_id", sHandler.GetSubtaskByID)
 	SubtasksApp.Put("/:subtask_id", sHandler.UpdateSubtask)
+	SubtasksApp.Patch("/:subtask_id", sHandler.UpdateSubtask)
 	SubtasksApp.Delete("/:subtask_id", sHandler.DeleteSubtask)
 
 	subtaskApp := r.App.Group("/subtask")
@@ -5,14 +5,17 @@ import (
 	"mizito/internal/handlers"
 )
 
-func InitTask(r *Router, postgreSql *database.DatabaseHandler) {
-	tHandler := handlers.NewTaskHandler(postgreSql)
+func InitTask(r *Router, redis *database.RedisHandler, postgreSql *database.DatabaseHandler) {
+	tHandler := handlers.NewTaskHandler(postgreSql, redis)
 
 	TaskApp := r.App.Group("/tasks")
+	TaskApp.Post("/bulk", tHandler.BulkTasks)
 	TaskApp.Get("/:project_id/all", tHandler.GetTasksByProject)
 	TaskApp.Get("/:task_id", tHandler.GetTaskByID)
 	TaskApp.Put("/:task_id", tHandler.UpdateTask)
+	TaskApp.Patch("/:task_id", tHandler.UpdateTask)
 	TaskApp.Delete("/:task_id", tHandler.DeleteTask)
+	TaskApp.Post("/:task_id/restore", tHandler.RestoreTask)
 	TaskApp.Post("/assign_task", tHandler.AssignTask)
 
 	subtaskApp := r.App.Group("/task")
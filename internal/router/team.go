@@ -3,18 +3,34 @@ package router
 import (
 	"mizito/internal/database"
 	"mizito/internal/handlers"
+	"mizito/internal/mailer"
+	"mizito/internal/repositories"
 )
 
-func InitTeam(r *Router, db *database.DatabaseHandler) {
+func InitTeam(r *Router, db *database.DatabaseHandler, deletionRepo repositories.DeletionRepository) {
 	routes := r.App.Group("/teams")
 
-	th := handlers.NewTeamHandler(db)
+	th := handlers.NewTeamHandler(db, deletionRepo)
+	ih := handlers.NewInvitationHandler(db, mailer.NewMailer(r.Cfg), r.Cfg)
 
 	routes.Get("/", th.GetTeams)
 	routes.Get("/:id", th.GetTeamByID)
 	routes.Get("/:id/projects", th.GetProjectsByTeam)
-	routes.Post("/add-users", th.AddUsersToTeam)
+	routes.Get("/:id/invitations", ih.GetTeamInvitations)
+	routes.Post("/:id/invitations", ih.InviteToTeam)
+	routes.Delete("/:id/invitations/:invitation_id", ih.RevokeInvitation)
 	routes.Delete("/remove-users", th.DeleteUsersFromTeam)
 	routes.Post("/create", th.CreateTeam)
 	routes.Put("/update", th.UpdateTeam)
+	routes.Put("/:id", th.UpdateTeam)
+	routes.Patch("/:id", th.UpdateTeam)
+	routes.Delete("/:id", th.DeleteTeam)
+	routes.Post("/:id/restore", th.RestoreTeam)
+	routes.Post("/:id/archive", th.ArchiveTeam)
+	routes.Post("/:id/unarchive", th.UnarchiveTeam)
+
+	invitations := r.App.Group("/invitations")
+	invitations.Get("/", ih.GetMyInvitations)
+	invitations.Post("/:invitation_id/accept", ih.AcceptInvitation)
+	invitations.Post("/:invitation_id/decline", ih.DeclineInvitation)
 }
@@ -0,0 +1,21 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitTemplate(r *Router, postgreSql *database.DatabaseHandler) {
+	tHandler := handlers.NewTemplateHandler(postgreSql)
+
+	r.App.Get("/teams/:id/templates", tHandler.GetTeamTemplates)
+	r.App.Post("/teams/:id/templates", tHandler.CreateTemplate)
+
+	templateApp := r.App.Group("/templates/:template_id")
+	templateApp.Get("", tHandler.GetTemplateByID)
+	templateApp.Put("", tHandler.UpdateTemplate)
+	templateApp.Delete("", tHandler.DeleteTemplate)
+	templateApp.Post("/projects", tHandler.CreateProjectFromTemplate)
+
+	r.App.Post("/projects/:project_id/templates/:template_id/apply", tHandler.ApplyTemplate)
+}
@@ -0,0 +1,26 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitTime(r *Router, postgreSql *database.DatabaseHandler) {
+	tHandler := handlers.NewTimeHandler(postgreSql)
+
+	taskApp := r.App.Group("/tasks/:task_id")
+	taskApp.Get("/time", tHandler.GetTaskTime)
+	taskApp.Post("/time", tHandler.CreateEntry)
+	taskApp.Post("/time/start", tHandler.StartTimer)
+
+	timeApp := r.App.Group("/time")
+	timeApp.Get("/running", tHandler.GetRunningTimer)
+	timeApp.Post("/stop", tHandler.StopTimer)
+	timeApp.Put("/:entry_id", tHandler.UpdateEntry)
+	timeApp.Delete("/:entry_id", tHandler.DeleteEntry)
+
+	r.App.Get("/projects/:project_id/time-report", tHandler.GetProjectReport)
+	r.App.Get("/projects/:project_id/timesheet", tHandler.GetProjectTimesheet)
+	r.App.Get("/users/:user_id/time-report", tHandler.GetUserReport)
+	r.App.Get("/users/:user_id/timesheet", tHandler.GetUserTimesheet)
+}
@@ -0,0 +1,12 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitTrash(r *Router, postgreSql *database.DatabaseHandler) {
+	trHandler := handlers.NewTrashHandler(postgreSql)
+
+	r.App.Get("/teams/:id/trash", trHandler.GetTeamTrash)
+}
@@ -3,10 +3,11 @@ package router
 import (
 	"mizito/internal/database"
 	"mizito/internal/handlers"
+	bearerhandler "mizito/internal/repositories/auth/bearer"
 )
 
-func InitUser(r *Router, postgreSql *database.DatabaseHandler) {
-	uHandler := handlers.NewUserHandler(postgreSql)
+func InitUser(r *Router, secret string, redis *database.RedisHandler, postgreSql *database.DatabaseHandler) {
+	uHandler := handlers.NewUserHandler(postgreSql, bearerhandler.NewJwtRepository(secret, redis))
 
 	TaskApp := r.App.Group("/users")
 	TaskApp.Get("/all", uHandler.GetUsers)
@@ -0,0 +1,21 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitView(r *Router, postgreSql *database.DatabaseHandler) {
+	vHandler := handlers.NewViewHandler(postgreSql)
+
+	meApp := r.App.Group("/me")
+	meApp.Get("/tasks", vHandler.GetMyTasks)
+	meApp.Get("/views", vHandler.GetViews)
+	meApp.Post("/views", vHandler.CreateView)
+
+	viewApp := r.App.Group("/views/:view_id")
+	viewApp.Get("", vHandler.GetView)
+	viewApp.Put("", vHandler.UpdateView)
+	viewApp.Delete("", vHandler.DeleteView)
+	viewApp.Get("/tasks", vHandler.GetViewTasks)
+}
@@ -0,0 +1,22 @@
+package router
+
+import (
+	"mizito/internal/database"
+	"mizito/internal/handlers"
+)
+
+func InitWorkflow(r *Router, postgreSql *database.DatabaseHandler) {
+	wHandler := handlers.NewWorkflowHandler(postgreSql)
+
+	projectApp := r.App.Group("/projects/:project_id")
+	projectApp.Get("/workflow", wHandler.GetWorkflow)
+	projectApp.Post("/workflow/statuses", wHandler.CreateStatus)
+	projectApp.Put("/workflow/statuses/:status_id", wHandler.UpdateStatus)
+	projectApp.Delete("/workflow/statuses/:status_id", wHandler.DeleteStatus)
+	projectApp.Put("/workflow/transitions", wHandler.SetTransitions)
+	projectApp.Get("/board", wHandler.GetBoard)
+
+	taskApp := r.App.Group("/tasks/:task_id")
+	taskApp.Post("/move", wHandler.MoveTask)
+	taskApp.Get("/moves", wHandler.GetTaskMoves)
+}
@@ -0,0 +1,29 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"mizito/internal/database"
+	"mizito/internal/repositories"
+)
+
+// StartRecurrence generates the next occurrences of recurring tasks every interval until the process exits
+func StartRecurrence(postgreSql *database.DatabaseHandler, interval time.Duration) {
+	repo := repositories.NewRecurrenceRepository(postgreSql)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			created, err := repo.GenerateOccurrences(now)
+			if err != nil {
+				fmt.Println("failed to generate recurring tasks:", err)
+			}
+			if created > 0 {
+				fmt.Printf("generated %d recurring tasks\n", created)
+			}
+		}
+	}()
+}
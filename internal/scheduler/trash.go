@@ -0,0 +1,26 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"mizito/internal/repositories"
+)
+
+// StartTrashPurge permanently deletes what stayed in the trash longer than the retention every interval until the process exits
+func StartTrashPurge(deletionRepo repositories.DeletionRepository, interval time.Duration, retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			purged, err := deletionRepo.PurgeTrash(now.Add(-retention))
+			if err != nil {
+				fmt.Println("failed to purge the trash:", err)
+			}
+			if purged > 0 {
+				fmt.Printf("purged %d items from the trash\n", purged)
+			}
+		}
+	}()
+}
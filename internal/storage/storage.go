@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"mizito/internal/env"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps attachment contents by key, keys only contain letters, digits and slashes.
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.ReadSeeker, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStore returns the backend selected by StorageBackend, local or s3
+func NewBlobStore(cfg *env.Config) (BlobStore, error) {
+	switch cfg.StorageBackend {
+	case "local":
+		return NewLocalStore(cfg.StorageLocalPath)
+	case "s3":
+		return NewS3Store(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey)
+	}
+	return nil, fmt.Errorf("unknown storage backend %s", cfg.StorageBackend)
+}
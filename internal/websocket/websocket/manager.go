@@ -6,18 +6,20 @@ import (
 	"mizito/internal/database"
 	"mizito/internal/env"
 	"mizito/internal/repositories"
+	"mizito/internal/repositories/utils"
+	"mizito/pkg/models"
 	"mizito/pkg/models/dtos"
 	messagedto "mizito/pkg/models/dtos/message"
-	"strconv"
 	"time"
 )
 
 import "github.com/gofiber/contrib/websocket"
 
 type ChannelRepository struct {
-	socketManager SocketManager
-	messageRepo   repositories.MessageChannelRepository
-	ProjectDetail repositories.ProjectDetailRepo
+	socketManager  SocketManager
+	messageRepo    repositories.MessageChannelRepository
+	ProjectDetail  repositories.ProjectDetailRepo
+	permissionRepo utils.PermissionRepository
 }
 
 func NewChannelHandler(redis *database.RedisHandler, mongo *database.MongoHandler, postgreSql *database.DatabaseHandler, env *env.Config) *ChannelRepository {
@@ -25,9 +27,10 @@ func NewChannelHandler(redis *database.RedisHandler, mongo *database.MongoHandle
 	sm := NewSocketHandler()
 
 	chHandler := &ChannelRepository{
-		socketManager: sm,
-		messageRepo:   repositories.NewMessageRepository(redis, mongo, env),
-		ProjectDetail: repositories.NewProjectRepository(postgreSql),
+		socketManager:  sm,
+		messageRepo:    repositories.NewMessageRepository(redis, mongo, env),
+		ProjectDetail:  repositories.NewProjectRepository(postgreSql),
+		permissionRepo: utils.NewPermissionRepository(postgreSql),
 	}
 
 	go chHandler.ProcessEvents()
@@ -41,6 +44,9 @@ func (chm ChannelRepository) ProcessEvents() {
 		event.Event = &e
 		if event.Event.EventType == dtos.Message {
 			chm.processMsg(&event)
+		} else if len(e.Recipients) > 0 {
+			event.Ids = e.Recipients
+			event.Event.Recipients = nil
 		}
 		fmt.Println("final event is : ", event)
 		chm.socketManager.SendEvent(&event)
@@ -60,11 +66,10 @@ func (chm ChannelRepository) processMsg(event *dtos.WebSocketMessage) {
 }
 
 func (chm ChannelRepository) Register(c *websocket.Conn) {
-	sid := c.Params("id")
-	// middleware checks id being integer
-	id, _ := strconv.ParseInt(sid, 10, 32)
+	// the socket middleware only upgrades a path id matching the token's user
+	id := c.Locals("userID").(uint)
 
-	chm.socketManager.AddSocket(uint(id), c)
+	chm.socketManager.AddSocket(id, c)
 
 	for {
 		var (
@@ -78,12 +83,28 @@ func (chm ChannelRepository) Register(c *websocket.Conn) {
 			continue
 		}
 		e.Payload.CreatedAt = time.Now()
+		// only the server addresses events to users
+		e.Recipients = nil
 		if eRaw, err = json.Marshal(e); err != nil {
 			fmt.Println(err.Error())
 			continue
 		}
 
 		if e.EventType == dtos.Message {
+			// viewers and non members can read the chat but not send to it
+			if !chm.permissionRepo.Authorize(id, models.ChatSend, utils.ProjectResource(e.Payload.Project)) {
+				if err := c.WriteJSON(map[string]string{"error": "you don't have permission to send messages to this project"}); err != nil {
+					fmt.Println(err.Error())
+				}
+				continue
+			}
+			// archived projects keep their chat history read-only
+			if chm.permissionRepo.CheckArchived(utils.ProjectResource(e.Payload.Project)) {
+				if err := c.WriteJSON(map[string]string{"error": repositories.ErrArchived.Error()}); err != nil {
+					fmt.Println(err.Error())
+				}
+				continue
+			}
 			go chm.messageRepo.PublishMsg(eRaw)
 
 		}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+type ActivityAction string
+
+const (
+	ActivityCreated         ActivityAction = "created"
+	ActivityUpdated         ActivityAction = "updated"
+	ActivityDeleted         ActivityAction = "deleted"
+	ActivityRestored        ActivityAction = "restored"
+	ActivityPurged          ActivityAction = "purged"
+	ActivityArchived        ActivityAction = "archived"
+	ActivityUnarchived      ActivityAction = "unarchived"
+	ActivityAssigned        ActivityAction = "assigned"
+	ActivityMemberAdded     ActivityAction = "member_added"
+	ActivityMemberRemoved   ActivityAction = "member_removed"
+	ActivityRoleAssigned    ActivityAction = "role_assigned"
+	ActivityPasswordChanged ActivityAction = "password_changed"
+	ActivityEmailVerified   ActivityAction = "email_verified"
+)
+
+type ActivityTarget string
+
+const (
+	TeamTarget    ActivityTarget = "team"
+	ProjectTarget ActivityTarget = "project"
+	TaskTarget    ActivityTarget = "task"
+	SubtaskTarget ActivityTarget = "subtask"
+	UserTarget    ActivityTarget = "user"
+)
+
+// FieldChange is the value of a field before and after a mutation, nil when the record didn't exist
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// ActivityChanges maps the changed field names of the target to their change
+type ActivityChanges map[string]FieldChange
+
+// Activity is an append-only entry of the activity log. TeamID and ProjectID place the target
+// in the feeds, ActorID is 0 for changes made by the system or by an anonymous request and
+// RequestID correlates the entries written by one request.
+type Activity struct {
+	ID         uint            `gorm:"primaryKey"`
+	ActorID    uint            `gorm:"index"`
+	Action     ActivityAction  `gorm:"not null;index"`
+	TargetType ActivityTarget  `gorm:"not null;index:idx_activity_target"`
+	TargetID   uint            `gorm:"index:idx_activity_target"`
+	TeamID     *uint           `gorm:"index"`
+	ProjectID  *uint           `gorm:"index"`
+	Changes    ActivityChanges `gorm:"type:jsonb;serializer:json"`
+	RequestID  string          `gorm:"index"`
+	CreatedAt  time.Time       `gorm:"index"`
+}
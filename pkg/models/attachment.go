@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Blob is a stored file content, identical uploads share one blob.
+type Blob struct {
+	Hash        string `gorm:"primaryKey;size:64"`
+	Size        int64
+	ContentType string
+	StorageKey  string
+	CreatedAt   time.Time
+}
+
+// AttachmentOwner is the kind of object an attachment belongs to.
+type AttachmentOwner string
+
+const (
+	AttachmentTask    AttachmentOwner = "task"
+	AttachmentComment AttachmentOwner = "comment"
+	// AttachmentMessage files are shared in the chat of the project given as owner
+	AttachmentMessage       AttachmentOwner = "message"
+	AttachmentProjectAvatar AttachmentOwner = "project"
+	AttachmentUserAvatar    AttachmentOwner = "user"
+)
+
+func IsValidAttachmentOwner(owner AttachmentOwner) bool {
+	switch owner {
+	case AttachmentTask, AttachmentComment, AttachmentMessage, AttachmentProjectAvatar, AttachmentUserAvatar:
+		return true
+	}
+	return false
+}
+
+// Attachment is an uploaded file attached to a task, comment, chat or avatar.
+type Attachment struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	BlobHash    string          `gorm:"size:64;not null;index" json:"hash"`
+	Name        string          `json:"name"`
+	ContentType string          `json:"content_type"`
+	Size        int64           `json:"size"`
+	UploadedBy  uint            `gorm:"not null" json:"uploaded_by"`
+	OwnerType   AttachmentOwner `gorm:"not null;index:idx_attachment_owner" json:"owner_type"`
+	OwnerID     uint            `gorm:"not null;index:idx_attachment_owner" json:"owner_id"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
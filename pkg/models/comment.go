@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskComment is a markdown comment on a task, deleted comments keep their history.
+type TaskComment struct {
+	ID          uint   `gorm:"primaryKey"`
+	TaskID      uint   `gorm:"not null;index"`
+	AuthorID    uint   `gorm:"not null"`
+	Body        string `gorm:"type:text"`
+	EditedAt    *time.Time
+	Mentions    []CommentMention    `gorm:"foreignKey:CommentID"`
+	Attachments []CommentAttachment `gorm:"foreignKey:CommentID"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+// CommentRevision keeps the body a comment had before an edit or its deletion.
+type CommentRevision struct {
+	ID        uint   `gorm:"primaryKey"`
+	CommentID uint   `gorm:"not null;index"`
+	Body      string `gorm:"type:text"`
+	EditedBy  uint
+	CreatedAt time.Time
+}
+
+type CommentMention struct {
+	CommentID uint `gorm:"primaryKey"`
+	UserID    uint `gorm:"primaryKey"`
+}
+
+// CommentAttachment references a file attached to a comment.
+type CommentAttachment struct {
+	ID           uint `gorm:"primaryKey"`
+	CommentID    uint `gorm:"not null;index"`
+	AttachmentID *uint
+	Name         string
+	URL          string
+}
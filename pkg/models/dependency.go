@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// TaskDependency means BlockedID can't be finished before BlockerID is done,
+// both tasks always belong to the same project.
+type TaskDependency struct {
+	ID        uint `gorm:"primaryKey"`
+	ProjectID uint `gorm:"not null;index"`
+	BlockerID uint `gorm:"not null;uniqueIndex:idx_task_dependency"`
+	BlockedID uint `gorm:"not null;uniqueIndex:idx_task_dependency;index"`
+	CreatedBy uint
+	CreatedAt time.Time
+}
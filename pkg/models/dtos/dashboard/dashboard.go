@@ -0,0 +1,59 @@
+package dashboard_dto
+
+import (
+	"time"
+)
+
+type Dashboard struct {
+	Username       string `json:"username"`
+	ProfilePicture string `json:"profile_picture"`
+	// TodoCount counts every unfinished subtask, TodoList only holds the most urgent ones
+	TodoCount         int                 `json:"todo_count"`
+	TodoList          []TodoSubtask       `json:"todo_list"`
+	Projects          []ProjectCompletion `json:"project_list"`
+	Coworkers         []Coworker          `json:"coworkers"`
+	OverdueCount      int                 `json:"overdue_count"`
+	UpcomingDeadlines []Deadline          `json:"upcoming_deadlines"`
+	GeneratedAt       time.Time           `json:"generated_at"`
+}
+
+// TodoSubtask is an unfinished subtask of a task assigned to the user
+type TodoSubtask struct {
+	SubtaskID   uint      `json:"subtask_id"`
+	Title       string    `json:"title"`
+	TaskID      uint      `json:"task_id"`
+	TaskTitle   string    `json:"task_title"`
+	ProjectID   uint      `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	DueDate     time.Time `json:"due_date"`
+}
+
+type ProjectCompletion struct {
+	ProjectID     uint   `json:"project_id"`
+	ProjectName   string `json:"project_name"`
+	CompletionPct int    `json:"completion_pct"`
+	TaskCount     int    `json:"task_count"`
+}
+
+// Coworker is a member of one of the user's teams, Teams lists every team they share
+type Coworker struct {
+	UserID    uint           `json:"user_id"`
+	Username  string         `json:"username"`
+	AvatarUrl string         `json:"avatar_url"`
+	Teams     []CoworkerTeam `json:"teams"`
+}
+
+type CoworkerTeam struct {
+	TeamID   uint   `json:"team_id"`
+	TeamName string `json:"team_name"`
+	Role     string `json:"role"`
+}
+
+// Deadline is an unfinished task assigned to the user that is due soon
+type Deadline struct {
+	TaskID      uint      `json:"task_id"`
+	Title       string    `json:"title"`
+	ProjectID   uint      `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	DueDate     time.Time `json:"due_date"`
+}
@@ -1,18 +1,30 @@
 package dtos
 
-import message_dto "mizito/pkg/models/dtos/message"
+import (
+	"encoding/json"
+	message_dto "mizito/pkg/models/dtos/message"
+)
 
 type EventType string
 
 const (
-	Message      EventType = "message"
-	Notification EventType = "notification"
+	Message        EventType = "message"
+	Notification   EventType = "notification"
+	CommentCreated EventType = "comment.created"
+	CommentUpdated EventType = "comment.updated"
+	CommentDeleted EventType = "comment.deleted"
+	// TasksChanged is published once for a bulk change of tasks
+	TasksChanged EventType = "tasks.changed"
 )
 
 type Event struct {
 	// for later use, struct must get generic attribute for Payload
 	Payload   message_dto.Message `validate:"dive" json:"payload"`
 	EventType EventType           `validate:"oneof='message notification'" json:"event_type"`
+	// Data carries the payload of server side events
+	Data json.RawMessage `json:"data,omitempty"`
+	// Recipients are the users a server side event is delivered to, never sent to clients
+	Recipients []uint `json:"recipients,omitempty"`
 }
 
 type WebSocketMessage struct {
@@ -5,7 +5,9 @@ import (
 )
 
 type Message struct {
-	Project   uint   `json:"project_id"`
-	Content   string `json:"content"`
-	CreatedAt time.Time
+	Project uint   `json:"project_id"`
+	Content string `json:"content"`
+	// Attachments are ids of files uploaded to the project chat
+	Attachments []uint `json:"attachments,omitempty"`
+	CreatedAt   time.Time
 }
@@ -5,5 +5,7 @@ import "github.com/golang-jwt/jwt/v4"
 type UserClaims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
+	// SessionVersion must match the user's current version, it gets bumped to revoke all sessions
+	SessionVersion int64 `json:"session_version"`
 	jwt.StandardClaims
 }
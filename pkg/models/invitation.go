@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationDeclined InvitationStatus = "declined"
+	InvitationRevoked  InvitationStatus = "revoked"
+)
+
+// TeamInvitation invites either an existing user (InviteeID) or an email address
+// without an account yet, in which case it's claimed by the user signing up with that email.
+type TeamInvitation struct {
+	ID        uint `gorm:"primaryKey"`
+	TeamID    uint `gorm:"not null;index"`
+	InviterID uint `gorm:"not null"`
+	InviteeID *uint
+	Email     string           `gorm:"index"`
+	Role      Role             `gorm:"not null"`
+	Status    InvitationStatus `gorm:"not null;default:pending"`
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Label is a colored tag defined by a project, tasks of the project can carry any number of them.
+type Label struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	ProjectID uint   `gorm:"not null;uniqueIndex:idx_label_project_name" json:"project_id"`
+	Name      string `gorm:"not null;uniqueIndex:idx_label_project_name" json:"name"`
+	// Color is a #rrggbb hex color
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CustomFieldType decides which values a custom field accepts.
+type CustomFieldType string
+
+const (
+	FieldText   CustomFieldType = "text"
+	FieldNumber CustomFieldType = "number"
+	// FieldDate values are YYYY-MM-DD dates
+	FieldDate CustomFieldType = "date"
+	// FieldSelect values are one of the field's options
+	FieldSelect CustomFieldType = "select"
+	// FieldUser values are ids of project members
+	FieldUser CustomFieldType = "user"
+)
+
+func IsValidCustomFieldType(fieldType CustomFieldType) bool {
+	switch fieldType {
+	case FieldText, FieldNumber, FieldDate, FieldSelect, FieldUser:
+		return true
+	}
+	return false
+}
+
+// CustomField is a typed field a project adds to its tasks.
+type CustomField struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	ProjectID uint            `gorm:"not null;uniqueIndex:idx_field_project_name" json:"project_id"`
+	Name      string          `gorm:"not null;uniqueIndex:idx_field_project_name" json:"name"`
+	Type      CustomFieldType `gorm:"not null" json:"type"`
+	Options   []string        `gorm:"serializer:json" json:"options,omitempty"`
+	Position  int             `json:"position"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// TaskFieldValue is the value of a custom field on a task. Value holds the canonical text of
+// every type, numbers and dates are also kept typed so they can be filtered by range.
+type TaskFieldValue struct {
+	TaskID      uint       `gorm:"primaryKey" json:"task_id"`
+	FieldID     uint       `gorm:"primaryKey;index" json:"field_id"`
+	Value       string     `gorm:"index" json:"value"`
+	NumberValue *float64   `json:"-"`
+	DateValue   *time.Time `gorm:"type:date" json:"-"`
+}
@@ -0,0 +1,122 @@
+package models
+
+import "time"
+
+type Permission string
+
+const (
+	TeamView         Permission = "team.view"
+	TeamUpdate       Permission = "team.update"
+	TeamDelete       Permission = "team.delete"
+	MemberInvite     Permission = "member.invite"
+	MemberRemove     Permission = "member.remove"
+	MemberAssignRole Permission = "member.assign_role"
+	RoleManage       Permission = "role.manage"
+
+	ProjectCreate    Permission = "project.create"
+	ProjectView      Permission = "project.view"
+	ProjectUpdate    Permission = "project.update"
+	ProjectDelete    Permission = "project.delete"
+	ProjectAddMember Permission = "project.add_member"
+
+	TaskCreate Permission = "task.create"
+	TaskView   Permission = "task.view"
+	TaskUpdate Permission = "task.update"
+	TaskDelete Permission = "task.delete"
+	TaskAssign Permission = "task.assign"
+
+	SubtaskCreate Permission = "subtask.create"
+	SubtaskUpdate Permission = "subtask.update"
+	SubtaskDelete Permission = "subtask.delete"
+
+	TimeTrack   Permission = "time.track"
+	TimeManage  Permission = "time.manage"
+	TimeViewAll Permission = "time.view_all"
+
+	ChatSend Permission = "chat.send"
+)
+
+// AllPermissions lists every permission that can be granted to a role.
+var AllPermissions = []Permission{
+	TeamView, TeamUpdate, TeamDelete, MemberInvite, MemberRemove, MemberAssignRole, RoleManage,
+	ProjectCreate, ProjectView, ProjectUpdate, ProjectDelete, ProjectAddMember,
+	TaskCreate, TaskView, TaskUpdate, TaskDelete, TaskAssign,
+	SubtaskCreate, SubtaskUpdate, SubtaskDelete,
+	TimeTrack, TimeManage, TimeViewAll,
+	ChatSend,
+}
+
+// BuiltinRolePermissions holds the permissions of the roles every team has,
+// they can't be changed or deleted, teams define CustomRole for anything else.
+var BuiltinRolePermissions = map[Role][]Permission{
+	Admin: AllPermissions,
+	Member: {
+		TeamView, ProjectView, TaskView, SubtaskUpdate, TimeTrack, ChatSend,
+	},
+}
+
+// BuiltinProjectRolePermissions holds the permissions of the roles assigned on project memberships.
+var BuiltinProjectRolePermissions = map[Role][]Permission{
+	ProjectOwner: {
+		ProjectView, ProjectUpdate, ProjectDelete, ProjectAddMember, MemberAssignRole,
+		TaskCreate, TaskView, TaskUpdate, TaskDelete, TaskAssign,
+		SubtaskCreate, SubtaskUpdate, SubtaskDelete,
+		TimeTrack, TimeManage,
+		ChatSend,
+	},
+	ProjectMaintainer: {
+		ProjectView, ProjectUpdate, ProjectAddMember,
+		TaskCreate, TaskView, TaskUpdate, TaskDelete, TaskAssign,
+		SubtaskCreate, SubtaskUpdate, SubtaskDelete,
+		TimeTrack, TimeManage,
+		ChatSend,
+	},
+	ProjectContributor: {
+		ProjectView,
+		TaskCreate, TaskView, TaskUpdate,
+		SubtaskCreate, SubtaskUpdate, SubtaskDelete,
+		TimeTrack,
+		ChatSend,
+	},
+	ProjectViewer: {
+		ProjectView, TaskView,
+	},
+}
+
+// BuiltinPermissions returns the permissions of a built-in team or project role.
+func BuiltinPermissions(role Role) ([]Permission, bool) {
+	if permissions, ok := BuiltinRolePermissions[role]; ok {
+		return permissions, true
+	}
+	permissions, ok := BuiltinProjectRolePermissions[role]
+	return permissions, ok
+}
+
+func IsProjectRole(role Role) bool {
+	_, ok := BuiltinProjectRolePermissions[role]
+	return ok
+}
+
+func IsValidPermission(permission Permission) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CustomRole is a team defined role, it's referenced by name from TeamMember.Role and ProjectMember.Role.
+type CustomRole struct {
+	ID          uint             `gorm:"primaryKey"`
+	TeamID      uint             `gorm:"not null;uniqueIndex:idx_custom_role_team_name"`
+	Name        Role             `gorm:"not null;uniqueIndex:idx_custom_role_team_name"`
+	Permissions []RolePermission `gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE;"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type RolePermission struct {
+	RoleID     uint       `gorm:"primaryKey"`
+	Permission Permission `gorm:"primaryKey"`
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Project struct {
@@ -14,4 +16,38 @@ type Project struct {
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	ImageUrl       string
+	ProgressMode   ProgressMode `gorm:"default:manual"`
+	// DeletedAt is set while the project is in the trash, its tasks share it when deleted with it
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	// ArchivedAt is set while the project is archived, it's read-only and left out of the listings
+	ArchivedAt *time.Time `gorm:"index"`
+	// Version is bumped by every update of the project's fields, it's sent as the ETag
+	Version uint `gorm:"not null;default:1"`
+}
+
+// ProgressMode decides how the progress of the project's tasks is computed.
+type ProgressMode string
+
+const (
+	// ProgressManual leaves Task.ProgressPercentage to the users
+	ProgressManual ProgressMode = "manual"
+	// ProgressSubtaskRatio is the share of completed subtasks
+	ProgressSubtaskRatio ProgressMode = "subtask_ratio"
+	// ProgressWeightedSubtasks is the share of completed subtask weight
+	ProgressWeightedSubtasks ProgressMode = "weighted"
+)
+
+func IsValidProgressMode(mode ProgressMode) bool {
+	return mode == ProgressManual || mode == ProgressSubtaskRatio || mode == ProgressWeightedSubtasks
+}
+
+// ProjectMember is the users_projects join table, Role overrides the member's team role inside the project when set.
+type ProjectMember struct {
+	ProjectID uint `gorm:"primaryKey"`
+	UserID    uint `gorm:"primaryKey"`
+	Role      Role
+}
+
+func (ProjectMember) TableName() string {
+	return "users_projects"
 }
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type SecurityEventType string
+
+const (
+	LoginLockout    SecurityEventType = "login_lockout"
+	AccountUnlocked SecurityEventType = "account_unlocked"
+)
+
+// SecurityEvent is an audit record for authentication related incidents.
+// ActorID is the admin who triggered the event, zero when it was triggered by the system or the user.
+type SecurityEvent struct {
+	ID        uint              `gorm:"primaryKey"`
+	Type      SecurityEventType `gorm:"not null;index"`
+	Username  string            `gorm:"index"`
+	IP        string
+	ActorID   uint
+	Detail    string
+	CreatedAt time.Time
+}
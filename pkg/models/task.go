@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Task struct {
 	ID                 uint `gorm:"primaryKey" validate:"required"`
@@ -13,14 +17,34 @@ type Task struct {
 	DueDate            time.Time
 	Reports            []Report `gorm:"foreignKey:TaskID"`
 	ProgressPercentage int      `validator:"gte=0;lte=100" gorm:"default:0"`
+	EstimateMinutes    int
+	Labels             []Label          `gorm:"many2many:task_labels;"`
+	FieldValues        []TaskFieldValue `gorm:"foreignKey:TaskID"`
+	StatusID           *uint            `gorm:"index"`
+	Rank               string           `gorm:"index"`
+	// RecurrenceRule is an RFC 5545 RRULE, the scheduler creates the next occurrence
+	// once the task is done or its DueDate passed
+	RecurrenceRule     string
+	RecurrenceParentID *uint `gorm:"index"`
+	RecurrenceIndex    int   `gorm:"default:1"`
+	RecurrenceSpawned  bool  `gorm:"default:false"`
+	// DeletedAt is set while the task is in the trash
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	// Version is bumped by every update of the task's fields, it's sent as the ETag
+	Version uint `gorm:"not null;default:1"`
 }
 
+// Report is a member's standup style report on a task for one day, Message is what was done.
 type Report struct {
-	ID        uint `gorm:"primaryKey"`
-	TaskID    uint
-	UserID    uint `gorm:"not null"`
-	Message   string
-	CreatedAt time.Time
+	ID         uint `gorm:"primaryKey"`
+	TaskID     uint `gorm:"uniqueIndex:idx_report_task_user_date"`
+	UserID     uint `gorm:"not null;uniqueIndex:idx_report_task_user_date"`
+	Message    string
+	Plan       string
+	Blockers   string
+	ReportDate time.Time `gorm:"type:date;index;uniqueIndex:idx_report_task_user_date"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
 type Subtask struct {
@@ -28,6 +52,9 @@ type Subtask struct {
 	TaskID      uint
 	Title       string `validate:"required"`
 	IsCompleted bool   `gorm:"default:False"`
+	Weight      int    `gorm:"default:1"`
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	// Version is bumped by every update of the subtask, it's sent as the ETag
+	Version uint `gorm:"not null;default:1"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type TaskEventType string
+
+const (
+	TaskCreated  TaskEventType = "created"
+	TaskMoved    TaskEventType = "moved"
+	TaskAssigned TaskEventType = "assigned"
+	TaskDeleted  TaskEventType = "deleted"
+	TaskRestored TaskEventType = "restored"
+)
+
+// TaskEvent is an entry of the task history analytics are computed from. StatusID and Done
+// are the state of the task after created, moved and restored events, UserID is the assignee of
+// assigned events and ActorID is 0 for changes made by the scheduler.
+type TaskEvent struct {
+	ID         uint          `gorm:"primaryKey"`
+	TaskID     uint          `gorm:"not null;index"`
+	ProjectID  uint          `gorm:"not null;index:idx_task_event_project_time"`
+	Type       TaskEventType `gorm:"not null"`
+	StatusID   *uint
+	Done       bool
+	UserID     *uint
+	ActorID    uint
+	OccurredAt time.Time `gorm:"not null;index:idx_task_event_project_time"`
+}
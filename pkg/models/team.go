@@ -1,5 +1,11 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type Role string
 
 const (
@@ -7,11 +13,25 @@ const (
 	Member Role = "member"
 )
 
+// project roles are only assigned on project memberships
+const (
+	ProjectOwner       Role = "owner"
+	ProjectMaintainer  Role = "maintainer"
+	ProjectContributor Role = "contributor"
+	ProjectViewer      Role = "viewer"
+)
+
 type Team struct {
 	ID       uint `gorm:"primaryKey"`
 	Name     string
 	Projects []Project    `gorm:"foreignKey:TeamID"`
 	Members  []TeamMember `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE;"`
+	// DeletedAt is set while the team is in the trash
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	// ArchivedAt is set while the team is archived, the team and its projects are read-only and left out of the listings
+	ArchivedAt *time.Time `gorm:"index"`
+	// Version is bumped by every update of the team's fields, it's sent as the ETag
+	Version uint `gorm:"not null;default:1"`
 }
 
 type TeamMember struct {
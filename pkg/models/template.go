@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// ProjectTemplate is a team scoped blueprint of tasks that projects can be created from.
+type ProjectTemplate struct {
+	ID          uint   `gorm:"primaryKey"`
+	TeamID      uint   `gorm:"not null;index"`
+	Name        string `validate:"required"`
+	Description string
+	Tasks       []TemplateTask `gorm:"foreignKey:TemplateID;constraint:OnDelete:CASCADE;"`
+	CreatedBy   uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// TemplateTask becomes a task when the template is instantiated, DueOffsetDays is counted
+// from the start date of the instantiation and AssigneeRole assigns every project member holding it.
+type TemplateTask struct {
+	ID            uint `gorm:"primaryKey"`
+	TemplateID    uint `gorm:"not null;index"`
+	Title         string
+	Description   string
+	TaskPriority  int
+	DueOffsetDays int
+	AssigneeRole  Role
+	Position      int
+	Subtasks      []TemplateSubtask `gorm:"foreignKey:TemplateTaskID;constraint:OnDelete:CASCADE;"`
+}
+
+type TemplateSubtask struct {
+	ID             uint `gorm:"primaryKey"`
+	TemplateTaskID uint `gorm:"not null;index"`
+	Title          string
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// TimeEntry is time a user spent on a task, a running timer has no EndedAt.
+// a user has at most one running timer.
+type TimeEntry struct {
+	ID        uint       `gorm:"primaryKey"`
+	TaskID    uint       `gorm:"not null;index"`
+	UserID    uint       `gorm:"not null;index;uniqueIndex:idx_running_timer,where:ended_at IS NULL"`
+	StartedAt time.Time  `gorm:"not null;index"`
+	EndedAt   *time.Time `gorm:"index"`
+	Note      string
+	Manual    bool `gorm:"default:false"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Duration is the tracked time, up to now for a running timer.
+func (e *TimeEntry) Duration() time.Duration {
+	if e.EndedAt == nil {
+		return time.Since(e.StartedAt)
+	}
+	return e.EndedAt.Sub(e.StartedAt)
+}
@@ -3,11 +3,14 @@ package models
 import "time"
 
 type User struct {
-	ID        uint   `gorm:"primaryKey"`
-	Username  string `validate:"required" gorm:"unique"`
-	Password  string
-	Reports   []Report `gorm:"foreignKey:UserID"`
-	Email     string   `validate:"required, endswith=@gmail.com" gorm:"unique"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID            uint   `gorm:"primaryKey"`
+	Username      string `validate:"required" gorm:"unique"`
+	Password      string
+	Reports       []Report `gorm:"foreignKey:UserID"`
+	Email         string   `validate:"required, endswith=@gmail.com" gorm:"unique"`
+	EmailVerified bool     `gorm:"default:false"`
+	IsAdmin       bool     `gorm:"default:false"`
+	AvatarUrl     string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SavedView is a named task listing query. Views with a ProjectID list that project's tasks,
+// the others list the owner's tasks across projects. A view with a TeamID is shared with the team.
+type SavedView struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	OwnerID   uint   `gorm:"not null;index" json:"owner_id"`
+	TeamID    *uint  `gorm:"index" json:"team_id"`
+	ProjectID *uint  `json:"project_id"`
+	Name      string `gorm:"not null" json:"name"`
+	// Query is the query string of the listing, e.g. status=3&assignee=me&sort=-due_date
+	Query     string    `json:"query"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// WorkflowStatus is a column of a project's board, Position orders the columns.
+type WorkflowStatus struct {
+	ID        uint   `gorm:"primaryKey"`
+	ProjectID uint   `gorm:"not null;index"`
+	Name      string `validate:"required"`
+	Position  int    `gorm:"not null"`
+	IsDone    bool   `gorm:"default:false"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WorkflowTransition allows moving tasks from one status to another, once a project
+// defines transitions only those moves are allowed, otherwise tasks move freely.
+type WorkflowTransition struct {
+	ProjectID    uint `gorm:"not null;index"`
+	FromStatusID uint `gorm:"primaryKey"`
+	ToStatusID   uint `gorm:"primaryKey"`
+}
+
+// TaskMove records who moved a task between statuses and when.
+type TaskMove struct {
+	ID           uint `gorm:"primaryKey"`
+	TaskID       uint `gorm:"not null;index"`
+	FromStatusID *uint
+	ToStatusID   uint `gorm:"not null"`
+	MovedBy      uint `gorm:"not null"`
+	MovedAt      time.Time
+}
+
+// DefaultWorkflow holds the columns every project is created with.
+var DefaultWorkflow = []WorkflowStatus{
+	{Name: "To Do", Position: 0},
+	{Name: "In Progress", Position: 1},
+	{Name: "Review", Position: 2},
+	{Name: "Done", Position: 3, IsDone: true},
+}